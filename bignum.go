@@ -0,0 +1,254 @@
+package cbor
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// maxDecimalExp limits decimal fraction exponent as 10^exp is computed exactly.
+const maxDecimalExp = 10000
+
+var (
+	bigIntType   = reflect.TypeOf((*big.Int)(nil))
+	bigFloatType = reflect.TypeOf((*big.Float)(nil))
+)
+
+// AppendBigInt encodes x as Int or Neg if it fits into 64 bits
+// and as tag 2 or 3 bignum otherwise.
+// nil is encoded as null.
+func (e Encoder) AppendBigInt(b []byte, x *big.Int) []byte {
+	if x == nil {
+		return e.AppendNull(b)
+	}
+
+	tag, num := Int, TagPosBignum
+
+	var m big.Int
+
+	if x.Sign() < 0 {
+		tag, num = Neg, TagNegBignum
+		x = m.Not(x) // -1 - x
+	}
+
+	if x.IsUint64() {
+		return e.AppendTag64(b, tag, x.Uint64())
+	}
+
+	n := (x.BitLen() + 7) / 8
+
+	b = e.AppendTag64(b, Labeled, uint64(num))
+	b = e.AppendTag(b, Bytes, n)
+	b = append(b, make([]byte, n)...)
+	x.FillBytes(b[len(b)-n:])
+
+	return b
+}
+
+// AppendBigFloat encodes x as integer if it's an integer fitting into 64 bits,
+// as float if it's exactly representable as float64
+// and as tag 5 bigfloat otherwise.
+// nil is encoded as null.
+func (e Encoder) AppendBigFloat(b []byte, x *big.Float) []byte {
+	if x == nil {
+		return e.AppendNull(b)
+	}
+
+	if x.IsInt() && (x.Sign() != 0 || !x.Signbit()) {
+		if v, acc := x.Int64(); acc == big.Exact {
+			return e.AppendInt64(b, v)
+		}
+
+		if v, acc := x.Uint64(); acc == big.Exact {
+			return e.AppendUint64(b, v)
+		}
+	}
+
+	if f, acc := x.Float64(); acc == big.Exact {
+		return e.AppendFloat(b, f)
+	}
+
+	// x = mant * 2^exp, 0.5 <= |mant| < 1
+	exp := x.MantExp(nil)
+	prec := int(x.MinPrec())
+
+	var m big.Int
+
+	new(big.Float).SetMantExp(x, prec-exp).Int(&m)
+
+	b = e.AppendTag64(b, Labeled, TagBigfloat)
+	b = e.AppendArray(b, 2)
+	b = e.AppendInt64(b, int64(exp-prec))
+
+	return e.AppendBigInt(b, &m)
+}
+
+// AppendDecimal encodes tag 4 decimal fraction mant * 10^exp.
+func (e Encoder) AppendDecimal(b []byte, mant *big.Int, exp int64) []byte {
+	b = e.AppendTag64(b, Labeled, TagDecimal)
+	b = e.AppendArray(b, 2)
+	b = e.AppendInt64(b, exp)
+
+	return e.AppendBigInt(b, mant)
+}
+
+// BigInt decodes Int, Neg or tag 2 or 3 bignum.
+// Other values are reported as ErrInvalid.
+func (d Decoder) BigInt(b []byte, st int) (x *big.Int, i int) {
+	x = new(big.Int)
+
+	i = d.bigInt(x, b, st)
+	if i < 0 {
+		return nil, i
+	}
+
+	return x, i
+}
+
+// BigFloat decodes integer, bignum, float, tag 4 decimal fraction or tag 5 bigfloat.
+// Decimal fractions are rounded to the mantissa precision, at least 64 bits.
+// Other values and NaN are reported as ErrInvalid.
+func (d Decoder) BigFloat(b []byte, st int) (x *big.Float, i int) {
+	raw := d.TagRaw(b, st)
+
+	if d.isFloat(raw) {
+		f, i := d.Float(b, st)
+		if i < 0 {
+			return nil, i
+		}
+
+		if math.IsNaN(f) {
+			return nil, newError(ErrInvalid, st)
+		}
+
+		return new(big.Float).SetFloat64(f), i
+	}
+
+	tag, num, i := d.Tag(b, st)
+	if i < 0 {
+		return nil, i
+	}
+
+	if tag != Labeled || num != TagDecimal && num != TagBigfloat {
+		var m big.Int
+
+		i = d.bigInt(&m, b, st)
+		if i < 0 {
+			return nil, i
+		}
+
+		return new(big.Float).SetInt(&m), i
+	}
+
+	tag, l, i := d.Tag(b, i)
+	if i >= 0 && (tag != Array || l != 2) {
+		return nil, newError(ErrInvalid, st)
+	}
+
+	exp, i := d.Signed(b, i)
+	if i < 0 {
+		return nil, i
+	}
+
+	var m big.Int
+
+	i = d.bigInt(&m, b, i)
+	if i < 0 {
+		return nil, i
+	}
+
+	x = new(big.Float).SetInt(&m)
+
+	if num == TagBigfloat {
+		if exp > math.MaxInt32 || exp < math.MinInt32 {
+			return nil, newError(ErrOverflow, st)
+		}
+
+		return x.SetMantExp(x, int(exp)), i
+	}
+
+	if exp > maxDecimalExp || exp < -maxDecimalExp {
+		return nil, newError(ErrLimit, st)
+	}
+
+	var p big.Int
+
+	p.Exp(big.NewInt(10), big.NewInt(csel(exp < 0, -exp, exp)), nil)
+
+	if exp >= 0 {
+		return x.SetInt(m.Mul(&m, &p)), i
+	}
+
+	return x.Quo(x, new(big.Float).SetInt(&p)), i
+}
+
+func (d Decoder) bigInt(x *big.Int, b []byte, st int) int {
+	tag, sub, i := d.Tag(b, st)
+	if i < 0 {
+		return i
+	}
+
+	switch tag {
+	case Int, Neg:
+		x.SetUint64(uint64(sub))
+	case Labeled:
+		if sub != TagPosBignum && sub != TagNegBignum || d.TagOnly(b, i) != Bytes {
+			return newError(ErrInvalid, st)
+		}
+
+		var s []byte
+
+		s, i = d.content(b, i)
+		if i < 0 {
+			return i
+		}
+
+		x.SetBytes(s)
+	default:
+		return newError(ErrInvalid, st)
+	}
+
+	if tag == Neg || tag == Labeled && sub == TagNegBignum {
+		x.Not(x) // -1 - x
+	}
+
+	return i
+}
+
+func encBigInt(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendBigInt(b, v.Interface().(*big.Int)), nil
+}
+
+func decBigInt(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	x, i := d.BigInt(b, st)
+	if i < 0 {
+		return st, Error(i)
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.ValueOf(x))
+	} else {
+		v.Interface().(*big.Int).Set(x)
+	}
+
+	return i, nil
+}
+
+func encBigFloat(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendBigFloat(b, v.Interface().(*big.Float)), nil
+}
+
+func decBigFloat(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	x, i := d.BigFloat(b, st)
+	if i < 0 {
+		return st, Error(i)
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.ValueOf(x))
+	} else {
+		v.Interface().(*big.Float).Set(x)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,209 @@
+package cbor
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestBigInt(tb *testing.T) {
+	var e Encoder
+
+	pow64 := new(big.Int).Lsh(big.NewInt(1), 64)
+
+	for _, tc := range []struct {
+		X    *big.Int
+		Diag string
+	}{
+		{big.NewInt(0), `0`},
+		{big.NewInt(-1), `-1`},
+		{new(big.Int).SetUint64(math.MaxUint64), `18446744073709551615`},
+		{pow64, `2(h'010000000000000000')`},
+		{new(big.Int).Neg(pow64), `-18446744073709551616`},
+		{new(big.Int).Not(pow64), `3(h'010000000000000000')`},
+		{nil, `null`},
+	} {
+		b := e.AppendBigInt(nil, tc.X)
+
+		if d := Diag(b); d != tc.Diag {
+			tb.Errorf("%v: encoded %v, wanted %v", tc.X, d, tc.Diag)
+		}
+
+		if tc.X == nil {
+			continue
+		}
+
+		x, i := MakeSafeDecoder().BigInt(b, 0)
+		if i != len(b) || x.Cmp(tc.X) != 0 {
+			tb.Errorf("%v: decoded %v %v", tc.X, x, Error(i))
+		}
+	}
+
+	b, _ := ParseDiag(`2((_ h'01', h'0000000000000000'))`)
+
+	x, i := Decoder{}.BigInt(b, 0)
+	if i != len(b) || x.Cmp(pow64) != 0 {
+		tb.Errorf("indefinite bytes: %v %v", x, Error(i))
+	}
+
+	for _, diag := range []string{`"1"`, `4(h'01')`, `2(1)`, `1.0`} {
+		b, _ := ParseDiag(diag)
+
+		if _, i := (Decoder{}).BigInt(b, 0); Error(i).Code() != ErrInvalid {
+			tb.Errorf("%v: expected invalid error, got %v", diag, Error(i))
+		}
+	}
+}
+
+func TestIntOverflow(tb *testing.T) {
+	var d Decoder
+
+	for _, tc := range []struct {
+		Diag string
+		Code int
+		Sign int64
+		Uns  uint64
+	}{
+		{`9223372036854775807`, ErrOK, math.MaxInt64, math.MaxInt64},
+		{`9223372036854775808`, ErrOverflow, 0, 1 << 63},
+		{`-9223372036854775808`, ErrOK, math.MinInt64, 1 << 63},
+		{`-9223372036854775809`, ErrOverflow, 0, 1<<63 + 1},
+		{`-18446744073709551616`, ErrOverflow, 0, 0},
+	} {
+		b, _ := ParseDiag(tc.Diag)
+
+		v, i := d.Signed(b, 0)
+		if Error(i).Code() != tc.Code || v != tc.Sign {
+			tb.Errorf("%v: signed %v %v", tc.Diag, v, Error(i))
+		}
+
+		u, i := d.Unsigned(b, 0)
+		if code := Error(i).Code(); (code == ErrOK) != (tc.Uns != 0) || u != tc.Uns {
+			tb.Errorf("%v: unsigned %v %v", tc.Diag, u, Error(i))
+		}
+	}
+}
+
+func TestBigFloat(tb *testing.T) {
+	var e Encoder
+
+	tiny := new(big.Float).SetMantExp(big.NewFloat(1), -1100)
+	precise := new(big.Float).SetPrec(200).SetInt64(1)
+	precise.Add(precise, new(big.Float).SetMantExp(big.NewFloat(1), -100))
+
+	for _, tc := range []struct {
+		X    *big.Float
+		Diag string
+	}{
+		{big.NewFloat(3), `3`},
+		{big.NewFloat(-1.5), `-1.5`},
+		{big.NewFloat(math.Inf(1)), `Infinity`},
+		{new(big.Float).SetMantExp(big.NewFloat(1), 70), `1.1805916207174113e+21`},
+		{tiny, `5([-1100, 1])`},
+		{precise, `5([-100, 2(h'10000000000000000000000001')])`},
+	} {
+		b := e.AppendBigFloat(nil, tc.X)
+
+		if d := Diag(b); d != tc.Diag {
+			tb.Errorf("%v: encoded %v, wanted %v", tc.X, d, tc.Diag)
+		}
+
+		x, i := MakeSafeDecoder().BigFloat(b, 0)
+		if i != len(b) || x.Cmp(tc.X) != 0 {
+			tb.Errorf("%v: decoded %v %v", tc.X, x, Error(i))
+		}
+	}
+
+	for _, tc := range []struct {
+		Diag string
+		Text string
+	}{
+		{`4([-2, 27315])`, "273.15"},
+		{`4([3, -2])`, "-2000"},
+		{`4([-1, 3(h'010000000000000000')])`, "-1844674407370955161.7"},
+		{`5([1, 3])`, "6"},
+		{`2(h'0100')`, "256"},
+	} {
+		b, err := ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		x, i := Decoder{}.BigFloat(b, 0)
+		if i != len(b) || x.Text('f', -1) != tc.Text {
+			tb.Errorf("%v: decoded %v %v, wanted %v", tc.Diag, x, Error(i), tc.Text)
+		}
+	}
+
+	for _, tc := range []struct {
+		Diag string
+		Code int
+	}{
+		{`NaN`, ErrInvalid},
+		{`4(1)`, ErrInvalid},
+		{`4([1, 2, 3])`, ErrInvalid},
+		{`4([100000, 1])`, ErrLimit},
+		{`5([9223372036854775807, 1])`, ErrOverflow},
+	} {
+		b, _ := ParseDiag(tc.Diag)
+
+		if _, i := (Decoder{}).BigFloat(b, 0); Error(i).Code() != tc.Code {
+			tb.Errorf("%v: error %v, wanted code %v", tc.Diag, Error(i), tc.Code)
+		}
+	}
+}
+
+func TestBigValue(tb *testing.T) {
+	type S struct {
+		I big.Int
+		P *big.Int
+		F big.Float
+	}
+
+	pow64 := new(big.Int).Lsh(big.NewInt(1), 64)
+	v := S{I: *big.NewInt(-5), P: pow64, F: *big.NewFloat(0.5)}
+
+	b, err := Marshal(v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	if d := Diag(b); d != `{"I": -5, "P": 2(h'010000000000000000'), "F": 0.5}` {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r S
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if r.I.Cmp(&v.I) != 0 || r.P == nil || r.P.Cmp(pow64) != 0 || r.F.Cmp(&v.F) != 0 {
+		tb.Errorf("decoded: %v %v %v", &r.I, r.P, &r.F)
+	}
+
+	b, _ = ParseDiag(`[2(h'010000000000000000'), -18446744073709551616]`)
+
+	var x any
+
+	err = Unmarshal(b, &x)
+	if err != nil {
+		tb.Fatalf("unmarshal any: %v", err)
+	}
+
+	exp := []any{pow64, new(big.Int).Neg(pow64)}
+
+	if !reflect.DeepEqual(exp, x) {
+		tb.Errorf("decoded any: %v", x)
+	}
+
+	y := big.NewInt(1)
+	p := y
+
+	err = Unmarshal(b[1:12], &y)
+	if err != nil || y != p || y.Cmp(pow64) != 0 {
+		tb.Errorf("unmarshal into pointer: %v %v", y, err)
+	}
+}
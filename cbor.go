@@ -3,9 +3,22 @@ package cbor
 type (
 	Tag byte
 
+	// Message is a validated zero-copy document.
+	// Container children offsets are indexed lazily on first access.
+	// It's not safe for concurrent use.
 	Message struct {
 		b    []byte
 		root int
+
+		d        Decoder
+		children map[int][]int // container offset -> children offsets; keys and values for maps
+	}
+
+	// Value is a data item inside a Message.
+	// Zero Value means the item doesn't exist.
+	Value struct {
+		m  *Message
+		st int
 	}
 )
 
@@ -59,7 +59,7 @@ func TestInt(tb *testing.T) {
 				tb.Errorf("%T(%#[1]v) -> %#x, wanted %#x", tc.Data, b, tc.Encoded)
 			}
 
-			dec, i := d.Signed(b, 0)
+			dec, i := d.Unsigned(b, 0)
 			if i != len(b) || uint(dec) != uint(tc.Data) {
 				tb.Errorf("%d -> %d,  i %#x / %#x", tc.Data, dec, i, len(b))
 			}
@@ -172,7 +172,9 @@ func TestFloat(tb *testing.T) {
 		{100000, []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}},
 		{3.4028234663852886e+38, []byte{0xfa, 0x7f, 0x7f, 0xff, 0xff}},
 		{1.0e+300, []byte{0xfb, 0x7e, 0x37, 0xe4, 0x3c, 0x88, 0x00, 0x75, 0x9c}},
-		//	{5.960464477539063e-8, []byte{0xf9, 0x00, 0x01}}, // wtf?
+		{5.960464477539063e-8, []byte{0xf9, 0x00, 0x01}},
+		{-6.097555160522461e-05, []byte{0xf9, 0x83, 0xff}},
+		{65536, []byte{0xfa, 0x47, 0x80, 0x00, 0x00}},
 		{0.00006103515625, []byte{0xf9, 0x04, 0x00}},
 		{-4, []byte{0xf9, 0xc4, 0x00}},
 		{-4.1, []byte{0xfb, 0xc0, 0x10, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66}},
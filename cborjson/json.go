@@ -0,0 +1,577 @@
+// Package cborjson converts CBOR to JSON and back
+// following RFC 8949 Section 6 conversion rules.
+//
+// Conversion works on bytes directly without intermediate Go values.
+package cborjson
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"nikand.dev/go/cbor"
+)
+
+const maxDepth = 10000
+
+var (
+	ErrSyntax    = errors.New("json syntax error")
+	ErrDepth     = errors.New("max depth exceeded")
+	ErrExtraData = errors.New("extra data after the value")
+)
+
+// ToJSON converts CBOR data item b into JSON and appends it to w.
+//
+// Byte strings are encoded as base64url without padding
+// unless tags 22 (base64) or 23 (base16) are in effect.
+// Non-finite floats, undefined and simple values are converted into null.
+// Other tags are dropped except bignums (tags 2 and 3) which become JSON integers.
+// Non-string map keys are converted into strings of their JSON representation.
+// Nesting deeper than FromJSON accepts is reported as ErrDepth.
+func ToJSON(w, b []byte) ([]byte, error) {
+	d := cbor.MakeDecoder()
+	d.Limits.MaxDepth = maxDepth
+
+	i := d.Validate(b, 0)
+	if i < 0 && cbor.Error(i).Code() == cbor.ErrLimit {
+		return w, fmt.Errorf("at %d: %w", cbor.Error(i).Index(), ErrDepth)
+	}
+	if i < 0 {
+		return w, cbor.Error(i)
+	}
+
+	if i != len(b) {
+		return w, fmt.Errorf("at %d: %w", i, ErrExtraData)
+	}
+
+	w, _ = toJSON(d, w, b, 0, cbor.TagBase64URL)
+
+	return w, nil
+}
+
+func toJSON(d cbor.Decoder, w, b []byte, st int, enc int64) (_ []byte, i int) {
+	tag, sub, i := d.Tag(b, st)
+
+	switch tag {
+	case cbor.Int:
+		w = strconv.AppendUint(w, uint64(sub), 10)
+	case cbor.Neg:
+		if uint64(sub) == math.MaxUint64 {
+			w = append(w, "-18446744073709551616"...)
+		} else {
+			w = append(w, '-')
+			w = strconv.AppendUint(w, uint64(sub)+1, 10)
+		}
+	case cbor.Bytes:
+		var s []byte
+		s, i = stringValue(d, b, st)
+
+		w = append(w, '"')
+		w = appendEncoded(w, s, enc)
+		w = append(w, '"')
+	case cbor.String:
+		var s []byte
+		s, i = stringValue(d, b, st)
+
+		w = appendString(w, s)
+	case cbor.Array:
+		w = append(w, '[')
+
+		for n := 0; sub < 0 && !d.Break(b, &i) || n < int(sub); n++ {
+			if n != 0 {
+				w = append(w, ',')
+			}
+
+			w, i = toJSON(d, w, b, i, enc)
+		}
+
+		w = append(w, ']')
+	case cbor.Map:
+		w = append(w, '{')
+
+		for n := 0; sub < 0 && !d.Break(b, &i) || n < int(sub); n++ {
+			if n != 0 {
+				w = append(w, ',')
+			}
+
+			kst := len(w)
+			w, i = toJSON(d, w, b, i, enc)
+
+			if w[kst] != '"' {
+				k := string(w[kst:])
+				w = appendString(w[:kst], []byte(k))
+			}
+
+			w = append(w, ':')
+			w, i = toJSON(d, w, b, i, enc)
+		}
+
+		w = append(w, '}')
+	case cbor.Labeled:
+		switch {
+		case (sub == cbor.TagPosBignum || sub == cbor.TagNegBignum) && d.TagOnly(b, i) == cbor.Bytes:
+			var s []byte
+			s, i = stringValue(d, b, i)
+
+			x := new(big.Int).SetBytes(s)
+
+			if sub == cbor.TagNegBignum {
+				x.Neg(x).Sub(x, big.NewInt(1))
+			}
+
+			w = x.Append(w, 10)
+		case sub >= cbor.TagBase64URL && sub <= cbor.TagBase16:
+			w, i = toJSON(d, w, b, i, sub)
+		default:
+			w, i = toJSON(d, w, b, i, enc)
+		}
+	case cbor.Simple:
+		raw := d.TagRaw(b, st)
+
+		switch {
+		case raw == cbor.Simple|cbor.False:
+			w = append(w, "false"...)
+		case raw == cbor.Simple|cbor.True:
+			w = append(w, "true"...)
+		case raw == cbor.Simple|cbor.Float8 && !d.Flags.Is(cbor.FtFloat8Int):
+			w = append(w, "null"...)
+		case cbor.IsFloat(raw):
+			f, _ := d.Float(b, st)
+
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				w = append(w, "null"...)
+				break
+			}
+
+			w = strconv.AppendFloat(w, f, 'g', -1, csel(raw < cbor.Simple|cbor.Float64, 32, 64))
+		default:
+			w = append(w, "null"...)
+		}
+	}
+
+	return w, i
+}
+
+// stringValue returns string contents concatenating indefinite length chunks.
+func stringValue(d cbor.Decoder, b []byte, st int) (s []byte, i int) {
+	if b[st]&cbor.SubMask != cbor.LenBreak {
+		return d.Bytes(b, st)
+	}
+
+	_, _, i = d.Tag(b, st)
+
+	for !d.Break(b, &i) {
+		var ch []byte
+
+		ch, i = d.Bytes(b, i)
+		s = append(s, ch...)
+	}
+
+	return s, i
+}
+
+func appendEncoded(w, s []byte, enc int64) []byte {
+	var e *base64.Encoding
+
+	switch enc {
+	case cbor.TagBase16:
+		return append(w, hex.EncodeToString(s)...)
+	case cbor.TagBase64:
+		e = base64.StdEncoding
+	default:
+		e = base64.RawURLEncoding
+	}
+
+	l := len(w)
+	n := e.EncodedLen(len(s))
+
+	w = append(w, make([]byte, n)...)
+	e.Encode(w[l:], s)
+
+	return w
+}
+
+func appendString(w, s []byte) []byte {
+	const hexdig = "0123456789abcdef"
+
+	w = append(w, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				w = append(w, "\ufffd"...)
+			} else {
+				w = append(w, s[i:i+size]...)
+			}
+
+			i += size
+
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\\':
+			w = append(w, '\\', c)
+		case c == '\n':
+			w = append(w, '\\', 'n')
+		case c == '\r':
+			w = append(w, '\\', 'r')
+		case c == '\t':
+			w = append(w, '\\', 't')
+		case c < 0x20:
+			w = append(w, '\\', 'u', '0', '0', hexdig[c>>4], hexdig[c&0xf])
+		default:
+			w = append(w, c)
+		}
+
+		i++
+	}
+
+	return append(w, '"')
+}
+
+type parser struct {
+	e cbor.Encoder
+	j []byte
+	i int
+}
+
+// FromJSON converts JSON value j into CBOR and appends it to b.
+//
+// Integers are encoded as CBOR integers or bignums (tags 2 and 3) if they don't fit into 64 bits.
+// Numbers with fraction or exponent are encoded as floats in the preferred (shortest) form.
+// Arrays and objects are encoded with definite length.
+func FromJSON(b, j []byte) ([]byte, error) {
+	p := parser{
+		e: cbor.Encoder{Flags: cbor.FtCompatible},
+		j: j,
+	}
+
+	b, err := p.value(b, 0)
+	if err != nil {
+		return b, err
+	}
+
+	p.ws()
+
+	if p.i != len(p.j) {
+		return b, fmt.Errorf("at %d: %w", p.i, ErrExtraData)
+	}
+
+	return b, nil
+}
+
+func (p *parser) value(b []byte, depth int) (_ []byte, err error) {
+	if depth > maxDepth {
+		return b, fmt.Errorf("at %d: %w", p.i, ErrDepth)
+	}
+
+	p.ws()
+
+	if p.i == len(p.j) {
+		return b, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.j[p.i]; {
+	case c == '{':
+		p.i++
+		return p.container(b, cbor.Map, '}', depth)
+	case c == '[':
+		p.i++
+		return p.container(b, cbor.Array, ']', depth)
+	case c == '"':
+		st := len(b)
+		b = append(b, byte(cbor.String))
+
+		b, err = p.string(b)
+		if err != nil {
+			return b, err
+		}
+
+		return fixLen(p.e, b, cbor.String, st, len(b)-st-1), nil
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number(b)
+	case p.lit("true"):
+		return p.e.AppendBool(b, true), nil
+	case p.lit("false"):
+		return p.e.AppendBool(b, false), nil
+	case p.lit("null"):
+		return p.e.AppendNull(b), nil
+	}
+
+	return b, p.errorf("unexpected %q", p.j[p.i])
+}
+
+func (p *parser) container(b []byte, tag cbor.Tag, end byte, depth int) (_ []byte, err error) {
+	st := len(b)
+	b = append(b, byte(tag))
+
+	n := 0
+
+	for ; ; n++ {
+		p.ws()
+
+		if p.i < len(p.j) && p.j[p.i] == end {
+			p.i++
+			break
+		}
+
+		if n != 0 {
+			if p.i == len(p.j) || p.j[p.i] != ',' {
+				return b, p.errorf("comma or %q expected", end)
+			}
+
+			p.i++
+			p.ws()
+		}
+
+		if tag == cbor.Map {
+			if p.i == len(p.j) || p.j[p.i] != '"' {
+				return b, p.errorf("object key expected")
+			}
+
+			b, err = p.value(b, depth+1)
+			if err != nil {
+				return b, err
+			}
+
+			p.ws()
+
+			if p.i == len(p.j) || p.j[p.i] != ':' {
+				return b, p.errorf("colon expected")
+			}
+
+			p.i++
+		}
+
+		b, err = p.value(b, depth+1)
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return fixLen(p.e, b, tag, st, n), nil
+}
+
+func (p *parser) string(b []byte) ([]byte, error) {
+	st := p.i
+	p.i++
+
+	for p.i < len(p.j) {
+		c := p.j[p.i]
+		p.i++
+
+		switch {
+		case c == '"':
+			return b, nil
+		case c < 0x20:
+			p.i--
+			return b, p.errorf("control character in string")
+		case c != '\\':
+			b = append(b, c)
+			continue
+		case p.i == len(p.j):
+			continue
+		}
+
+		c = p.j[p.i]
+		p.i++
+
+		switch c {
+		case '"', '\\', '/':
+			b = append(b, c)
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r, ok := p.hex4()
+			if !ok {
+				return b, p.errorf("bad \\u escape")
+			}
+
+			if utf16.IsSurrogate(r) && p.i+1 < len(p.j) && p.j[p.i] == '\\' && p.j[p.i+1] == 'u' {
+				p.i += 2
+
+				r2, ok := p.hex4()
+				if !ok {
+					return b, p.errorf("bad \\u escape")
+				}
+
+				r = utf16.DecodeRune(r, r2)
+			}
+
+			b = utf8.AppendRune(b, r)
+		default:
+			p.i--
+			return b, p.errorf("bad escape")
+		}
+	}
+
+	p.i = st
+
+	return b, p.errorf("unterminated string")
+}
+
+func (p *parser) hex4() (rune, bool) {
+	if p.i+4 > len(p.j) {
+		return 0, false
+	}
+
+	x, err := strconv.ParseUint(string(p.j[p.i:p.i+4]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	p.i += 4
+
+	return rune(x), true
+}
+
+func (p *parser) number(b []byte) ([]byte, error) {
+	st := p.i
+	isFloat := false
+
+	if p.j[p.i] == '-' {
+		p.i++
+	}
+
+	digits := func() int {
+		s := p.i
+
+		for p.i < len(p.j) && p.j[p.i] >= '0' && p.j[p.i] <= '9' {
+			p.i++
+		}
+
+		return p.i - s
+	}
+
+	if n := digits(); n == 0 || n > 1 && p.j[p.i-n] == '0' {
+		return b, p.errorf("bad number")
+	}
+
+	if p.i < len(p.j) && p.j[p.i] == '.' {
+		p.i++
+		isFloat = true
+
+		if digits() == 0 {
+			return b, p.errorf("bad number")
+		}
+	}
+
+	if p.i < len(p.j) && (p.j[p.i] == 'e' || p.j[p.i] == 'E') {
+		p.i++
+		isFloat = true
+
+		if p.i < len(p.j) && (p.j[p.i] == '+' || p.j[p.i] == '-') {
+			p.i++
+		}
+
+		if digits() == 0 {
+			return b, p.errorf("bad number")
+		}
+	}
+
+	num := string(p.j[st:p.i])
+	neg := num[0] == '-'
+
+	if isFloat {
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			p.i = st
+			return b, p.errorf("bad number: %v", err)
+		}
+
+		return p.e.AppendFloat(b, f), nil
+	}
+
+	if v, err := strconv.ParseUint(num[csel(neg, 1, 0):], 10, 64); err == nil {
+		if neg && v != 0 {
+			return p.e.AppendTag64(b, cbor.Neg, v-1), nil
+		}
+
+		return p.e.AppendTag64(b, cbor.Int, v), nil
+	}
+
+	x, _ := new(big.Int).SetString(num, 10)
+
+	if neg && x.Cmp(minInt65) == 0 {
+		return p.e.AppendTag64(b, cbor.Neg, math.MaxUint64), nil
+	}
+
+	if neg {
+		x.Neg(x).Sub(x, big.NewInt(1))
+
+		b = p.e.AppendLabeled(b, cbor.TagNegBignum)
+	} else {
+		b = p.e.AppendLabeled(b, cbor.TagPosBignum)
+	}
+
+	return p.e.AppendBytes(b, x.Bytes()), nil
+}
+
+// minInt65 is -2^64, the smallest CBOR integer.
+var minInt65 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64))
+
+func (p *parser) lit(s string) bool {
+	if len(p.j)-p.i < len(s) || string(p.j[p.i:p.i+len(s)]) != s {
+		return false
+	}
+
+	p.i += len(s)
+
+	return true
+}
+
+func (p *parser) ws() {
+	for p.i < len(p.j) {
+		switch p.j[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %w: %s", p.i, ErrSyntax, fmt.Sprintf(format, args...))
+}
+
+// fixLen replaces one byte head at st with the head of length l.
+func fixLen(e cbor.Encoder, b []byte, tag cbor.Tag, st, l int) []byte {
+	sz := e.TagSize(l)
+	if sz == 1 {
+		b[st] = byte(tag) | byte(l)
+		return b
+	}
+
+	b = append(b, make([]byte, sz-1)...)
+	copy(b[st+sz:], b[st+1:])
+	_ = e.AppendTag(b[:st], tag, l)
+
+	return b
+}
+
+func csel[T any](cond bool, t, f T) T {
+	if cond {
+		return t
+	}
+
+	return f
+}
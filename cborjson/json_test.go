@@ -0,0 +1,138 @@
+package cborjson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"nikand.dev/go/cbor"
+)
+
+func TestToJSON(tb *testing.T) {
+	for _, tc := range []struct {
+		Diag string
+		JSON string
+	}{
+		{`0`, `0`},
+		{`-18446744073709551616`, `-18446744073709551616`},
+		{`18446744073709551615`, `18446744073709551615`},
+		{`1.5`, `1.5`},
+		{`0.10000000149011612_2`, `0.1`},
+		{`1.0e+300`, `1e+300`},
+		{`NaN`, `null`},
+		{`-Infinity`, `null`},
+		{`[true, false, null, undefined, simple(16)]`, `[true,false,null,null,null]`},
+		{`h'fbff'`, `"-_8"`},
+		{`22(h'fbff')`, `"+/8="`},
+		{`23([h'fbff', {"a": h'01'}])`, `["fbff",{"a":"01"}]`},
+		{`21(23(h'01'))`, `"01"`},
+		{`(_ h'fb', h'ff')`, `"-_8"`},
+		{`(_ "a", "b")`, `"ab"`},
+		{`"\"\\\n\u0001ü"`, `"\"\\\n\u0001ü"`},
+		{`{_ 1: 2, "k": [_ ], h'01': {}, [1, "x"]: 0, 1.5: 1}`, `{"1":2,"k":[],"AQ":{},"[1,\"x\"]":0,"1.5":1}`},
+		{`2(h'010000000000000000')`, `18446744073709551616`},
+		{`3(h'010000000000000000')`, `-18446744073709551617`},
+		{`1(1363896240)`, `1363896240`},
+	} {
+		b, err := cbor.ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Fatalf("%v: %v", tc.Diag, err)
+		}
+
+		j, err := ToJSON(nil, b)
+		if err != nil {
+			tb.Errorf("%v: %v", tc.Diag, err)
+			continue
+		}
+
+		if string(j) != tc.JSON {
+			tb.Errorf("%v: %s, wanted %s", tc.Diag, j, tc.JSON)
+		}
+	}
+}
+
+func TestFromJSON(tb *testing.T) {
+	long := `"` + string(bytes.Repeat([]byte("x"), 300)) + `"`
+
+	for _, tc := range []struct {
+		JSON string
+		Diag string
+	}{
+		{`0`, `0`},
+		{` -0 `, `0`},
+		{`-1`, `-1`},
+		{`18446744073709551615`, `18446744073709551615`},
+		{`-18446744073709551616`, `-18446744073709551616`},
+		{`18446744073709551616`, `2(h'010000000000000000')`},
+		{`-18446744073709551617`, `3(h'010000000000000000')`},
+		{`1.5`, `1.5`},
+		{`1.0`, `1.0`},
+		{`1e300`, `1.0e+300`},
+		{`[true, false, null]`, `[true, false, null]`},
+		{`{"a": [1, {"b": "c"}], "": {}}`, `{"a": [1, {"b": "c"}], "": {}}`},
+		{`"\"\\\/\b\f\n\r\tü𐅑"`, `"\"\\/\u0008\u000c\n\r\t\u00fc\ud800\udd51"`},
+		{long, long},
+	} {
+		b, err := FromJSON(nil, []byte(tc.JSON))
+		if err != nil {
+			tb.Errorf("%v: %v", tc.JSON, err)
+			continue
+		}
+
+		if d := cbor.Diag(b); d != tc.Diag {
+			tb.Errorf("%v: %v, wanted %v", tc.JSON, d, tc.Diag)
+		}
+	}
+}
+
+func TestJSONRoundtrip(tb *testing.T) {
+	j := []byte(`{"arr":[1,-2,3.25,"str",[],{}],"big":123456789012345678901234567890,"n":null,"t":true}`)
+
+	b, err := FromJSON(nil, j)
+	if err != nil {
+		tb.Fatalf("from: %v", err)
+	}
+
+	r, err := ToJSON(nil, b)
+	if err != nil {
+		tb.Fatalf("to: %v", err)
+	}
+
+	if !bytes.Equal(j, r) {
+		tb.Errorf("roundtrip\n%s\n%s", j, r)
+	}
+}
+
+func TestJSONErrors(tb *testing.T) {
+	for _, j := range []string{
+		``, `[`, `[1,]`, `{"a"}`, `{1: 2}`, `01`, `1.`, `1e`, `-`, `"abc`, `"\x"`, "\"\x01\"",
+		`tru`, `[1 2]`, `1 2`, `"\u12"`,
+	} {
+		_, err := FromJSON(nil, []byte(j))
+		if err == nil {
+			tb.Errorf("%q: expected error", j)
+		}
+	}
+
+	_, err := ToJSON(nil, []byte{0x82, 0x01})
+	if !errors.As(err, new(cbor.Error)) {
+		tb.Errorf("truncated: %v", err)
+	}
+
+	_, err = ToJSON(nil, []byte{0x01, 0x02})
+	if !errors.Is(err, ErrExtraData) {
+		tb.Errorf("extra data: %v", err)
+	}
+
+	deep := append(bytes.Repeat([]byte{0x81}, maxDepth+1), 0x01)
+
+	_, err = ToJSON(nil, deep)
+	if !errors.Is(err, ErrDepth) {
+		tb.Errorf("deep: %v", err)
+	}
+
+	_, err = ToJSON(nil, deep[1:])
+	if err != nil {
+		tb.Errorf("max depth: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package cddl implements Concise Data Definition Language (RFC 8610)
+// schema parser and CBOR data validator.
+//
+// Supported are type and group rules with choices, /= and //= extensions,
+// sockets and plugs, generics, occurrence indicators, member keys with cuts,
+// ranges, unwrapping, enumerations, major type and tag constraints,
+// and .size, .bits, .regexp, .cbor, .lt, .le, .gt, .ge, .eq, .ne, .default,
+// .within and .and controls. The standard prelude is always available.
+//
+// Map entries are matched greedily in order, without backtracking.
+//
+// Schema.Shape describes type rules in terms of plain data types for code generators.
+package cddl
+
+import (
+	"errors"
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// Schema is a parsed CDDL specification.
+	// It's safe for concurrent use.
+	Schema struct {
+		rules map[string]*rule
+		names []string // in definition order
+		root  string
+	}
+
+	// Violation is returned when data doesn't match the schema.
+	// Err is ErrInvalid code with the offset of the offending item.
+	// It's the furthest offset data matched to,
+	// and the innermost failed rule at that offset.
+	Violation struct {
+		Rule string // named rule being matched
+		Err  cbor.Error
+		Msg  string
+	}
+)
+
+var (
+	ErrSyntax    = errors.New("cddl syntax error")
+	ErrUndefined = errors.New("undefined rule")
+	ErrExtraData = errors.New("extra data after the item")
+
+	ErrUnsupported = errors.New("unsupported by shape")
+)
+
+const prelude = `
+any = #
+
+uint = #0
+nint = #1
+int = uint / nint
+
+bstr = #2
+bytes = bstr
+tstr = #3
+text = tstr
+
+tdate = #6.0(tstr)
+time = #6.1(number)
+number = int / float
+biguint = #6.2(bstr)
+bignint = #6.3(bstr)
+bigint = biguint / bignint
+integer = int / bigint
+unsigned = uint / biguint
+decfrac = #6.4([e10: int, m: integer])
+bigfloat = #6.5([e2: int, m: integer])
+eb64url = #6.21(any)
+eb64legacy = #6.22(any)
+eb16 = #6.23(any)
+encoded-cbor = #6.24(bstr)
+uri = #6.32(tstr)
+b64url = #6.33(tstr)
+b64legacy = #6.34(tstr)
+regexp = #6.35(tstr)
+mime-message = #6.36(tstr)
+cbor-any = #6.55799(any)
+
+float16 = #7.25
+float32 = #7.26
+float64 = #7.27
+float16-32 = float16 / float32
+float32-64 = float32 / float64
+float = float16-32 / float64
+
+false = #7.20
+true = #7.21
+bool = false / true
+nil = #7.22
+null = nil
+undefined = #7.23
+`
+
+// Parse parses CDDL specification.
+// The first rule is the root one used by Validate.
+// All the referenced names must be defined except sockets, which are names starting with $.
+func Parse(s string) (*Schema, error) {
+	sc := &Schema{rules: map[string]*rule{}}
+
+	p := parser{s: s}
+
+	err := p.rules(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.root == "" {
+		return nil, fmt.Errorf("%w: no rules", ErrSyntax)
+	}
+
+	for name, r := range preludeRules {
+		if sc.rules[name] == nil {
+			sc.rules[name] = r
+		}
+	}
+
+	for _, r := range sc.rules {
+		err = sc.checkGroup(r.g, r.params)
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %w", r.name, err)
+		}
+	}
+
+	return sc, nil
+}
+
+// Validate checks b is exactly one data item matching the root rule.
+func (s *Schema) Validate(b []byte) error {
+	i, err := s.ValidateAt(cbor.Decoder{}, b, 0, "")
+	if err != nil {
+		return err
+	}
+
+	if i != len(b) {
+		return fmt.Errorf("at %d: %w", i, ErrExtraData)
+	}
+
+	return nil
+}
+
+// ValidateAt checks the data item at st matches the named rule, or the root rule if name is empty.
+// It returns the end of the item.
+// Malformed data is reported as cbor.Error, schema mismatch as *Violation.
+func (s *Schema) ValidateAt(d cbor.Decoder, b []byte, st int, name string) (i int, err error) {
+	if name == "" {
+		name = s.root
+	}
+
+	r := s.rules[name]
+	if r == nil {
+		return st, fmt.Errorf("%w: %v", ErrUndefined, name)
+	}
+
+	t := asType(r.g)
+	if t == nil || len(r.params) != 0 {
+		return st, fmt.Errorf("rule %v: not a type", name)
+	}
+
+	d.Flags |= cbor.FtSafe
+
+	i = d.Validate(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	v := validator{s: s, d: d, b: b, rule: name}
+
+	if !v.typ(t, nil, st) || v.abort {
+		return st, v.violation(st)
+	}
+
+	return i, nil
+}
+
+func (s *Schema) add(r *rule) {
+	if s.root == "" {
+		s.root = r.name
+	}
+
+	s.rules[r.name] = r
+	s.names = append(s.names, r.name)
+}
+
+func (s *Schema) checkGroup(g *group, params []string) (err error) {
+	for _, ch := range g.choices {
+		for _, e := range ch {
+			if e.key != nil {
+				err = s.checkType1(e.key, params)
+			}
+
+			if err == nil && e.t != nil {
+				err = s.checkType(e.t, params)
+			}
+
+			if err == nil && e.g != nil {
+				err = s.checkGroup(e.g, params)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) checkType(t *typ, params []string) error {
+	for _, t1 := range t.choices {
+		err := s.checkType1(t1, params)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) checkType1(t1 *type1, params []string) error {
+	err := s.checkType2(t1.t, params)
+	if err == nil && t1.arg != nil {
+		err = s.checkType2(t1.arg, params)
+	}
+
+	return err
+}
+
+func (s *Schema) checkType2(t2 *type2, params []string) error {
+	if t2.name != "" && t2.name[0] != '$' && !contains(params, t2.name) {
+		r := s.rules[t2.name]
+		if r == nil {
+			return fmt.Errorf("%w: %v", ErrUndefined, t2.name)
+		}
+
+		if len(r.params) != len(t2.args) {
+			return fmt.Errorf("%v: %d generic arguments expected, got %d", t2.name, len(r.params), len(t2.args))
+		}
+	}
+
+	for _, a := range t2.args {
+		err := s.checkType(a, params)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t2.t != nil {
+		return s.checkType(t2.t, params)
+	}
+
+	if t2.g != nil {
+		return s.checkGroup(t2.g, params)
+	}
+
+	return nil
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%v: %v: %v", v.Err, v.Rule, v.Msg)
+}
+
+func (v *Violation) Unwrap() error { return v.Err }
+
+var preludeRules = func() map[string]*rule {
+	s := &Schema{rules: map[string]*rule{}}
+	p := parser{s: prelude}
+
+	if err := p.rules(s); err != nil {
+		panic(err)
+	}
+
+	return s.rules
+}()
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,959 @@
+package cddl
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	parser struct {
+		s string
+		i int
+	}
+
+	rule struct {
+		name   string
+		params []string
+		g      *group // type rules are groups of a single type entry
+	}
+
+	group struct {
+		choices [][]*entry
+	}
+
+	entry struct {
+		min, max int // occurrence, max < 0 means unbounded
+
+		key *type1 // member key, nil if none
+		cut bool
+
+		t *typ   // entry type, nil for parenthesized groups
+		g *group // parenthesized group
+
+		src string
+	}
+
+	// typ is a type choice.
+	typ struct {
+		choices []*type1
+		src     string
+	}
+
+	type1 struct {
+		t   *type2
+		op  string // range operator or control name
+		arg *type2 // range upper bound or controller
+
+		re *regexp.Regexp // compiled .regexp controller
+	}
+
+	type2 struct {
+		kind kind
+
+		v    value  // kindValue
+		name string // kindName, kindUnwrap, kindEnum with a name
+		args []*typ // generic arguments
+
+		t *typ   // kindParen, kindMajor tag content
+		g *group // kindMap, kindArray, kindEnum with a group
+
+		major  int // kindMajor, -1 for any item
+		num    uint64
+		hasNum bool
+	}
+
+	kind int
+
+	value struct {
+		kind valueKind
+
+		neg bool   // int is Neg: -1-u
+		u   uint64 // int argument
+		f   float64
+		s   string // text or bytes
+	}
+
+	valueKind int
+)
+
+const (
+	kindValue kind = iota
+	kindName
+	kindParen
+	kindMap
+	kindArray
+	kindUnwrap
+	kindEnum
+	kindMajor
+)
+
+const (
+	valueInt valueKind = iota
+	valueFloat
+	valueText
+	valueBytes
+)
+
+var controls = map[string]bool{
+	"size": true, "bits": true, "regexp": true, "cbor": true,
+	"lt": true, "le": true, "gt": true, "ge": true, "eq": true, "ne": true,
+	"default": true, "within": true, "and": true,
+}
+
+func (p *parser) rules(s *Schema) (err error) {
+	for {
+		p.ws()
+
+		if p.i == len(p.s) {
+			return nil
+		}
+
+		name := p.id()
+		if name == "" {
+			return p.errorf("rule name expected")
+		}
+
+		var params []string
+
+		if p.skip("<") {
+			for len(params) == 0 || p.skip(",") {
+				p.ws()
+
+				id := p.id()
+				if id == "" {
+					return p.errorf("generic parameter expected")
+				}
+
+				params = append(params, id)
+
+				p.ws()
+			}
+
+			if !p.skip(">") {
+				return p.errorf("> expected")
+			}
+		}
+
+		p.ws()
+
+		r := s.rules[name]
+
+		switch {
+		case p.skip("//="):
+			g, err := p.group(0)
+			if err != nil {
+				return err
+			}
+
+			if r == nil {
+				r = &rule{name: name, params: params, g: &group{}}
+				s.add(r)
+			}
+
+			r.g.choices = append(r.g.choices, g.choices...)
+		case p.skip("/="):
+			p.ws()
+
+			st := p.i
+
+			t, err := p.typ()
+			if err != nil {
+				return err
+			}
+
+			if r == nil {
+				r = &rule{name: name, params: params, g: &group{choices: [][]*entry{{{min: 1, max: 1, t: &typ{}}}}}}
+				s.add(r)
+			}
+
+			rt := asType(r.g)
+			if rt == nil {
+				p.i = st
+				return p.errorf("%v is not a type", name)
+			}
+
+			rt.choices = append(rt.choices, t.choices...)
+			rt.src = strings.TrimPrefix(rt.src+" / "+t.src, " / ")
+		case p.skip("=") && !p.at(">"):
+			if r != nil {
+				return p.errorf("%v redefined", name)
+			}
+
+			g, err := p.group(0)
+			if err != nil {
+				return err
+			}
+
+			if len(g.choices) == 1 && len(g.choices[0]) == 0 {
+				return p.errorf("type or group expected")
+			}
+
+			s.add(&rule{name: name, params: params, g: g})
+		default:
+			return p.errorf("assignment expected")
+		}
+	}
+}
+
+// group parses group choices till the closing bracket or the next rule if end is 0.
+func (p *parser) group(end byte) (*group, error) {
+	g := &group{}
+
+	for {
+		var ch []*entry
+
+		for {
+			p.ws()
+
+			if p.i == len(p.s) || strings.IndexByte(")]}", p.s[p.i]) >= 0 || p.at("//") || end == 0 && p.atRule() {
+				break
+			}
+
+			e, err := p.entry()
+			if err != nil {
+				return nil, err
+			}
+
+			ch = append(ch, e)
+
+			p.ws()
+			p.skip(",")
+		}
+
+		g.choices = append(g.choices, ch)
+
+		if p.at("//=") || !p.skip("//") {
+			break
+		}
+	}
+
+	if end != 0 && !p.skip(string(end)) {
+		return nil, p.errorf("%c expected", end)
+	}
+
+	return g, nil
+}
+
+func (p *parser) entry() (e *entry, err error) {
+	st := p.i
+	e = &entry{min: 1, max: 1}
+
+	defer func() {
+		if e != nil {
+			e.src = strings.TrimSpace(p.s[st:p.i])
+		}
+	}()
+
+	err = p.occur(e)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ws()
+
+	if k, ok := p.colonKey(); ok {
+		e.key, e.cut = k, true
+
+		p.ws()
+
+		e.t, err = p.typ()
+
+		return e, err
+	}
+
+	if p.at("(") {
+		gst := p.i
+		p.i++
+
+		g, err := p.group(')')
+		if err == nil {
+			end := p.i
+
+			p.ws()
+
+			if !p.at("=>") && !p.at("^") && !p.at(".") && (!p.at("/") || p.at("//")) {
+				p.i = end
+				e.g = g
+
+				return e, nil
+			}
+		}
+
+		p.i = gst
+	}
+
+	tst := p.i
+
+	t1, err := p.type1()
+	if err != nil {
+		return nil, err
+	}
+
+	p.ws()
+
+	cut := p.skip("^")
+	if cut {
+		p.ws()
+	}
+
+	if p.skip("=>") {
+		e.key, e.cut = t1, cut
+
+		p.ws()
+
+		e.t, err = p.typ()
+
+		return e, err
+	}
+
+	if cut {
+		return nil, p.errorf("=> expected")
+	}
+
+	e.t, err = p.typRest(tst, t1)
+
+	return e, err
+}
+
+func (p *parser) occur(e *entry) error {
+	switch {
+	case p.skip("?"):
+		e.min, e.max = 0, 1
+		return nil
+	case p.skip("+"):
+		e.min, e.max = 1, -1
+		return nil
+	}
+
+	st := p.i
+
+	min, minok := p.uint64()
+	if !p.skip("*") {
+		p.i = st
+		return nil
+	}
+
+	max, maxok := p.uint64()
+
+	if minok && min > math.MaxInt32 || maxok && max > math.MaxInt32 {
+		p.i = st
+		return p.errorf("bad occurrence")
+	}
+
+	e.min, e.max = 0, -1
+
+	if minok {
+		e.min = int(min)
+	}
+
+	if maxok {
+		e.max = int(max)
+	}
+
+	if e.max >= 0 && e.max < e.min {
+		return p.errorf("bad occurrence")
+	}
+
+	return nil
+}
+
+// colonKey parses bareword or value key followed by colon.
+func (p *parser) colonKey() (*type1, bool) {
+	st := p.i
+
+	if id := p.id(); id != "" {
+		p.ws()
+
+		if p.skip(":") {
+			return &type1{t: &type2{kind: kindValue, v: value{kind: valueText, s: id}}}, true
+		}
+
+		p.i = st
+
+		return nil, false
+	}
+
+	if !p.atValue() {
+		return nil, false
+	}
+
+	v, err := p.value()
+	if err == nil {
+		p.ws()
+
+		if p.skip(":") {
+			return &type1{t: &type2{kind: kindValue, v: v}}, true
+		}
+	}
+
+	p.i = st
+
+	return nil, false
+}
+
+func (p *parser) typ() (*typ, error) {
+	st := p.i
+
+	t1, err := p.type1()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.typRest(st, t1)
+}
+
+func (p *parser) typRest(st int, t1 *type1) (*typ, error) {
+	t := &typ{choices: []*type1{t1}}
+
+	for {
+		end := p.i
+
+		p.ws()
+
+		if !p.at("/") || p.at("//") || p.at("/=") {
+			p.i = end
+			break
+		}
+
+		p.i++
+		p.ws()
+
+		t1, err := p.type1()
+		if err != nil {
+			return nil, err
+		}
+
+		t.choices = append(t.choices, t1)
+	}
+
+	t.src = strings.TrimSpace(p.s[st:p.i])
+
+	return t, nil
+}
+
+func (p *parser) type1() (t1 *type1, err error) {
+	t1 = &type1{}
+
+	t1.t, err = p.type2()
+	if err != nil {
+		return nil, err
+	}
+
+	end := p.i
+
+	p.ws()
+
+	switch {
+	case p.skip("..."):
+		t1.op = "..."
+	case p.skip(".."):
+		t1.op = ".."
+	case p.skip("."):
+		st := p.i
+
+		t1.op = p.id()
+		if !controls[t1.op] {
+			p.i = st
+			return nil, p.errorf("unsupported control %q", t1.op)
+		}
+	default:
+		p.i = end
+		return t1, nil
+	}
+
+	p.ws()
+
+	t1.arg, err = p.type2()
+	if err != nil {
+		return nil, err
+	}
+
+	if t1.op == "regexp" {
+		if t1.arg.kind != kindValue || t1.arg.v.kind != valueText {
+			return nil, p.errorf("regexp must be a text literal")
+		}
+
+		t1.re, err = regexp.Compile(`^(?:` + t1.arg.v.s + `)$`)
+		if err != nil {
+			return nil, p.errorf("regexp: %v", err)
+		}
+	}
+
+	return t1, nil
+}
+
+func (p *parser) type2() (t2 *type2, err error) {
+	if p.i == len(p.s) {
+		return nil, p.errorf("type expected")
+	}
+
+	t2 = &type2{}
+
+	switch c := p.s[p.i]; {
+	case p.atValue():
+		t2.v, err = p.value()
+	case c == '(':
+		p.i++
+		p.ws()
+
+		t2.kind = kindParen
+
+		t2.t, err = p.typ()
+		if err != nil {
+			return nil, err
+		}
+
+		p.ws()
+
+		if !p.skip(")") {
+			return nil, p.errorf(") expected")
+		}
+	case c == '{' || c == '[':
+		p.i++
+
+		t2.kind = kindMap
+		end := byte('}')
+
+		if c == '[' {
+			t2.kind = kindArray
+			end = ']'
+		}
+
+		t2.g, err = p.group(end)
+	case c == '~':
+		p.i++
+		p.ws()
+
+		t2.kind = kindUnwrap
+		err = p.name(t2)
+	case c == '&':
+		p.i++
+		p.ws()
+
+		t2.kind = kindEnum
+
+		if p.skip("(") {
+			t2.g, err = p.group(')')
+			break
+		}
+
+		err = p.name(t2)
+	case c == '#':
+		p.i++
+		err = p.major(t2)
+	default:
+		t2.kind = kindName
+		err = p.name(t2)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t2, nil
+}
+
+func (p *parser) major(t2 *type2) (err error) {
+	t2.kind = kindMajor
+	t2.major = -1
+
+	if p.i == len(p.s) || p.s[p.i] < '0' || p.s[p.i] > '9' {
+		return nil
+	}
+
+	if p.s[p.i] > '7' {
+		return p.errorf("bad major type")
+	}
+
+	t2.major = int(p.s[p.i] - '0')
+	p.i++
+
+	if p.skip(".") {
+		n, ok := p.uint64()
+		if !ok {
+			return p.errorf("number expected")
+		}
+
+		t2.num, t2.hasNum = n, true
+	}
+
+	if t2.major != 6 {
+		return nil
+	}
+
+	if !p.skip("(") {
+		return p.errorf("( expected")
+	}
+
+	p.ws()
+
+	t2.t, err = p.typ()
+	if err != nil {
+		return err
+	}
+
+	p.ws()
+
+	if !p.skip(")") {
+		return p.errorf(") expected")
+	}
+
+	return nil
+}
+
+func (p *parser) name(t2 *type2) error {
+	t2.name = p.id()
+	if t2.name == "" {
+		if p.i == len(p.s) {
+			return p.errorf("type expected")
+		}
+
+		return p.errorf("unexpected %q", p.s[p.i])
+	}
+
+	if !p.skip("<") {
+		return nil
+	}
+
+	for len(t2.args) == 0 || p.skip(",") {
+		p.ws()
+
+		st := p.i
+
+		t1, err := p.type1()
+		if err != nil {
+			return err
+		}
+
+		t2.args = append(t2.args, &typ{choices: []*type1{t1}, src: p.s[st:p.i]})
+
+		p.ws()
+	}
+
+	if !p.skip(">") {
+		return p.errorf("> expected")
+	}
+
+	return nil
+}
+
+func (p *parser) atValue() bool {
+	if p.i == len(p.s) {
+		return false
+	}
+
+	c := p.s[p.i]
+
+	return c == '"' || c == '\'' || c == '-' || c >= '0' && c <= '9' ||
+		p.at("h'") || p.at("b64'")
+}
+
+func (p *parser) value() (v value, err error) {
+	switch c := p.s[p.i]; {
+	case c == '"':
+		v.kind = valueText
+		v.s, err = p.text()
+	case c == '\'':
+		v.kind = valueBytes
+		v.s, err = p.quoted()
+	case p.skip("h"):
+		v.kind = valueBytes
+
+		var s string
+
+		s, err = p.quoted()
+		if err != nil {
+			break
+		}
+
+		var b []byte
+
+		b, err = hex.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil {
+			return v, p.errorf("bad hex")
+		}
+
+		v.s = string(b)
+	case p.skip("b64"):
+		v.kind = valueBytes
+
+		var s string
+
+		s, err = p.quoted()
+		if err != nil {
+			break
+		}
+
+		var b []byte
+
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.Join(strings.Fields(s), ""), "="))
+		if err != nil {
+			return v, p.errorf("bad base64")
+		}
+
+		v.s = string(b)
+	default:
+		return p.number()
+	}
+
+	return v, err
+}
+
+func (p *parser) number() (v value, err error) {
+	st := p.i
+
+	neg := p.skip("-")
+	float := false
+
+	digits := func() {
+		for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+	}
+
+	if p.at("0x") || p.at("0b") {
+		p.i += 2
+
+		for p.i < len(p.s) && isAlnum(p.s[p.i]) {
+			p.i++
+		}
+	} else {
+		digits()
+
+		if p.i+1 < len(p.s) && p.s[p.i] == '.' && p.s[p.i+1] >= '0' && p.s[p.i+1] <= '9' {
+			float = true
+			p.i++
+
+			digits()
+		}
+
+		if p.i < len(p.s) && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+			float = true
+			p.i++
+
+			if p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+				p.i++
+			}
+
+			digits()
+		}
+	}
+
+	s := p.s[st:p.i]
+
+	if float {
+		v.kind = valueFloat
+
+		v.f, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			p.i = st
+			return v, p.errorf("bad number %q", s)
+		}
+
+		return v, nil
+	}
+
+	v.kind = valueInt
+
+	v.u, err = strconv.ParseUint(strings.TrimPrefix(s, "-"), 0, 64)
+	if err != nil {
+		p.i = st
+		return v, p.errorf("bad number %q", s)
+	}
+
+	if neg && v.u != 0 {
+		v.neg = true
+		v.u--
+	}
+
+	return v, nil
+}
+
+func (p *parser) text() (string, error) {
+	st := p.i
+	p.i++
+
+	for p.i < len(p.s) && p.s[p.i] != '"' {
+		if p.s[p.i] == '\\' {
+			p.i++
+		}
+
+		p.i++
+	}
+
+	if p.i >= len(p.s) {
+		p.i = st
+		return "", p.errorf("unterminated string")
+	}
+
+	p.i++
+
+	s, err := strconv.Unquote(p.s[st:p.i])
+	if err != nil {
+		p.i = st
+		return "", p.errorf("bad string")
+	}
+
+	return s, nil
+}
+
+// quoted parses single quoted string with \' and \\ escapes.
+func (p *parser) quoted() (string, error) {
+	if !p.skip("'") {
+		return "", p.errorf("' expected")
+	}
+
+	var b strings.Builder
+
+	for p.i < len(p.s) && p.s[p.i] != '\'' {
+		if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+			p.i++
+		}
+
+		b.WriteByte(p.s[p.i])
+		p.i++
+	}
+
+	if !p.skip("'") {
+		return "", p.errorf("unterminated string")
+	}
+
+	return b.String(), nil
+}
+
+func (p *parser) uint64() (uint64, bool) {
+	st := p.i
+
+	if p.at("0x") || p.at("0b") {
+		p.i += 2
+	}
+
+	for p.i < len(p.s) && isAlnum(p.s[p.i]) {
+		p.i++
+	}
+
+	x, err := strconv.ParseUint(p.s[st:p.i], 0, 64)
+	if err != nil {
+		p.i = st
+		return 0, false
+	}
+
+	return x, true
+}
+
+// id parses identifier.
+func (p *parser) id() string {
+	st := p.i
+
+	if p.i == len(p.s) || !isEAlpha(p.s[p.i]) {
+		return ""
+	}
+
+	p.i++
+
+	for p.i < len(p.s) {
+		j := p.i
+
+		for j < len(p.s) && (p.s[j] == '-' || p.s[j] == '.') {
+			j++
+		}
+
+		if j == len(p.s) || !isEAlpha(p.s[j]) && (p.s[j] < '0' || p.s[j] > '9') {
+			break
+		}
+
+		p.i = j + 1
+	}
+
+	return p.s[st:p.i]
+}
+
+// atRule reports whether the next rule starts here.
+func (p *parser) atRule() bool {
+	st := p.i
+	defer func() { p.i = st }()
+
+	if p.id() == "" {
+		return false
+	}
+
+	if p.at("<") {
+		end := strings.IndexByte(p.s[p.i:], '>')
+		if end < 0 {
+			return false
+		}
+
+		p.i += end + 1
+	}
+
+	p.ws()
+
+	return p.at("=") && !p.at("=>") || p.at("/=") || p.at("//=")
+}
+
+func (p *parser) ws() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		case ';':
+			end := strings.IndexByte(p.s[p.i:], '\n')
+			if end < 0 {
+				end = len(p.s) - p.i
+			}
+
+			p.i += end
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) at(s string) bool {
+	return strings.HasPrefix(p.s[p.i:], s)
+}
+
+func (p *parser) skip(s string) bool {
+	if !p.at(s) {
+		return false
+	}
+
+	p.i += len(s)
+
+	return true
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line := 1 + strings.Count(p.s[:p.i], "\n")
+	col := 1 + p.i - (strings.LastIndexByte(p.s[:p.i], '\n') + 1)
+
+	return fmt.Errorf("at %d:%d: %w: %s", line, col, ErrSyntax, fmt.Sprintf(format, args...))
+}
+
+func isEAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '@' || c == '_' || c == '$'
+}
+
+func isAlnum(c byte) bool {
+	return isEAlpha(c) || c >= '0' && c <= '9'
+}
+
+// asType returns the type of a type rule or nil if it's a group.
+func asType(g *group) *typ {
+	if len(g.choices) != 1 || len(g.choices[0]) != 1 {
+		return nil
+	}
+
+	e := g.choices[0][0]
+
+	if e.key != nil || e.t == nil || e.min != 1 || e.max != 1 {
+		return nil
+	}
+
+	return e.t
+}
@@ -0,0 +1,93 @@
+package cddl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(tb *testing.T) {
+	s, err := Parse(`
+; comment
+person = {
+	name: tstr,          ; bareword key
+	? "age" => uint,
+	* tstr => any
+}
+
+message<t, v> = [type: t, value: v]
+reboot = message<"reboot", uint>
+
+point = [x: int, y: int]
+color = &colors
+colors = (red: 1, green: 2, blue: 3)
+
+$kind /= "a"
+$kind /= "b" / "c"
+
+hex = h'01 02' / b64'AQI' / 'ab' / -1.5e3 / 0x10 / 0b101
+`)
+	if err != nil {
+		tb.Fatalf("parse: %v", err)
+	}
+
+	if s.root != "person" {
+		tb.Errorf("root: %v", s.root)
+	}
+
+	if got := asType(s.rules["$kind"].g); got == nil || len(got.choices) != 3 {
+		tb.Errorf("$kind: %+v", got)
+	}
+
+	if asType(s.rules["colors"].g) != nil {
+		tb.Errorf("colors must be a group")
+	}
+
+	v := asType(s.rules["hex"].g).choices
+
+	for k, exp := range []value{
+		{kind: valueBytes, s: "\x01\x02"},
+		{kind: valueBytes, s: "\x01\x02"},
+		{kind: valueBytes, s: "ab"},
+		{kind: valueFloat, f: -1500},
+		{kind: valueInt, u: 16},
+		{kind: valueInt, u: 5},
+	} {
+		if v[k].t.v != exp {
+			tb.Errorf("value %d: %+v, wanted %+v", k, v[k].t.v, exp)
+		}
+	}
+}
+
+func TestParseErrors(tb *testing.T) {
+	for _, tc := range []struct {
+		S   string
+		Err error
+	}{
+		{``, ErrSyntax},
+		{`a`, ErrSyntax},
+		{`a = `, nil},
+		{`a = b`, ErrUndefined},
+		{`a = [int`, ErrSyntax},
+		{`a = {int => }`, ErrSyntax},
+		{`a = int a = uint`, ErrSyntax},
+		{`a = int .foo 3`, ErrSyntax},
+		{`a = tstr .regexp "("`, ErrSyntax},
+		{`a = tstr .regexp b  b = "x"`, ErrSyntax},
+		{`a = 3*2 int`, ErrSyntax},
+		{`a = "abc`, ErrSyntax},
+		{`a = h'0g'`, ErrSyntax},
+		{`a = g<int>  g<x, y> = [x, y]`, nil},
+		{`a = #6.1`, ErrSyntax},
+		{`a = [ (int // tstr ]`, ErrSyntax},
+		{`a = (x: int)  a /= int`, ErrSyntax},
+		{`a = [1000000000000*2000000000000 int]`, ErrSyntax},
+		{`a = [2*3000000000 int]`, ErrSyntax},
+		{`a = #9`, ErrSyntax},
+	} {
+		_, err := Parse(tc.S)
+
+		if err == nil || tc.Err != nil && !errors.Is(err, tc.Err) {
+			tb.Errorf("%q: %v", tc.S, err)
+		}
+	}
+}
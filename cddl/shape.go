@@ -0,0 +1,341 @@
+package cddl
+
+import (
+	"fmt"
+)
+
+type (
+	// Shape is a simplified view of a type rule for code generators.
+	// It covers the subset of CDDL which maps onto plain data types.
+	Shape struct {
+		Kind ShapeKind
+
+		Name     string  // ShapePrim prelude type or ShapeRef rule name
+		Fields   []Field // ShapeStruct and ShapeTuple members
+		Key      *Shape  // ShapeMap key
+		Elem     *Shape  // ShapeMap value and ShapeArray element
+		Nullable bool    // null is one of the choices
+	}
+
+	// Field is a ShapeStruct or ShapeTuple member.
+	Field struct {
+		Name     string // bareword or text key, array member name
+		Key      any    // string or int64 map key, nil for tuples
+		Optional bool
+		Type     *Shape
+	}
+
+	ShapeKind int
+)
+
+const (
+	ShapePrim   ShapeKind = iota // uint, nint, int, bstr, tstr, bool, float16, float32, float64, any
+	ShapeRef                     // named type rule
+	ShapeStruct                  // map with fixed keys
+	ShapeTuple                   // array with fixed members
+	ShapeArray                   // [* T]
+	ShapeMap                     // {* K => V}
+)
+
+var shapePrims = map[string]string{
+	"uint": "uint", "nint": "nint", "int": "int",
+	"bstr": "bstr", "bytes": "bstr", "tstr": "tstr", "text": "tstr",
+	"bool": "bool", "any": "any",
+	"float16": "float16", "float32": "float32", "float64": "float64",
+	"float16-32": "float32", "float32-64": "float64", "float": "float64",
+}
+
+// Rules returns the names of the rules defined in the specification in definition order.
+func (s *Schema) Rules() []string {
+	return append([]string{}, s.names...)
+}
+
+// Shape describes the named type rule.
+// Referenced type rules are described as ShapeRef, group rules are inlined.
+// Controls are dropped, ranges and literal choices are reduced to their base types,
+// optional map members are kept, other occurrences, sockets, generics,
+// unwrapping, enumerations and tags are reported as ErrUnsupported.
+func (s *Schema) Shape(name string) (*Shape, error) {
+	r := s.rules[name]
+	if r == nil {
+		return nil, fmt.Errorf("%w: %v", ErrUndefined, name)
+	}
+
+	t := asType(r.g)
+	if t == nil || len(r.params) != 0 {
+		return nil, fmt.Errorf("%w: %v: not a type", ErrUnsupported, name)
+	}
+
+	sh, err := s.shape(t)
+	if err != nil {
+		return nil, fmt.Errorf("rule %v: %w", name, err)
+	}
+
+	return sh, nil
+}
+
+func (s *Schema) shape(t *typ) (*Shape, error) {
+	var nullable bool
+	var ch []*type1
+
+	for _, t1 := range t.choices {
+		if t1.op == "" && t1.t.kind == kindName && (t1.t.name == "null" || t1.t.name == "nil") && s.isPrelude(t1.t.name) {
+			nullable = true
+			continue
+		}
+
+		ch = append(ch, t1)
+	}
+
+	if len(ch) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, t.src)
+	}
+
+	var sh *Shape
+
+	if len(ch) == 1 {
+		var err error
+
+		sh, err = s.shape1(ch[0])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		name := ""
+
+		for _, t1 := range ch {
+			sh1, err := s.shape1(t1)
+			if err != nil {
+				return nil, err
+			}
+
+			n := sh1.Name
+
+			switch {
+			case sh1.Kind != ShapePrim || n == "any":
+				return nil, fmt.Errorf("%w: type choice: %v", ErrUnsupported, t.src)
+			case name == "", name == n:
+				name = n
+			case isIntPrim(name) && isIntPrim(n):
+				name = "int"
+			default:
+				return nil, fmt.Errorf("%w: type choice: %v", ErrUnsupported, t.src)
+			}
+		}
+
+		sh = &Shape{Kind: ShapePrim, Name: name}
+	}
+
+	sh.Nullable = nullable
+
+	return sh, nil
+}
+
+func (s *Schema) shape1(t1 *type1) (*Shape, error) {
+	switch {
+	case t1.op == "":
+	case controls[t1.op]:
+	case t1.t.kind == kindValue && t1.t.v.kind != valueText && t1.t.v.kind != valueBytes:
+		return &Shape{Kind: ShapePrim, Name: valuePrim(t1.t.v)}, nil
+	default:
+		return nil, fmt.Errorf("%w: range with non-literal bound", ErrUnsupported)
+	}
+
+	return s.shape2(t1.t)
+}
+
+func (s *Schema) shape2(t2 *type2) (*Shape, error) {
+	switch t2.kind {
+	case kindValue:
+		return &Shape{Kind: ShapePrim, Name: valuePrim(t2.v)}, nil
+	case kindName:
+		return s.shapeName(t2)
+	case kindParen:
+		return s.shape(t2.t)
+	case kindMap:
+		return s.mapShape(t2.g)
+	case kindArray:
+		return s.arrayShape(t2.g)
+	case kindMajor:
+		if t2.t == nil && !t2.hasNum && t2.major >= 0 && t2.major <= 3 {
+			return &Shape{Kind: ShapePrim, Name: []string{"uint", "nint", "bstr", "tstr"}[t2.major]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unwrapping, enumeration or tag", ErrUnsupported)
+}
+
+func (s *Schema) shapeName(t2 *type2) (*Shape, error) {
+	switch {
+	case t2.name[0] == '$':
+		return nil, fmt.Errorf("%w: socket %v", ErrUnsupported, t2.name)
+	case len(t2.args) != 0:
+		return nil, fmt.Errorf("%w: generic %v", ErrUnsupported, t2.name)
+	case s.isPrelude(t2.name):
+		if p, ok := shapePrims[t2.name]; ok {
+			return &Shape{Kind: ShapePrim, Name: p}, nil
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, t2.name)
+	}
+
+	if asType(s.rules[t2.name].g) == nil {
+		return nil, fmt.Errorf("%w: group %v used as a type", ErrUnsupported, t2.name)
+	}
+
+	return &Shape{Kind: ShapeRef, Name: t2.name}, nil
+}
+
+func (s *Schema) mapShape(g *group) (*Shape, error) {
+	ents, err := s.entries(g, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ents) == 1 && ents[0].max != 1 && ents[0].key != nil && ents[0].key.t.kind != kindValue {
+		key, err := s.shape1(ents[0].key)
+		if err != nil {
+			return nil, err
+		}
+
+		elem, err := s.shape(ents[0].t)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Shape{Kind: ShapeMap, Key: key, Elem: elem}, nil
+	}
+
+	sh := &Shape{Kind: ShapeStruct}
+
+	for _, e := range ents {
+		if e.key == nil || e.key.op != "" || e.key.t.kind != kindValue {
+			return nil, fmt.Errorf("%w: map member %v", ErrUnsupported, e.src)
+		}
+
+		if e.min > 1 || e.max != 1 {
+			return nil, fmt.Errorf("%w: occurrence: %v", ErrUnsupported, e.src)
+		}
+
+		f := Field{Optional: e.min == 0}
+
+		switch v := e.key.t.v; v.kind {
+		case valueText:
+			f.Name, f.Key = v.s, v.s
+		case valueInt:
+			f.Key = int64(v.u)
+			if v.neg {
+				f.Key = -1 - int64(v.u)
+			}
+		default:
+			return nil, fmt.Errorf("%w: map key %v", ErrUnsupported, e.src)
+		}
+
+		f.Type, err = s.shape(e.t)
+		if err != nil {
+			return nil, err
+		}
+
+		sh.Fields = append(sh.Fields, f)
+	}
+
+	return sh, nil
+}
+
+func (s *Schema) arrayShape(g *group) (*Shape, error) {
+	ents, err := s.entries(g, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ents) == 1 && ents[0].max != 1 {
+		elem, err := s.shape(ents[0].t)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Shape{Kind: ShapeArray, Elem: elem}, nil
+	}
+
+	sh := &Shape{Kind: ShapeTuple}
+
+	for _, e := range ents {
+		if e.min != 1 || e.max != 1 {
+			return nil, fmt.Errorf("%w: occurrence: %v", ErrUnsupported, e.src)
+		}
+
+		var f Field
+
+		if e.key != nil && e.key.t.kind == kindValue && e.key.t.v.kind == valueText {
+			f.Name = e.key.t.v.s
+		}
+
+		f.Type, err = s.shape(e.t)
+		if err != nil {
+			return nil, err
+		}
+
+		sh.Fields = append(sh.Fields, f)
+	}
+
+	return sh, nil
+}
+
+// entries flattens the group inlining parenthesized groups and group rules.
+func (s *Schema) entries(g *group, ents []*entry) ([]*entry, error) {
+	if len(g.choices) != 1 {
+		return nil, fmt.Errorf("%w: group choice", ErrUnsupported)
+	}
+
+	for _, e := range g.choices[0] {
+		var sub *group
+
+		switch {
+		case e.g != nil:
+			sub = e.g
+		case e.key == nil && e.t != nil && len(e.t.choices) == 1 && e.t.choices[0].op == "" && e.t.choices[0].t.kind == kindName:
+			if r := s.rules[e.t.choices[0].t.name]; r != nil && asType(r.g) == nil && len(r.params) == 0 {
+				sub = r.g
+			}
+		}
+
+		if sub == nil {
+			ents = append(ents, e)
+			continue
+		}
+
+		if e.min != 1 || e.max != 1 {
+			return nil, fmt.Errorf("%w: occurrence: %v", ErrUnsupported, e.src)
+		}
+
+		var err error
+
+		ents, err = s.entries(sub, ents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ents, nil
+}
+
+func (s *Schema) isPrelude(name string) bool {
+	return s.rules[name] == preludeRules[name]
+}
+
+func valuePrim(v value) string {
+	switch v.kind {
+	case valueInt:
+		return csel(v.neg, "int", "uint")
+	case valueFloat:
+		return "float64"
+	case valueText:
+		return "tstr"
+	default:
+		return "bstr"
+	}
+}
+
+func isIntPrim(n string) bool {
+	return n == "uint" || n == "nint" || n == "int"
+}
@@ -0,0 +1,86 @@
+package cddl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestShape(tb *testing.T) {
+	s, err := Parse(`
+person = {
+	name: tstr,
+	? age: uint .le 150,
+	1 => bytes,
+	-1: [* tag],
+	common,
+}
+
+common = (id: id, kind: "a" / "b")
+id = uint / null
+
+point = [x: int, y: 0..10 / -1]
+tag = tstr .size (1..10)
+attrs = {* tstr => float}
+bad = int / tstr
+`)
+	if err != nil {
+		tb.Fatalf("parse: %v", err)
+	}
+
+	if exp := []string{"person", "common", "id", "point", "tag", "attrs", "bad"}; !reflect.DeepEqual(s.Rules(), exp) {
+		tb.Errorf("rules: %v, wanted %v", s.Rules(), exp)
+	}
+
+	prim := func(name string) *Shape { return &Shape{Kind: ShapePrim, Name: name} }
+
+	for _, tc := range []struct {
+		Name  string
+		Shape *Shape
+	}{{
+		Name: "person",
+		Shape: &Shape{Kind: ShapeStruct, Fields: []Field{
+			{Name: "name", Key: "name", Type: prim("tstr")},
+			{Name: "age", Key: "age", Optional: true, Type: prim("uint")},
+			{Key: int64(1), Type: prim("bstr")},
+			{Key: int64(-1), Type: &Shape{Kind: ShapeArray, Elem: &Shape{Kind: ShapeRef, Name: "tag"}}},
+			{Name: "id", Key: "id", Type: &Shape{Kind: ShapeRef, Name: "id"}},
+			{Name: "kind", Key: "kind", Type: prim("tstr")},
+		}},
+	}, {
+		Name:  "id",
+		Shape: &Shape{Kind: ShapePrim, Name: "uint", Nullable: true},
+	}, {
+		Name: "point",
+		Shape: &Shape{Kind: ShapeTuple, Fields: []Field{
+			{Name: "x", Type: prim("int")},
+			{Name: "y", Type: prim("int")},
+		}},
+	}, {
+		Name:  "tag",
+		Shape: prim("tstr"),
+	}, {
+		Name:  "attrs",
+		Shape: &Shape{Kind: ShapeMap, Key: prim("tstr"), Elem: prim("float64")},
+	}} {
+		sh, err := s.Shape(tc.Name)
+		if err != nil {
+			tb.Errorf("%v: %v", tc.Name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(sh, tc.Shape) {
+			tb.Errorf("%v: %+v, wanted %+v", tc.Name, sh, tc.Shape)
+		}
+	}
+
+	for _, name := range []string{"bad", "common"} {
+		if _, err = s.Shape(name); !errors.Is(err, ErrUnsupported) {
+			tb.Errorf("%v: %v", name, err)
+		}
+	}
+
+	if _, err = s.Shape("none"); !errors.Is(err, ErrUndefined) {
+		tb.Errorf("none: %v", err)
+	}
+}
@@ -0,0 +1,941 @@
+package cddl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	validator struct {
+		s *Schema
+		d cbor.Decoder
+		b []byte
+
+		rule  string // current named rule
+		err   *Violation
+		quiet bool
+		abort bool // recursion limit reached, nothing matches anymore
+		depth int
+
+		ends map[int]int // container item ends, see skip
+	}
+
+	// env binds generic parameters to the arguments.
+	env map[string]binding
+
+	binding struct {
+		t *typ
+		e env
+	}
+
+	pair struct {
+		k, v int
+	}
+)
+
+const maxDepth = 1000
+
+func (v *validator) typ(t *typ, e env, i int) bool {
+	if v.abort {
+		return false
+	}
+
+	for _, t1 := range t.choices {
+		if v.type1(t1, e, i) {
+			return true
+		}
+	}
+
+	return v.fail(i, "expected %v", t.src)
+}
+
+func (v *validator) type1(t1 *type1, e env, i int) bool {
+	switch t1.op {
+	case "":
+		return v.type2(t1.t, e, i)
+	case "..", "...":
+		return v.rangeOp(t1, e, i)
+	}
+
+	return v.type2(t1.t, e, i) && v.control(t1, e, i)
+}
+
+func (v *validator) type2(t2 *type2, e env, i int) bool {
+	switch t2.kind {
+	case kindValue:
+		return v.value(t2.v, i)
+	case kindName:
+		return v.name(t2, e, i)
+	case kindParen:
+		return v.typ(t2.t, e, i)
+	case kindMap:
+		return v.mapType(t2.g, e, i)
+	case kindArray:
+		return v.arrayType(t2.g, e, i)
+	case kindUnwrap:
+		u, ue, ok := v.unwrap(t2, e, 0)
+		return ok && v.type2(u, ue, i)
+	case kindEnum:
+		g, ge, ok := v.groupRef(t2, e, 0)
+		if t2.g != nil {
+			g, ge, ok = t2.g, e, true
+		}
+
+		return ok && v.enum(g, ge, i, 0)
+	case kindMajor:
+		return v.major(t2, e, i)
+	}
+
+	return false
+}
+
+func (v *validator) name(t2 *type2, e env, i int) bool {
+	if b, ok := e[t2.name]; ok {
+		return v.typ(b.t, b.e, i)
+	}
+
+	r := v.s.rules[t2.name]
+	if r == nil { // socket without plugs
+		return false
+	}
+
+	t := asType(r.g)
+	if t == nil {
+		return v.fail(i, "%v is a group", t2.name)
+	}
+
+	if v.depth >= maxDepth {
+		return v.tooDeep(i)
+	}
+
+	rule, quiet := v.rule, v.quiet
+	v.rule = r.name
+	v.quiet = quiet || preludeRules[r.name] == r
+	v.depth++
+
+	ok := v.typ(t, bind(r, t2.args, e), i)
+
+	v.rule, v.quiet = rule, quiet
+	v.depth--
+
+	return ok
+}
+
+// groupRef resolves group name reference or unwrapping.
+func (v *validator) groupRef(t2 *type2, e env, depth int) (*group, env, bool) {
+	if depth >= maxDepth {
+		return nil, nil, false
+	}
+
+	switch t2.kind {
+	case kindName, kindEnum:
+	case kindUnwrap:
+		u, ue, ok := v.unwrap(t2, e, depth)
+		if !ok || u.kind != kindMap && u.kind != kindArray {
+			return nil, nil, false
+		}
+
+		return u.g, ue, true
+	default:
+		return nil, nil, false
+	}
+
+	if b, ok := e[t2.name]; ok {
+		if t2 := single(b.t); t2 != nil {
+			return v.groupRef(t2, b.e, depth+1)
+		}
+
+		return nil, nil, false
+	}
+
+	r := v.s.rules[t2.name]
+	if r == nil {
+		if strings.HasPrefix(t2.name, "$$") {
+			return &group{}, nil, true
+		}
+
+		return nil, nil, false
+	}
+
+	re := bind(r, t2.args, e)
+
+	t := asType(r.g)
+	if t == nil {
+		return r.g, re, true
+	}
+
+	if t2 := single(t); t2 != nil && t2.kind == kindName {
+		return v.groupRef(t2, re, depth+1)
+	}
+
+	return nil, nil, false
+}
+
+// unwrap resolves ~name to the map, array or tag type it names.
+func (v *validator) unwrap(t2 *type2, e env, depth int) (*type2, env, bool) {
+	for ; depth < maxDepth; depth++ {
+		switch t2.kind {
+		case kindMap, kindArray:
+			return t2, e, true
+		case kindMajor:
+			if t2.major != 6 {
+				return nil, nil, false
+			}
+
+			if u := single(t2.t); u != nil {
+				return u, e, true
+			}
+
+			return nil, nil, false
+		case kindName, kindUnwrap:
+		default:
+			return nil, nil, false
+		}
+
+		if b, ok := e[t2.name]; ok {
+			t2, e = single(b.t), b.e
+		} else if r := v.s.rules[t2.name]; r != nil && asType(r.g) != nil {
+			t2, e = single(asType(r.g)), bind(r, t2.args, e)
+		} else {
+			return nil, nil, false
+		}
+
+		if t2 == nil {
+			return nil, nil, false
+		}
+	}
+
+	return nil, nil, false
+}
+
+// entryGroup returns the group the entry refers to if it's a group entry.
+func (v *validator) entryGroup(en *entry, e env) (*group, env, bool) {
+	if en.g != nil {
+		return en.g, e, true
+	}
+
+	if en.key != nil {
+		return nil, nil, false
+	}
+
+	t2 := single(en.t)
+	if t2 == nil || t2.kind != kindName && t2.kind != kindUnwrap {
+		return nil, nil, false
+	}
+
+	return v.groupRef(t2, e, 0)
+}
+
+func (v *validator) enum(g *group, e env, i, depth int) bool {
+	if depth >= maxDepth {
+		return false
+	}
+
+	for _, ch := range g.choices {
+		for _, en := range ch {
+			if sub, se, ok := v.entryGroup(en, e); ok {
+				if v.enum(sub, se, i, depth+1) {
+					return true
+				}
+
+				continue
+			}
+
+			if en.t != nil && v.typ(en.t, e, i) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (v *validator) mapType(g *group, e env, i int) bool {
+	if v.d.TagOnly(v.b, i) != cbor.Map {
+		return false
+	}
+
+	var pairs []pair
+
+	l, j := v.d.ExpectMap(v.b, i)
+
+	for el := 0; l == -1 && !v.d.Break(v.b, &j) || el < l; el++ {
+		k := j
+		j = v.skip(j)
+		pairs = append(pairs, pair{k: k, v: j})
+		j = v.skip(j)
+	}
+
+	used := make([]bool, len(pairs))
+
+	if !v.mapGroup(g, e, pairs, used, i) {
+		return false
+	}
+
+	for k, u := range used {
+		if !u {
+			return v.fail(pairs[k].k, "unexpected map key")
+		}
+	}
+
+	return true
+}
+
+func (v *validator) mapGroup(g *group, e env, pairs []pair, used []bool, at int) bool {
+	for _, ch := range g.choices {
+		u := append([]bool{}, used...)
+
+		if v.mapEntries(ch, e, pairs, u, at) {
+			copy(used, u)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *validator) mapEntries(ch []*entry, e env, pairs []pair, used []bool, at int) bool {
+	for _, en := range ch {
+		if !v.mapEntry(en, e, pairs, used, at) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v *validator) mapEntry(en *entry, e env, pairs []pair, used []bool, at int) bool {
+	if v.abort {
+		return false
+	}
+
+	g, ge, isGroup := v.entryGroup(en, e)
+
+	if isGroup {
+		if v.depth >= maxDepth {
+			return v.tooDeep(at)
+		}
+
+		v.depth++
+		defer func() { v.depth-- }()
+	}
+
+	n := 0
+
+	for en.max < 0 || n < en.max {
+		if !isGroup {
+			ok, cut := v.mapMember(en, e, pairs, used)
+			if cut {
+				return false
+			}
+
+			if !ok {
+				break
+			}
+
+			n++
+
+			continue
+		}
+
+		u := append([]bool{}, used...)
+
+		if !v.mapGroup(g, ge, pairs, u, at) {
+			break
+		}
+
+		n++
+
+		if count(u) == count(used) {
+			break
+		}
+
+		copy(used, u)
+	}
+
+	if n < en.min {
+		return v.fail(at, "missing %v", en.src)
+	}
+
+	return true
+}
+
+// mapMember consumes the first pair matching the entry.
+// cut is reported if the key matched but the value didn't and the entry has a cut.
+func (v *validator) mapMember(en *entry, e env, pairs []pair, used []bool) (ok, cut bool) {
+	if en.key == nil {
+		return false, false
+	}
+
+	for k, p := range pairs {
+		if used[k] {
+			continue
+		}
+
+		q := v.quiet
+		v.quiet = true
+		ok = v.type1(en.key, e, p.k)
+		v.quiet = q
+
+		if !ok {
+			continue
+		}
+
+		if v.typ(en.t, e, p.v) {
+			used[k] = true
+			return true, false
+		}
+
+		if en.cut {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+func (v *validator) arrayType(g *group, e env, i int) bool {
+	if v.d.TagOnly(v.b, i) != cbor.Array {
+		return false
+	}
+
+	var items []int
+
+	l, j := v.d.ExpectArray(v.b, i)
+
+	for el := 0; l == -1 && v.b[j] != byte(cbor.Simple|cbor.Break) || el < l; el++ {
+		items = append(items, j)
+		j = v.skip(j)
+	}
+
+	items = append(items, j) // end position for missing items
+
+	return v.arrayGroup(g, e, items, 0, func(k int) bool {
+		if k == len(items)-1 {
+			return true
+		}
+
+		return v.fail(items[k], "unexpected array item")
+	})
+}
+
+func (v *validator) arrayGroup(g *group, e env, items []int, k int, cont func(k int) bool) bool {
+	for _, ch := range g.choices {
+		if v.arrayEntries(ch, 0, e, items, k, cont) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *validator) arrayEntries(ch []*entry, ei int, e env, items []int, k int, cont func(k int) bool) bool {
+	if ei == len(ch) {
+		return cont(k)
+	}
+
+	return v.arrayRepeat(ch[ei], 0, e, items, k, func(k int) bool {
+		return v.arrayEntries(ch, ei+1, e, items, k, cont)
+	})
+}
+
+func (v *validator) arrayRepeat(en *entry, n int, e env, items []int, k int, cont func(k int) bool) bool {
+	if (en.max < 0 || n < en.max) && v.arrayOne(en, e, items, k, func(k2 int) bool {
+		if k2 == k {
+			return n+1 >= en.min && cont(k2)
+		}
+
+		return v.arrayRepeat(en, n+1, e, items, k2, cont)
+	}) {
+		return true
+	}
+
+	return n >= en.min && cont(k)
+}
+
+func (v *validator) arrayOne(en *entry, e env, items []int, k int, cont func(k int) bool) bool {
+	if v.abort {
+		return false
+	}
+
+	if g, ge, ok := v.entryGroup(en, e); ok {
+		if v.depth >= maxDepth {
+			return v.tooDeep(items[k])
+		}
+
+		v.depth++
+		defer func() { v.depth-- }()
+
+		return v.arrayGroup(g, ge, items, k, cont)
+	}
+
+	if k == len(items)-1 {
+		return v.fail(items[k], "missing %v", en.src)
+	}
+
+	return v.typ(en.t, e, items[k]) && cont(k+1)
+}
+
+func (v *validator) major(t2 *type2, e env, i int) bool {
+	if t2.major < 0 {
+		return true
+	}
+
+	raw := v.d.TagRaw(v.b, i)
+
+	if raw&cbor.TagMask != cbor.Tag(t2.major<<5) {
+		return false
+	}
+
+	switch t2.major {
+	case 6:
+		_, num, j := v.d.Tag(v.b, i)
+
+		if t2.hasNum && uint64(num) != t2.num {
+			return false
+		}
+
+		return v.typ(t2.t, e, j)
+	case 7:
+		if !t2.hasNum {
+			return true
+		}
+
+		switch ai := uint64(raw & cbor.SubMask); {
+		case t2.num >= cbor.Float16 && t2.num <= cbor.Float64 || ai < cbor.Float8:
+			return ai == t2.num
+		case ai == cbor.Float8:
+			return uint64(v.b[i+1]) == t2.num
+		}
+
+		return false
+	default:
+		_, sub, _ := v.d.Tag(v.b, i)
+
+		return !t2.hasNum || uint64(sub) == t2.num
+	}
+}
+
+func (v *validator) value(x value, i int) bool {
+	switch x.kind {
+	case valueInt:
+		y, ok := v.intAt(i)
+		return ok && y.neg == x.neg && y.u == x.u
+	case valueFloat:
+		f, ok := v.floatAt(i)
+		return ok && f == x.f
+	case valueText, valueBytes:
+		tag := csel(x.kind == valueText, cbor.String, cbor.Bytes)
+		if v.d.TagOnly(v.b, i) != tag {
+			return false
+		}
+
+		s, _ := v.d.ExpectString(v.b, i)
+		if tag == cbor.Bytes {
+			s, _ = v.d.ExpectBytes(v.b, i)
+		}
+
+		return string(s) == x.s
+	}
+
+	return false
+}
+
+func (v *validator) rangeOp(t1 *type1, e env, i int) bool {
+	lo, ok1 := v.literal(t1.t, e, 0)
+	hi, ok2 := v.literal(t1.arg, e, 0)
+
+	if !ok1 || !ok2 || lo.kind != hi.kind || lo.kind != valueInt && lo.kind != valueFloat {
+		return v.fail(i, "bad range")
+	}
+
+	excl := t1.op == "..."
+
+	if lo.kind == valueFloat {
+		f, ok := v.floatAt(i)
+
+		return ok && f >= lo.f && (f < hi.f || !excl && f == hi.f)
+	}
+
+	x, ok := v.intAt(i)
+	if !ok {
+		return false
+	}
+
+	c := cmpInt(x, hi)
+
+	return cmpInt(x, lo) >= 0 && (c < 0 || !excl && c == 0)
+}
+
+func (v *validator) control(t1 *type1, e env, i int) bool {
+	tag := v.d.TagOnly(v.b, i)
+
+	switch t1.op {
+	case "size":
+		switch tag {
+		case cbor.Int:
+			n, ok := v.literal(t1.arg, e, 0)
+			if !ok || n.kind != valueInt || n.neg {
+				return v.fail(i, "bad .size controller")
+			}
+
+			_, x, _ := v.d.Tag(v.b, i)
+
+			if n.u < 8 && uint64(x) >= 1<<(8*n.u) {
+				return v.fail(i, "size exceeds %d bytes", n.u)
+			}
+
+			return true
+		case cbor.Bytes, cbor.String:
+			s, _ := v.d.ExpectBytes(v.b, i)
+			if tag == cbor.String {
+				s, _ = v.d.ExpectString(v.b, i)
+			}
+
+			if !v.check(t1.arg, e, i, uint64(len(s))) {
+				return v.fail(i, "size %d doesn't match", len(s))
+			}
+
+			return true
+		}
+	case "bits":
+		var bits []uint64
+
+		switch tag {
+		case cbor.Int:
+			_, x, _ := v.d.Tag(v.b, i)
+
+			for k := uint64(0); k < 64; k++ {
+				if uint64(x)&(1<<k) != 0 {
+					bits = append(bits, k)
+				}
+			}
+		case cbor.Bytes:
+			s, _ := v.d.ExpectBytes(v.b, i)
+
+			for j, c := range s {
+				for k := uint64(0); k < 8; k++ {
+					if c&(1<<k) != 0 {
+						bits = append(bits, uint64(j)*8+k)
+					}
+				}
+			}
+		default:
+			return false
+		}
+
+		for _, k := range bits {
+			if !v.check(t1.arg, e, i, k) {
+				return v.fail(i, "bit %d is not allowed", k)
+			}
+		}
+
+		return true
+	case "regexp":
+		if tag != cbor.String {
+			return false
+		}
+
+		s, _ := v.d.ExpectString(v.b, i)
+
+		return t1.re.Match(s) || v.fail(i, "doesn't match regexp %q", t1.arg.v.s)
+	case "cbor":
+		if tag != cbor.Bytes {
+			return false
+		}
+
+		return v.embedded(t1.arg, e, i)
+	case "lt", "le", "gt", "ge":
+		x, ok := v.literal(t1.arg, e, 0)
+		if !ok {
+			return v.fail(i, "bad .%v controller", t1.op)
+		}
+
+		c, ok := v.cmpNum(i, x)
+
+		ok = ok && (t1.op == "lt" && c < 0 || t1.op == "le" && c <= 0 || t1.op == "gt" && c > 0 || t1.op == "ge" && c >= 0)
+
+		return ok || v.fail(i, "must be %v %v", t1.op, x)
+	case "eq", "ne":
+		x, ok := v.literal(t1.arg, e, 0)
+		if !ok {
+			return v.fail(i, "bad .%v controller", t1.op)
+		}
+
+		return v.value(x, i) == (t1.op == "eq") || v.fail(i, "must be %v %v", t1.op, x)
+	case "default":
+		return true
+	case "within", "and":
+		return v.type2(t1.arg, e, i)
+	}
+
+	return false
+}
+
+// embedded checks bytes at i contain exactly one item matching t2.
+func (v *validator) embedded(t2 *type2, e env, i int) bool {
+	_, sub, j := v.d.Tag(v.b, i)
+
+	if sub >= 0 {
+		if v.d.Validate(v.b[:j+int(sub)], j) != j+int(sub) {
+			return v.fail(j, "not a single cbor item")
+		}
+
+		return v.type2(t2, e, j)
+	}
+
+	s, _ := v.d.ExpectBytes(v.b, i)
+
+	if v.d.Validate(s, 0) != len(s) {
+		return v.fail(i, "not a single cbor item")
+	}
+
+	sub2 := validator{s: v.s, d: v.d, b: s, quiet: true, depth: v.depth}
+
+	if sub2.type2(t2, e, 0) {
+		return true
+	}
+
+	if sub2.abort {
+		return v.tooDeep(i)
+	}
+
+	return v.fail(i, "embedded item doesn't match")
+}
+
+// check checks unsigned n derived from the item at i matches t2.
+func (v *validator) check(t2 *type2, e env, i int, n uint64) bool {
+	sub := validator{s: v.s, d: v.d, b: cbor.Encoder{}.AppendUint64(nil, n), quiet: true, depth: v.depth}
+
+	if sub.type2(t2, e, 0) {
+		return true
+	}
+
+	if sub.abort {
+		return v.tooDeep(i)
+	}
+
+	return false
+}
+
+// literal resolves t2 to a literal value.
+func (v *validator) literal(t2 *type2, e env, depth int) (value, bool) {
+	for ; t2 != nil && depth < maxDepth; depth++ {
+		switch t2.kind {
+		case kindValue:
+			return t2.v, true
+		case kindParen:
+			t2 = single(t2.t)
+			continue
+		case kindName:
+		default:
+			return value{}, false
+		}
+
+		if b, ok := e[t2.name]; ok {
+			t2, e = single(b.t), b.e
+		} else if r := v.s.rules[t2.name]; r != nil && asType(r.g) != nil {
+			t2, e = single(asType(r.g)), bind(r, t2.args, e)
+		} else {
+			return value{}, false
+		}
+	}
+
+	return value{}, false
+}
+
+func (v *validator) intAt(i int) (value, bool) {
+	tag, sub, _ := v.d.Tag(v.b, i)
+	if tag != cbor.Int && tag != cbor.Neg {
+		return value{}, false
+	}
+
+	return value{kind: valueInt, neg: tag == cbor.Neg, u: uint64(sub)}, true
+}
+
+func (v *validator) floatAt(i int) (float64, bool) {
+	raw := v.d.TagRaw(v.b, i)
+	if raw < cbor.Simple|cbor.Float16 || raw > cbor.Simple|cbor.Float64 {
+		return 0, false
+	}
+
+	f, _ := v.d.Float(v.b, i)
+
+	return f, true
+}
+
+// cmpNum compares numeric item at i with x.
+func (v *validator) cmpNum(i int, x value) (int, bool) {
+	if y, ok := v.intAt(i); ok && x.kind == valueInt {
+		return cmpInt(y, x), true
+	}
+
+	var f float64
+
+	if y, ok := v.intAt(i); ok {
+		f = y.float()
+	} else if f, ok = v.floatAt(i); !ok {
+		return 0, false
+	}
+
+	g := x.f
+	if x.kind == valueInt {
+		g = x.float()
+	} else if x.kind != valueFloat {
+		return 0, false
+	}
+
+	return csel(f < g, -1, csel(f > g, 1, 0)), true
+}
+
+func (v *validator) fail(i int, format string, args ...any) bool {
+	if v.quiet || v.abort || v.err != nil && i <= v.err.Err.Index() {
+		return false
+	}
+
+	v.err = &Violation{Rule: v.rule, Err: cbor.MakeError(cbor.ErrInvalid, i), Msg: fmt.Sprintf(format, args...)}
+
+	return false
+}
+
+// skip returns the end of the item at i.
+// Container ends are memoized as recursive rules skip nested containers at every level.
+func (v *validator) skip(i int) int {
+	tag := v.d.TagOnly(v.b, i)
+	if tag != cbor.Array && tag != cbor.Map && tag != cbor.Labeled {
+		return v.d.Skip(v.b, i)
+	}
+
+	if j, ok := v.ends[i]; ok {
+		return j
+	}
+
+	var l, j int
+
+	switch tag {
+	case cbor.Array:
+		l, j = v.d.ExpectArray(v.b, i)
+	case cbor.Map:
+		l, j = v.d.ExpectMap(v.b, i)
+		l *= 2
+	default:
+		_, _, j = v.d.Tag(v.b, i)
+		l = 1
+	}
+
+	for el := 0; l < 0 && !v.d.Break(v.b, &j) || el < l; el++ {
+		j = v.skip(j)
+	}
+
+	if v.ends == nil {
+		v.ends = map[int]int{}
+	}
+
+	v.ends[i] = j
+
+	return j
+}
+
+// tooDeep stops validation at the recursion limit.
+// The error is reported even in quiet mode as no alternative can match after that.
+func (v *validator) tooDeep(i int) bool {
+	v.abort = true
+	v.err = &Violation{Rule: v.rule, Err: cbor.MakeError(cbor.ErrInvalid, i), Msg: "too deep recursion"}
+
+	return false
+}
+
+func (v *validator) violation(st int) error {
+	if v.err == nil {
+		return &Violation{Rule: v.rule, Err: cbor.MakeError(cbor.ErrInvalid, st), Msg: "no match"}
+	}
+
+	return v.err
+}
+
+func bind(r *rule, args []*typ, e env) env {
+	if len(r.params) == 0 {
+		return nil
+	}
+
+	ne := env{}
+
+	for k, p := range r.params {
+		ne[p] = binding{t: args[k], e: e}
+	}
+
+	return ne
+}
+
+// single returns the only type2 of the type without operators or nil.
+func single(t *typ) *type2 {
+	if t == nil || len(t.choices) != 1 || t.choices[0].op != "" {
+		return nil
+	}
+
+	return t.choices[0].t
+}
+
+func cmpInt(x, y value) int {
+	switch {
+	case x.neg != y.neg:
+		return csel(x.neg, -1, 1)
+	case x.u == y.u:
+		return 0
+	case x.u < y.u != x.neg:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func count(l []bool) (n int) {
+	for _, x := range l {
+		if x {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (x value) float() float64 {
+	if x.neg {
+		return -1 - float64(x.u)
+	}
+
+	return float64(x.u)
+}
+
+func (x value) String() string {
+	switch x.kind {
+	case valueInt:
+		if !x.neg {
+			return strconv.FormatUint(x.u, 10)
+		}
+
+		if x.u == math.MaxUint64 {
+			return "-18446744073709551616"
+		}
+
+		return "-" + strconv.FormatUint(x.u+1, 10)
+	case valueFloat:
+		return strconv.FormatFloat(x.f, 'g', -1, 64)
+	case valueText:
+		return strconv.Quote(x.s)
+	default:
+		return fmt.Sprintf("h'%x'", x.s)
+	}
+}
+
+func csel[T any](c bool, x, y T) T {
+	if c {
+		return x
+	}
+
+	return y
+}
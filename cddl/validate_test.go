@@ -0,0 +1,261 @@
+package cddl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"nikand.dev/go/cbor"
+)
+
+func TestValidate(tb *testing.T) {
+	for _, tc := range []struct {
+		Schema string
+		OK     []string
+		Fail   []string
+	}{{
+		Schema: `a = uint / tstr / null`,
+		OK:     []string{`0`, `1000000`, `"x"`, `(_ "a", "b")`, `null`},
+		Fail:   []string{`-1`, `h''`, `true`, `1.5`},
+	}, {
+		Schema: `a = int / float / bool / undefined`,
+		OK:     []string{`-1`, `1.5_1`, `1.5_2`, `1.5_3`, `true`, `false`, `undefined`},
+		Fail:   []string{`null`, `"1"`},
+	}, {
+		Schema: `a = 1 / -2 / 1.5 / "x" / h'01' / 'y'`,
+		OK:     []string{`1`, `-2`, `1.5`, `"x"`, `h'01'`, `h'79'`},
+		Fail:   []string{`2`, `-1`, `1.0`, `"y"`, `h'02'`},
+	}, {
+		Schema: `a = 1..10 / -5...-1 / 0.5..1.5`,
+		OK:     []string{`1`, `10`, `-5`, `-2`, `0.5`, `1.5`},
+		Fail:   []string{`0`, `11`, `-1`, `2.0`, `"5"`},
+	}, {
+		Schema: `a = 0..max  max = 3`,
+		OK:     []string{`0`, `3`},
+		Fail:   []string{`4`},
+	}, {
+		Schema: `a = {name: tstr, ? age: uint, * int => bool}`,
+		OK:     []string{`{"name": "a"}`, `{"age": 1, "name": "a"}`, `{"name": "a", 1: true, 2: false}`, `{_ "name": "a"}`},
+		Fail:   []string{`{}`, `{"name": 1}`, `{"name": "a", "age": -1}`, `{"name": "a", "x": 1}`, `{"name": "a", 1: 1}`, `["name", "a"]`},
+	}, {
+		Schema: `a = [uint, ? tstr, * bool]`,
+		OK:     []string{`[1]`, `[1, "a"]`, `[1, true, false]`, `[1, "a", true]`, `[_ 1, "a"]`},
+		Fail:   []string{`[]`, `["a"]`, `[1, "a", "b"]`, `[1, true, "a"]`, `{}`},
+	}, {
+		Schema: `a = [2*3 int]`,
+		OK:     []string{`[1, 2]`, `[1, 2, 3]`},
+		Fail:   []string{`[1]`, `[1, 2, 3, 4]`},
+	}, {
+		Schema: `a = [+ (int, tstr)]`,
+		OK:     []string{`[1, "a"]`, `[1, "a", 2, "b"]`},
+		Fail:   []string{`[]`, `[1]`, `[1, "a", 2]`},
+	}, {
+		Schema: `a = [* int, tstr]`,
+		OK:     []string{`["a"]`, `[1, 2, "a"]`},
+		Fail:   []string{`[1]`, `["a", 1]`},
+	}, {
+		Schema: `a = [x // y]  x = (int, int)  y = (tstr)`,
+		OK:     []string{`[1, 2]`, `["a"]`},
+		Fail:   []string{`[1]`, `["a", 1]`},
+	}, {
+		Schema: `a = {common, (x: int // y: tstr)}  common = (id: uint)`,
+		OK:     []string{`{"id": 1, "x": 1}`, `{"id": 1, "y": "a"}`},
+		Fail:   []string{`{"id": 1}`, `{"id": 1, "x": 1, "y": "a"}`, `{"x": 1}`},
+	}, {
+		Schema: `a = {? x: int, ? x: tstr} / {? "y" => int, ? "y" => tstr}`,
+		OK:     []string{`{"x": 1}`, `{"y": 1}`, `{"y": "a"}`},
+		Fail:   []string{`{"x": "a"}`},
+	}, {
+		Schema: `a = {* tstr => int, * tstr ^ => any}`,
+		OK:     []string{`{"a": 1, "b": "c"}`},
+	}, {
+		Schema: `a = {"x": int, * tstr => any}`,
+		OK:     []string{`{"x": 1, "y": "z"}`},
+		Fail:   []string{`{"x": "a"}`},
+	}, {
+		Schema: `a = [~b, tstr]  b = [int, int]`,
+		OK:     []string{`[1, 2, "a"]`},
+		Fail:   []string{`[[1, 2], "a"]`},
+	}, {
+		Schema: `a = {~b, z: int}  b = {x: int}`,
+		OK:     []string{`{"x": 1, "z": 2}`},
+		Fail:   []string{`{"z": 2}`},
+	}, {
+		Schema: `a = &colors / &(one: 100)  colors = (red: 1, green: 2, (blue: 3))`,
+		OK:     []string{`1`, `3`, `100`},
+		Fail:   []string{`4`, `"red"`},
+	}, {
+		Schema: `a = #6.32(tstr) / #6(bstr) / #7.22 / #0.5 / #1`,
+		OK:     []string{`32("x")`, `1(h'')`, `null`, `5`, `-10`},
+		Fail:   []string{`32(1)`, `1("x")`, `undefined`, `6`},
+	}, {
+		Schema: `a = tdate / time / biguint / encoded-cbor / uri`,
+		OK:     []string{`0("2020-01-01T00:00:00Z")`, `1(1)`, `1(1.5)`, `2(h'01')`, `24(h'01')`, `32("x")`},
+		Fail:   []string{`0(1)`, `1("x")`, `3(h'01')`},
+	}, {
+		Schema: `a = [message<"reboot", uint>, message<"echo", tstr>]  message<t, v> = (type: t, value: v)`,
+		OK:     []string{`["reboot", 1, "echo", "x"]`},
+		Fail:   []string{`["reboot", "x", "echo", "x"]`, `["echo", 1, "echo", "x"]`},
+	}, {
+		Schema: `a = pair<uint>  pair<t> = [t, t]`,
+		OK:     []string{`[1, 2]`},
+		Fail:   []string{`[1, "x"]`},
+	}, {
+		Schema: `a = {kind: $kind, * $$ext}  $kind /= "a"  $kind /= "b"  $$ext //= (x: int)  $$ext //= (y: tstr)`,
+		OK:     []string{`{"kind": "a"}`, `{"kind": "b", "x": 1}`, `{"kind": "a", "x": 1, "y": "z"}`},
+		Fail:   []string{`{"kind": "c"}`, `{"kind": "a", "x": "1"}`, `{"kind": "a", "z": 1}`},
+	}, {
+		Schema: `a = {* $$ext}  b = $undef`,
+		OK:     []string{`{}`},
+		Fail:   []string{`{"x": 1}`},
+	}, {
+		Schema: `a = tstr .size 3 / bstr .size (1..2) / uint .size 1`,
+		OK:     []string{`"abc"`, `h'01'`, `h'0102'`, `255`},
+		Fail:   []string{`"ab"`, `h''`, `h'010203'`, `256`},
+	}, {
+		Schema: `a = uint .bits flags / bstr .bits flags  flags = &(read: 0, write: 1, exec: 8)`,
+		OK:     []string{`0`, `3`, `h'0301'`},
+		Fail:   []string{`4`, `h'04'`, `h'0002'`},
+	}, {
+		Schema: `a = tstr .regexp "[a-z]+@[a-z]+\\.com"`,
+		OK:     []string{`"ab@cd.com"`},
+		Fail:   []string{`"ab@cd.org"`, `"x ab@cd.com"`, `1`},
+	}, {
+		Schema: `a = bstr .cbor [uint, tstr]`,
+		OK:     []string{`<<[1, "a"]>>`, `(_ h'8201', h'6161')`},
+		Fail:   []string{`<<[1, 1]>>`, `<<[1, "a"], 1>>`, `h'82'`, `[1, "a"]`},
+	}, {
+		Schema: `a = int .lt 0 / int .ge 100`,
+		OK:     []string{`-5`, `100`, `1000`},
+		Fail:   []string{`0`, `99`},
+	}, {
+		Schema: `a = (int .gt -3) .and (int .le 3) / tstr .ne "x" / uint .eq 7`,
+		OK:     []string{`-2`, `3`, `"y"`, `7`},
+		Fail:   []string{`"x"`, `-3`, `4`, `8`},
+	}, {
+		Schema: `a = uint .default 1`,
+		OK:     []string{`5`},
+		Fail:   []string{`"x"`},
+	}, {
+		Schema: `a = uint .within (0..9) / float .lt 1`,
+		OK:     []string{`0`, `9`, `0.5`},
+		Fail:   []string{`10`, `1.5`},
+	}, {
+		Schema: `tree = [uint, * tree]`,
+		OK:     []string{`[1]`, `[1, [2], [3, [4]]]`},
+		Fail:   []string{`[1, [2, ["x"]]]`},
+	}} {
+		s, err := Parse(tc.Schema)
+		if err != nil {
+			tb.Errorf("%v: parse: %v", tc.Schema, err)
+			continue
+		}
+
+		for _, diag := range tc.OK {
+			err = s.Validate(testDiag(tb, diag))
+			if err != nil {
+				tb.Errorf("%v: %v: %v", tc.Schema, diag, err)
+			}
+		}
+
+		for _, diag := range tc.Fail {
+			err = s.Validate(testDiag(tb, diag))
+			if err == nil {
+				tb.Errorf("%v: %v: expected error", tc.Schema, diag)
+			}
+		}
+	}
+}
+
+func TestViolation(tb *testing.T) {
+	s, err := Parse(`
+people = [* person]
+person = {name: tstr, age: age}
+age = uint .le 150
+`)
+	if err != nil {
+		tb.Fatalf("parse: %v", err)
+	}
+
+	b := testDiag(tb, `[{"name": "a", "age": 30}, {"name": "b", "age": 200}]`)
+
+	err = s.Validate(b)
+
+	var v *Violation
+
+	if !errors.As(err, &v) {
+		tb.Fatalf("expected violation: %v", err)
+	}
+
+	if v.Rule != "age" || v.Err.Code() != cbor.ErrInvalid || v.Err.Index() != 27 {
+		tb.Errorf("violation: %+v  %v", v, err)
+	}
+
+	if !errors.Is(err, v.Err) {
+		tb.Errorf("unwrap: %v", err)
+	}
+
+	b = testDiag(tb, `[{"name": "a", "age": 30}, {"name": "b"}]`)
+
+	err = s.Validate(b)
+	if !errors.As(err, &v) || v.Rule != "person" || v.Err.Index() != 15 || v.Msg != "missing age: age" {
+		tb.Errorf("missing key: %v", err)
+	}
+
+	err = s.Validate(testDiag(tb, `[], 0`))
+	if !errors.Is(err, ErrExtraData) {
+		tb.Errorf("extra data: %v", err)
+	}
+
+	err = s.Validate([]byte{0x81})
+	if !errors.As(err, new(cbor.Error)) {
+		tb.Errorf("malformed: %v", err)
+	}
+
+	i, err := s.ValidateAt(cbor.Decoder{}, testDiag(tb, `1, 5`), 1, "age")
+	if err != nil || i != 2 {
+		tb.Errorf("validate at: %v %v", i, err)
+	}
+
+	for _, name := range []string{"none", "uint .le 150"} {
+		if _, err = s.ValidateAt(cbor.Decoder{}, testDiag(tb, `1`), 0, name); err == nil {
+			tb.Errorf("rule %q: expected error", name)
+		}
+	}
+}
+
+func TestValidateRecursion(tb *testing.T) {
+	for _, tc := range []struct {
+		Schema string
+		Data   []byte
+	}{
+		{`a = {g}  g = (g // int => int)`, []byte{0xa0}},
+		{`a = [g]  g = (g // int)`, []byte{0x80}},
+		{`a = [* a]`, append(bytes.Repeat([]byte{0x81}, 1<<14), 0x80)},
+		{`a = [* a] / {* int => a}`, append(bytes.Repeat([]byte{0xa1, 0x01}, 1<<14), 0x80)},
+	} {
+		s, err := Parse(tc.Schema)
+		if err != nil {
+			tb.Errorf("%v: parse: %v", tc.Schema, err)
+			continue
+		}
+
+		var v *Violation
+
+		err = s.Validate(tc.Data)
+		if !errors.As(err, &v) || v.Msg != "too deep recursion" {
+			tb.Errorf("%v: %v", tc.Schema, err)
+		}
+	}
+}
+
+func testDiag(tb testing.TB, diag string) []byte {
+	tb.Helper()
+
+	b, err := cbor.ParseDiag(diag)
+	if err != nil {
+		tb.Fatalf("parse diag %v: %v", diag, err)
+	}
+
+	return b
+}
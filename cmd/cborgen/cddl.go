@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"nikand.dev/go/cbor/cddl"
+)
+
+// cddlGen converts CDDL rules into Go type declarations.
+type cddlGen struct {
+	s *cddl.Schema
+
+	rules []string // in output order
+	seen  map[string]bool
+
+	buf bytes.Buffer
+}
+
+var primTypes = map[string]string{
+	"uint": "uint64", "nint": "int64", "int": "int64",
+	"bstr": "[]byte", "tstr": "string", "bool": "bool",
+	"float16": "float32", "float32": "float32", "float64": "float64",
+}
+
+// generateCDDL generates types for the named rules and the rules they refer to,
+// and their methods. The first rule is used if names is empty.
+func generateCDDL(spec, pkg string, names []string) ([]byte, error) {
+	s, err := cddl.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		names = s.Rules()[:1]
+	}
+
+	c := &cddlGen{s: s, seen: map[string]bool{}}
+
+	for _, name := range names {
+		c.queue(name)
+	}
+
+	for k := 0; k < len(c.rules); k++ {
+		err = c.decl(c.rules[k])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fset := token.NewFileSet()
+
+	f, err := parser.ParseFile(fset, "cddl.go", "package "+pkg+"\n\n"+c.buf.String(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("generated declarations: %w\n%s", err, c.buf.Bytes())
+	}
+
+	var conf types.Config
+
+	tpkg, err := conf.Check(pkg, fset, []*ast.File{f}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("generated declarations: %w\n%s", err, c.buf.Bytes())
+	}
+
+	g := newGenerator(tpkg)
+	g.schema = true
+
+	for _, name := range c.rules {
+		n := tpkg.Scope().Lookup(goName(name)).Type().(*types.Named)
+
+		switch n.Underlying().(type) {
+		case *types.Struct, *types.Slice, *types.Map:
+			err = g.add(n)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = g.run()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.source(c.buf.Bytes())
+}
+
+func (c *cddlGen) queue(name string) {
+	if !c.seen[name] {
+		c.seen[name] = true
+		c.rules = append(c.rules, name)
+	}
+}
+
+func (c *cddlGen) decl(name string) error {
+	sh, err := c.s.Shape(name)
+	if err != nil {
+		return err
+	}
+
+	var t string
+
+	switch sh.Kind {
+	case cddl.ShapeStruct, cddl.ShapeTuple:
+		t, err = c.structType(sh)
+	default:
+		t, err = c.goType(sh)
+	}
+
+	if err != nil {
+		return fmt.Errorf("rule %v: %w", name, err)
+	}
+
+	fmt.Fprintf(&c.buf, "// %[1]s is the %[2]s rule.\ntype %[1]s %[3]s\n\n", goName(name), name, t)
+
+	return nil
+}
+
+func (c *cddlGen) structType(sh *cddl.Shape) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("struct {\n")
+
+	if sh.Kind == cddl.ShapeTuple {
+		b.WriteString("_ struct{} `cbor:\",toarray\"`\n\n")
+	}
+
+	for j, f := range sh.Fields {
+		t, err := c.memberType(f.Type, f.Optional)
+		if err != nil {
+			return "", fmt.Errorf("member %d: %w", j+1, err)
+		}
+
+		name := goName(f.Name)
+
+		switch k := f.Key.(type) {
+		case nil:
+			if f.Name == "" {
+				name = fmt.Sprintf("Field%d", j+1)
+			}
+		case string:
+			if strings.ContainsAny(k, ",\"`") {
+				return "", fmt.Errorf("%w: key %q", errUnsupported, k)
+			}
+
+			t += fmt.Sprintf(" `cbor:\"%s%s\"`", k, csel(f.Optional, ",omitempty", ""))
+		case int64:
+			name = fmt.Sprintf("Key%d", k)
+			if k < 0 {
+				name = fmt.Sprintf("KeyN%d", -k)
+			}
+
+			t += fmt.Sprintf(" `cbor:\"%d,keyasint%s\"`", k, csel(f.Optional, ",omitempty", ""))
+		}
+
+		fmt.Fprintf(&b, "%s %s\n", name, t)
+	}
+
+	b.WriteString("}")
+
+	return b.String(), nil
+}
+
+// memberType is the type of a field or element, pointer if it's nullable or optional.
+// Nil slices and maps are encoded empty, so they are also pointers if nullable.
+func (c *cddlGen) memberType(sh *cddl.Shape, optional bool) (string, error) {
+	t, err := c.goType(sh)
+	if err != nil {
+		return "", err
+	}
+
+	nilable, nullable, err := c.nilable(sh)
+	if err != nil {
+		return "", err
+	}
+
+	if nullable || optional && !nilable {
+		t = "*" + t
+	}
+
+	return t, nil
+}
+
+func (c *cddlGen) goType(sh *cddl.Shape) (string, error) {
+	switch sh.Kind {
+	case cddl.ShapePrim:
+		if t, ok := primTypes[sh.Name]; ok {
+			return t, nil
+		}
+	case cddl.ShapeRef:
+		c.queue(sh.Name)
+
+		return goName(sh.Name), nil
+	case cddl.ShapeArray:
+		t, err := c.memberType(sh.Elem, false)
+
+		return "[]" + t, err
+	case cddl.ShapeMap:
+		k, err := c.goType(sh.Key)
+		if err != nil {
+			return "", err
+		}
+
+		if nilable, _, _ := c.nilable(sh.Key); nilable {
+			return "", fmt.Errorf("%w: map key %v", errUnsupported, k)
+		}
+
+		v, err := c.memberType(sh.Elem, false)
+
+		return "map[" + k + "]" + v, err
+	case cddl.ShapeStruct, cddl.ShapeTuple:
+		return "", fmt.Errorf("%w: inline map or array members, define a separate rule", errUnsupported)
+	}
+
+	return "", fmt.Errorf("%w: %v", errUnsupported, sh.Name)
+}
+
+// nilable reports whether the type is nil-able, and whether null is allowed.
+func (c *cddlGen) nilable(sh *cddl.Shape) (nilable, nullable bool, err error) {
+	nullable = sh.Nullable
+
+	for depth := 0; sh.Kind == cddl.ShapeRef; depth++ {
+		if depth == 100 {
+			return false, false, fmt.Errorf("%w: %v: recursive alias", errUnsupported, sh.Name)
+		}
+
+		sh, err = c.s.Shape(sh.Name)
+		if err != nil {
+			return false, false, err
+		}
+
+		nullable = nullable || sh.Nullable
+	}
+
+	nilable = sh.Kind == cddl.ShapeArray || sh.Kind == cddl.ShapeMap || sh.Kind == cddl.ShapePrim && sh.Name == "bstr"
+
+	return nilable, nullable, nil
+}
+
+// initialisms are kept upper case in Go names.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "URI": true,
+	"URL": true, "UTF8": true, "UUID": true, "VM": true, "XML": true, "XSRF": true,
+	"XSS": true,
+}
+
+// goName converts CDDL name into exported Go identifier.
+func goName(s string) string {
+	var b strings.Builder
+
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+
+	for _, w := range words {
+		if b.Len() == 0 && w[0] >= '0' && w[0] <= '9' {
+			b.WriteByte('X')
+		}
+
+		if up := strings.ToUpper(w); initialisms[up] {
+			w = up
+		}
+
+		b.WriteString(strings.ToUpper(w[:1]))
+		b.WriteString(w[1:])
+	}
+
+	if b.Len() == 0 {
+		return "X"
+	}
+
+	return b.String()
+}
+
+func csel[T any](c bool, x, y T) T {
+	if c {
+		return x
+	}
+
+	return y
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"nikand.dev/go/cbor/cddl"
+)
+
+func TestCDDLGolden(tb *testing.T) {
+	spec, err := os.ReadFile("internal/example/schema.cddl")
+	if err != nil {
+		tb.Fatalf("read: %v", err)
+	}
+
+	src, err := generateCDDL(string(spec), "example", nil)
+	if err != nil {
+		tb.Fatalf("generate: %v", err)
+	}
+
+	testGolden(tb, "internal/example/schema_cbor.go", src)
+}
+
+func TestCDDLErrors(tb *testing.T) {
+	for _, tc := range []struct {
+		Spec string
+		Err  string
+	}{
+		{`a = {x: any}`, "unsupported type: any"},
+		{`a = {x: {y: int}}`, "inline map or array"},
+		{`a = {* bstr => int}`, "map key []byte"},
+		{`a = {"x,y": int}`, "key"},
+		{`a = int / tstr`, "type choice"},
+		{`a = [? int]`, "occurrence"},
+		{`a = b  b = a`, "invalid recursive type"},
+		{`c = {x: a}  a = b  b = a`, "recursive alias"},
+		{`a = (x: int)`, "not a type"},
+		{`a = [`, "syntax"},
+	} {
+		_, err := generateCDDL(tc.Spec, "p", nil)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			tb.Errorf("%v: %v, wanted %q", tc.Spec, err, tc.Err)
+		}
+	}
+
+	_, err := generateCDDL(`a = int`, "p", []string{"b"})
+	if !errors.Is(err, cddl.ErrUndefined) {
+		tb.Errorf("undefined: %v", err)
+	}
+}
+
+func TestGoName(tb *testing.T) {
+	for _, tc := range [][2]string{
+		{"device-id", "DeviceID"},
+		{"http-url", "HTTPURL"},
+		{"ids", "Ids"},
+		{"a.b_c", "ABC"},
+		{"$kind", "Kind"},
+		{"1st", "X1st"},
+		{"-", "X"},
+	} {
+		if got := goName(tc[0]); got != tc[1] {
+			tb.Errorf("%v: %v, wanted %v", tc[0], got, tc[1])
+		}
+	}
+}
@@ -0,0 +1,1150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// generator writes AppendCBOR and DecodeCBOR methods for named types of a package.
+	generator struct {
+		pkg *types.Package
+
+		queue []*types.Named
+		gen   map[*types.Named]bool
+
+		imports map[string]string // path: name
+		buf     bytes.Buffer
+		tmp     int
+
+		nonNil string // expression omitempty checked to be non-nil
+
+		// schema is set for CDDL types: nil slices and maps are encoded empty
+		// as Null is not allowed by the rule, and map fields without omitempty are required.
+		schema bool
+	}
+
+	// field is a struct field as the reflection based codec sees it.
+	field struct {
+		name      string
+		key       []byte // encoded key
+		keyInt    int64
+		isInt     bool
+		omitEmpty bool
+		tagged    bool
+		path      []*types.Var // embedded structs and the field itself
+		index     []int
+	}
+)
+
+const header = "// Code generated by cborgen. DO NOT EDIT.\n"
+
+var errUnsupported = errors.New("unsupported type")
+
+func newGenerator(pkg *types.Package) *generator {
+	return &generator{
+		pkg:     pkg,
+		gen:     map[*types.Named]bool{},
+		imports: map[string]string{"nikand.dev/go/cbor": "cbor"},
+	}
+}
+
+// add queues the type for generation.
+func (g *generator) add(n *types.Named) error {
+	if g.gen[n] {
+		return nil
+	}
+
+	name := n.Obj().Name()
+
+	if n.Obj().Pkg() != g.pkg || n.TypeParams().Len() != 0 {
+		return fmt.Errorf("%v: %w", name, errUnsupported)
+	}
+
+	switch n.Underlying().(type) {
+	case *types.Struct, *types.Slice, *types.Map, *types.Array:
+	default:
+		return fmt.Errorf("%v: %w: %v", name, errUnsupported, n.Underlying())
+	}
+
+	for _, m := range []string{"AppendCBOR", "DecodeCBOR"} {
+		if g.hasMethod(n, m) {
+			return fmt.Errorf("%v: %v method already defined", name, m)
+		}
+	}
+
+	g.gen[n] = true
+	g.queue = append(g.queue, n)
+
+	return nil
+}
+
+// run generates methods for the queued types and the local struct types they refer to.
+func (g *generator) run() error {
+	for k := 0; k < len(g.queue); k++ {
+		n := g.queue[k]
+
+		var err error
+
+		if st, ok := n.Underlying().(*types.Struct); ok {
+			err = g.structMethods(n, st)
+		} else {
+			err = g.otherMethods(n)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// source returns formatted file with decls and the generated methods.
+func (g *generator) source(decls []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%s\npackage %s\n\n", header, g.pkg.Name())
+
+	paths := make([]string, 0, len(g.imports))
+
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		if isStd(paths[i]) != isStd(paths[j]) {
+			return isStd(paths[i])
+		}
+
+		return paths[i] < paths[j]
+	})
+
+	b.WriteString("import (\n")
+
+	for k, p := range paths {
+		if k != 0 && isStd(paths[k-1]) != isStd(p) {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "%q\n", p)
+	}
+
+	b.WriteString(")\n\n")
+	b.Write(decls)
+	b.Write(g.buf.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, b.Bytes())
+	}
+
+	return src, nil
+}
+
+func (g *generator) structMethods(n *types.Named, st *types.Struct) error {
+	name := g.typ(n)
+
+	fs, toArray, err := g.fields(st)
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	g.p("// AppendCBOR implements cbor.Marshaler.")
+	g.p("func (x *%s) AppendCBOR(e cbor.Encoder, b []byte) []byte {", name)
+	g.tmp = 0
+
+	if toArray {
+		err = g.encArray(fs)
+	} else {
+		err = g.encMap(fs)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	g.p("return b")
+	g.p("}\n")
+
+	g.p("// DecodeCBOR implements cbor.Unmarshaler.")
+	g.p("func (x *%s) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {", name)
+	g.tmp = 0
+	g.imports["fmt"] = "fmt"
+
+	if toArray {
+		err = g.decArray(fs)
+	} else {
+		err = g.decMap(fs)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	g.p("")
+	g.p("if i < 0 {")
+	g.p("return st, cbor.Error(i)")
+	g.p("}\n")
+
+	if !toArray {
+		g.decMissing(fs)
+	}
+
+	g.p("return i, nil")
+	g.p("}\n")
+
+	return nil
+}
+
+func (g *generator) otherMethods(n *types.Named) error {
+	name := g.typ(n)
+
+	g.p("// AppendCBOR implements cbor.Marshaler.")
+	g.p("func (x %s) AppendCBOR(e cbor.Encoder, b []byte) []byte {", name)
+	g.tmp = 0
+
+	err := g.enc("x", n.Underlying())
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	g.p("")
+	g.p("return b")
+	g.p("}\n")
+
+	g.p("// DecodeCBOR implements cbor.Unmarshaler.")
+	g.p("func (x *%s) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {", name)
+	g.p("i = st\n")
+	g.tmp = 0
+
+	err = g.dec("*x", n.Underlying())
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	g.p("")
+	g.p("return i, nil")
+	g.p("}\n")
+
+	return nil
+}
+
+func (g *generator) encMap(fs []field) error {
+	sorted := append([]field{}, fs...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].key, sorted[j].key) < 0
+	})
+
+	needSort := false
+
+	for j := range fs {
+		needSort = needSort || !bytes.Equal(fs[j].key, sorted[j].key)
+	}
+
+	if needSort {
+		g.p("st := len(b)\n")
+	}
+
+	conds := make([]string, len(fs))
+	fixed := 0
+
+	for j, f := range fs {
+		conds[j] = g.cond(f, f.omitEmpty)
+
+		if conds[j] == "" {
+			fixed++
+		}
+	}
+
+	if fixed == len(fs) {
+		g.p("b = e.AppendMap(b, %d)", fixed)
+	} else {
+		g.p("n := %d\n", fixed)
+
+		for _, c := range conds {
+			if c != "" {
+				g.p("if %s {", c)
+				g.p("n++")
+				g.p("}\n")
+			}
+		}
+
+		g.p("b = e.AppendMap(b, n)")
+	}
+
+	for j, f := range fs {
+		g.p("")
+
+		if conds[j] != "" {
+			g.p("if %s {", conds[j])
+		}
+
+		if f.isInt {
+			g.p("b = e.AppendInt64(b, %d)", f.keyInt)
+		} else {
+			g.p("b = e.AppendString(b, %q)", f.name)
+		}
+
+		if f.omitEmpty {
+			g.nonNil = f.expr()
+		}
+
+		err := g.enc(f.expr(), f.typ())
+		g.nonNil = ""
+		if err != nil {
+			return fmt.Errorf("field %v: %w", f.expr()[2:], err)
+		}
+
+		if conds[j] != "" {
+			g.p("}")
+		}
+	}
+
+	if needSort {
+		g.p("")
+		g.p("if e.Flags.Is(cbor.FtDeterministic) {")
+		g.p("b = e.SortMap(b, st)")
+		g.p("}")
+	}
+
+	g.p("")
+
+	return nil
+}
+
+func (g *generator) encArray(fs []field) error {
+	g.p("b = e.AppendArray(b, %d)", len(fs))
+
+	for _, f := range fs {
+		g.p("")
+
+		c := g.cond(f, false)
+		if c != "" {
+			g.p("if %s {", c)
+		}
+
+		err := g.enc(f.expr(), f.typ())
+		if err != nil {
+			return fmt.Errorf("field %v: %w", f.expr()[2:], err)
+		}
+
+		if c != "" {
+			g.p("} else {")
+			g.p("b = e.AppendNull(b)")
+			g.p("}")
+		}
+	}
+
+	g.p("")
+
+	return nil
+}
+
+func (g *generator) decMap(fs []field) error {
+	var names, ints []field
+
+	for _, f := range fs {
+		if f.isInt {
+			ints = append(ints, f)
+		} else {
+			names = append(names, f)
+		}
+	}
+
+	req := g.required(fs)
+
+	g.p("l, i := d.ExpectMap(b, st)")
+	g.check()
+	g.p("")
+
+	if len(req) != 0 {
+		g.p("var has [%d]bool\n", len(req))
+	}
+
+	g.p("for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {")
+	g.p("kst := i\n")
+	g.p("switch d.TagOnly(b, i) {")
+
+	if len(names) != 0 {
+		g.p("case cbor.String:")
+		g.p("var k []byte\n")
+		g.p("k, i = d.ExpectString(b, i)")
+		g.check()
+		g.p("")
+		g.p("switch string(k) {")
+
+		for _, f := range names {
+			g.p("case %q:", f.name)
+			g.decHas(req, f)
+
+			err := g.decField(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		g.p("}")
+	}
+
+	if len(ints) != 0 {
+		g.p("case cbor.Int, cbor.Neg:")
+		g.p("k, ki := d.Signed(b, i)")
+		g.p("i = d.Skip(b, i)\n")
+		g.p("if ki >= 0 {")
+		g.p("switch k {")
+
+		for _, f := range ints {
+			g.p("case %d:", f.keyInt)
+			g.decHas(req, f)
+
+			err := g.decField(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		g.p("}")
+		g.p("}")
+	}
+
+	g.p("default:")
+	g.p("i = d.Skip(b, i)")
+	g.p("}\n")
+	g.p("if d.Flags.Is(cbor.FtDisallowUnknownFields) {")
+	g.p("return kst, fmt.Errorf(\"at %%d: %%w\", kst, cbor.ErrUnknownField)")
+	g.p("}\n")
+	g.p("i = d.Skip(b, i)")
+	g.check()
+	g.p("}")
+
+	return nil
+}
+
+// required returns fields that must be present in the map.
+func (g *generator) required(fs []field) (req []field) {
+	if !g.schema {
+		return nil
+	}
+
+	for _, f := range fs {
+		if !f.omitEmpty {
+			req = append(req, f)
+		}
+	}
+
+	return req
+}
+
+// decHas marks the field as present if it's required.
+func (g *generator) decHas(req []field, f field) {
+	for k, r := range req {
+		if r.expr() == f.expr() {
+			g.p("has[%d] = true\n", k)
+		}
+	}
+}
+
+// decMissing reports the first required field not present in the map.
+func (g *generator) decMissing(fs []field) {
+	for k, f := range g.required(fs) {
+		verb, key := "%q", strconv.Quote(f.name)
+		if f.isInt {
+			verb, key = "%d", strconv.FormatInt(f.keyInt, 10)
+		}
+
+		g.p("if !has[%d] {", k)
+		g.p("return st, fmt.Errorf(\"%%w: missing %s key\", cbor.MakeError(cbor.ErrInvalid, st), %s)", verb, key)
+		g.p("}\n")
+	}
+}
+
+func (g *generator) decArray(fs []field) error {
+	g.p("l, i := d.ExpectArray(b, st)")
+	g.check()
+	g.p("")
+	g.p("for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {")
+	g.p("switch n {")
+
+	for j, f := range fs {
+		g.p("case %d:", j)
+
+		g.alloc(f)
+
+		err := g.dec(f.expr(), f.typ())
+		if err != nil {
+			return fmt.Errorf("field %v: %w", f.expr()[2:], err)
+		}
+	}
+
+	g.p("default:")
+	g.p("if d.Flags.Is(cbor.FtDisallowUnknownFields) {")
+	g.p("return i, fmt.Errorf(\"at %%d: %%w\", i, cbor.ErrUnknownField)")
+	g.p("}\n")
+	g.p("i = d.Skip(b, i)")
+	g.check()
+	g.p("}")
+	g.p("}")
+
+	return nil
+}
+
+func (g *generator) decField(f field) error {
+	g.alloc(f)
+
+	err := g.dec(f.expr(), f.typ())
+	if err != nil {
+		return fmt.Errorf("field %v: %w", f.expr()[2:], err)
+	}
+
+	g.p("")
+	g.p("continue")
+
+	return nil
+}
+
+// alloc allocates nil embedded pointers on the field path.
+func (g *generator) alloc(f field) {
+	for k, v := range f.path[:len(f.path)-1] {
+		if p, ok := v.Type().(*types.Pointer); ok {
+			x := pathExpr(f.path[:k+1])
+
+			g.p("if %s == nil {", x)
+			g.p("%s = new(%s)", x, g.typ(p.Elem()))
+			g.p("}\n")
+		}
+	}
+}
+
+// enc appends x of type t to b.
+func (g *generator) enc(x string, t types.Type) (err error) {
+	if _, ok := t.(*types.Pointer); !ok && g.hasMethod(t, "AppendCBOR") {
+		g.p("b = %s.AppendCBOR(e, b)", paren(x))
+		return nil
+	}
+
+	switch {
+	case isNamed(t, "time", "Time"):
+		g.p("b = e.AppendTime(b, %s, e.Time)", x)
+		return nil
+	case isNamed(t, "time", "Duration"):
+		g.p("b = e.AppendDuration(b, %s)", x)
+		return nil
+	case g.hasMethod(t, "MarshalBinary") || g.hasMethod(t, "MarshalText"):
+		return fmt.Errorf("%w: %v implements encoding marshaler", errUnsupported, g.typ(t))
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			g.p("b = e.AppendBool(b, %s)", conv(x, t, types.Bool))
+		case u.Kind() == types.Uintptr:
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		case u.Info()&types.IsUnsigned != 0:
+			g.p("b = e.AppendUint64(b, %s)", conv(x, t, types.Uint64))
+		case u.Info()&types.IsInteger != 0:
+			g.p("b = e.AppendInt64(b, %s)", conv(x, t, types.Int64))
+		case u.Kind() == types.Float32:
+			g.p("b = e.AppendFloat32(b, %s)", conv(x, t, types.Float32))
+		case u.Kind() == types.Float64:
+			g.p("b = e.AppendFloat(b, %s)", conv(x, t, types.Float64))
+		case u.Kind() == types.String:
+			g.p("b = e.AppendString(b, %s)", conv(x, t, types.String))
+		default:
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		}
+	case *types.Pointer:
+		err = g.encNil(x, false, func() error {
+			if g.hasMethod(u.Elem(), "AppendCBOR") || g.local(u.Elem()) {
+				return g.enc(x, u.Elem())
+			}
+
+			return g.enc("*"+x, u.Elem())
+		})
+	case *types.Slice:
+		err = g.encNil(x, g.schema, func() error {
+			if isByte(u.Elem()) {
+				g.p("b = e.AppendBytes(b, %s)", x)
+				return nil
+			}
+
+			return g.encElems(x, u.Elem(), "len("+x+")")
+		})
+	case *types.Array:
+		if isByte(u.Elem()) {
+			g.p("b = e.AppendBytes(b, %s[:])", paren(x))
+			break
+		}
+
+		err = g.encElems(x, u.Elem(), strconv.FormatInt(u.Len(), 10))
+	case *types.Map:
+		err = g.encNil(x, g.schema, func() error {
+			n := g.next()
+
+			g.p("st%d := len(b)", n)
+			g.p("b = e.AppendMap(b, len(%s))\n", x)
+			g.p("for k%d, v%d := range %s {", n, n, x)
+
+			err := g.enc(fmt.Sprintf("k%d", n), u.Key())
+			if err == nil {
+				err = g.enc(fmt.Sprintf("v%d", n), u.Elem())
+			}
+
+			g.p("}\n")
+			g.p("if e.Flags.Is(cbor.FtDeterministic) {")
+			g.p("b = e.SortMap(b, st%d)", n)
+			g.p("}")
+
+			return err
+		})
+	case *types.Struct:
+		n, ok := t.(*types.Named)
+		if !ok || !g.local(n) {
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		}
+
+		err = g.add(n)
+		if err == nil {
+			g.p("b = %s.AppendCBOR(e, b)", paren(x))
+		}
+	default:
+		return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+	}
+
+	return err
+}
+
+// encNil appends Null if x is nil and calls f otherwise.
+// The check is omitted if x is known to be non-nil or nil is to be encoded empty.
+func (g *generator) encNil(x string, empty bool, f func() error) error {
+	if empty || x == g.nonNil {
+		return f()
+	}
+
+	g.p("if %s == nil {", x)
+	g.p("b = e.AppendNull(b)")
+	g.p("} else {")
+
+	err := f()
+
+	g.p("}")
+
+	return err
+}
+
+func (g *generator) encElems(x string, elem types.Type, l string) error {
+	n := g.next()
+
+	g.p("b = e.AppendArray(b, %s)\n", l)
+	g.p("for _, v%d := range %s {", n, x)
+
+	err := g.enc(fmt.Sprintf("v%d", n), elem)
+
+	g.p("}")
+
+	return err
+}
+
+// dec decodes the value at i into addressable x of type t.
+func (g *generator) dec(x string, t types.Type) (err error) {
+	if _, ok := t.(*types.Pointer); !ok && g.hasMethod(t, "DecodeCBOR") {
+		g.decMethod(x)
+		return nil
+	}
+
+	switch {
+	case isNamed(t, "time", "Time"):
+		g.p("%s, i = d.Time(b, i)", x)
+		g.check()
+
+		return nil
+	case isNamed(t, "time", "Duration"):
+		g.p("%s, i = d.Duration(b, i)", x)
+		g.check()
+
+		return nil
+	case g.hasMethod(t, "UnmarshalBinary") || g.hasMethod(t, "UnmarshalText"):
+		return fmt.Errorf("%w: %v implements encoding unmarshaler", errUnsupported, g.typ(t))
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			g.decBasic(x, t, "ExpectBool", types.Bool)
+		case u.Kind() == types.Uintptr:
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		case u.Info()&types.IsInteger != 0:
+			name := types.Typ[u.Kind()].Name() // not byte or rune
+			g.decBasic(x, t, strings.ToUpper(name[:1])+name[1:], u.Kind())
+		case u.Kind() == types.Float32, u.Kind() == types.Float64:
+			g.decBasic(x, t, "ExpectFloat", types.Float64)
+		case u.Kind() == types.String:
+			g.decBasic(x, t, "ExpectString", types.Invalid)
+		default:
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		}
+	case *types.Pointer:
+		err = g.decNull(x, func() error {
+			g.p("if %s == nil {", x)
+			g.p("%s = new(%s)", x, g.typ(u.Elem()))
+			g.p("}\n")
+
+			if g.hasMethod(u.Elem(), "DecodeCBOR") || g.local(u.Elem()) {
+				return g.dec(x, u.Elem())
+			}
+
+			return g.dec("*"+x, u.Elem())
+		})
+	case *types.Slice:
+		err = g.decNull(x, func() error {
+			n := g.next()
+
+			if isByte(u.Elem()) {
+				g.p("var v%d []byte\n", n)
+				g.p("v%d, i = d.ExpectBytes(b, i)", n)
+				g.check()
+				g.p("")
+				g.p("%s = append(%s{}, v%d...)", x, g.typ(t), n)
+
+				return nil
+			}
+
+			g.p("var l%d int\n", n)
+			g.p("l%d, i = d.ExpectArray(b, i)", n)
+			g.check()
+			g.p("")
+			g.p("%s = %s[:0]\n", x, paren(x))
+			g.p("for n%d := 0; l%[1]d < 0 && !d.Break(b, &i) || n%[1]d < l%[1]d; n%[1]d++ {", n)
+			g.p("var v%d %s\n", n, g.typ(u.Elem()))
+
+			err := g.dec(fmt.Sprintf("v%d", n), u.Elem())
+			if err != nil {
+				return err
+			}
+
+			g.p("")
+			g.p("%s = append(%s, v%d)", x, x, n)
+			g.p("}\n")
+			g.check()
+			g.p("")
+			g.p("if %s == nil {", x)
+			g.p("%s = %s{}", x, g.typ(t))
+			g.p("}")
+
+			return nil
+		})
+	case *types.Array:
+		n := g.next()
+
+		g.p("%s = %s{}\n", x, g.typ(t))
+
+		if isByte(u.Elem()) {
+			g.p("var v%d []byte\n", n)
+			g.p("v%d, i = d.ExpectBytes(b, i)", n)
+			g.check()
+			g.p("")
+			g.p("copy(%s[:], v%d)", paren(x), n)
+
+			return nil
+		}
+
+		g.p("var l%d int\n", n)
+		g.p("l%d, i = d.ExpectArray(b, i)", n)
+		g.check()
+		g.p("")
+		g.p("for n%d := 0; l%[1]d < 0 && !d.Break(b, &i) || n%[1]d < l%[1]d; n%[1]d++ {", n)
+		g.p("if n%d >= %d {", n, u.Len())
+		g.p("i = d.Skip(b, i)")
+		g.check()
+		g.p("")
+		g.p("continue")
+		g.p("}\n")
+
+		err = g.dec(fmt.Sprintf("%s[n%d]", paren(x), n), u.Elem())
+
+		g.p("}\n")
+		g.check()
+	case *types.Map:
+		err = g.decNull(x, func() error {
+			n := g.next()
+
+			g.p("var l%d int\n", n)
+			g.p("l%d, i = d.ExpectMap(b, i)", n)
+			g.check()
+			g.p("")
+			g.p("if %s == nil {", x)
+			g.p("%s = make(%s)", x, g.typ(t))
+			g.p("}\n")
+			g.p("for n%d := 0; l%[1]d < 0 && !d.Break(b, &i) || n%[1]d < l%[1]d; n%[1]d++ {", n)
+			g.p("var k%d %s\n", n, g.typ(u.Key()))
+
+			err := g.dec(fmt.Sprintf("k%d", n), u.Key())
+			if err != nil {
+				return err
+			}
+
+			g.p("")
+			g.p("var v%d %s\n", n, g.typ(u.Elem()))
+
+			err = g.dec(fmt.Sprintf("v%d", n), u.Elem())
+			if err != nil {
+				return err
+			}
+
+			g.p("")
+			g.p("%s[k%d] = v%[2]d", paren(x), n)
+			g.p("}\n")
+			g.check()
+
+			return nil
+		})
+	case *types.Struct:
+		n, ok := t.(*types.Named)
+		if !ok || !g.local(n) {
+			return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+		}
+
+		err = g.add(n)
+		if err == nil {
+			g.decMethod(x)
+		}
+	default:
+		return fmt.Errorf("%w: %v", errUnsupported, g.typ(t))
+	}
+
+	return err
+}
+
+// decBasic decodes x using Decoder method fn returning basic type res, or []byte if res is Invalid.
+func (g *generator) decBasic(x string, t types.Type, fn string, res types.BasicKind) {
+	if res != types.Invalid && types.Identical(t, types.Typ[res]) {
+		g.p("%s, i = d.%s(b, i)", x, fn)
+		g.check()
+
+		return
+	}
+
+	n := g.next()
+	rt := "[]byte"
+
+	if res != types.Invalid {
+		rt = types.Typ[res].Name()
+	}
+
+	g.p("var v%d %s\n", n, rt)
+	g.p("v%d, i = d.%s(b, i)", n, fn)
+	g.check()
+	g.p("")
+	g.p("%s = %s(v%d)", x, g.typ(t), n)
+}
+
+func (g *generator) decMethod(x string) {
+	g.p("i, err = %s.DecodeCBOR(d, b, i)", paren(x))
+	g.p("if err != nil {")
+	g.p("return i, err")
+	g.p("}")
+}
+
+// decNull sets x to nil on Null and calls f otherwise.
+func (g *generator) decNull(x string, f func() error) error {
+	g.p("if d.TagRaw(b, i) == cbor.Simple|cbor.Null {")
+	g.p("%s = nil", x)
+	g.p("i++")
+	g.p("} else {")
+
+	err := f()
+
+	g.p("}")
+
+	return err
+}
+
+func (g *generator) check() {
+	g.p("if i < 0 {")
+	g.p("return st, cbor.Error(i)")
+	g.p("}")
+}
+
+// cond returns the condition the field is encoded on, or "" if it's always encoded.
+func (g *generator) cond(f field, omitEmpty bool) string {
+	var cs []string
+
+	for k, v := range f.path[:len(f.path)-1] {
+		if _, ok := v.Type().(*types.Pointer); ok {
+			cs = append(cs, pathExpr(f.path[:k+1])+" != nil")
+		}
+	}
+
+	if omitEmpty {
+		if c := nonEmpty(f.expr(), f.typ()); c != "" {
+			cs = append(cs, c)
+		}
+	}
+
+	return strings.Join(cs, " && ")
+}
+
+// fields collects struct fields the same way the reflection based codec does.
+func (g *generator) fields(st *types.Struct) (fs []field, toArray bool, err error) {
+	var all []field
+
+	var walk func(st *types.Struct, path []*types.Var, index []int, visited map[types.Type]bool) error
+
+	walk = func(st *types.Struct, path []*types.Var, index []int, visited map[types.Type]bool) error {
+		for i := 0; i < st.NumFields(); i++ {
+			sf := st.Field(i)
+
+			tag := reflect.StructTag(st.Tag(i)).Get("cbor")
+			if tag == "-" {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+
+			if sf.Name() == "_" {
+				if len(index) == 0 && hasOpt(opts, "toarray") {
+					toArray = true
+				}
+
+				continue
+			}
+
+			ft := sf.Type()
+			if p, ok := ft.(*types.Pointer); ok {
+				ft = p.Elem()
+			}
+
+			if est, ok := ft.Underlying().(*types.Struct); ok && sf.Embedded() && name == "" {
+				if visited[ft] {
+					continue
+				}
+
+				visited[ft] = true
+
+				err := walk(est, append(path[:len(path):len(path)], sf), append(index[:len(index):len(index)], i), visited)
+				if err != nil {
+					return err
+				}
+
+				delete(visited, ft)
+
+				continue
+			}
+
+			if !sf.Exported() {
+				continue
+			}
+
+			f := field{
+				name:      name,
+				tagged:    name != "",
+				omitEmpty: hasOpt(opts, "omitempty"),
+				path:      append(path[:len(path):len(path)], sf),
+				index:     append(index[:len(index):len(index)], i),
+			}
+
+			if f.name == "" {
+				f.name = sf.Name()
+			}
+
+			var e cbor.Encoder
+
+			if hasOpt(opts, "keyasint") {
+				f.keyInt, err = strconv.ParseInt(f.name, 10, 64)
+				if err != nil {
+					return fmt.Errorf("%v: keyasint: %w", sf.Name(), err)
+				}
+
+				f.isInt = true
+				f.key = e.AppendInt64(nil, f.keyInt)
+			} else {
+				f.key = e.AppendString(nil, f.name)
+			}
+
+			all = append(all, f)
+		}
+
+		return nil
+	}
+
+	err = walk(st, nil, nil, map[types.Type]bool{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// keep dominant fields only: the shallowest, tagged preferred, ambiguous dropped
+
+	depths := map[string]int{}
+
+	for _, f := range all {
+		if d, ok := depths[string(f.key)]; !ok || len(f.index) < d {
+			depths[string(f.key)] = len(f.index)
+		}
+	}
+
+	for _, f := range all {
+		if len(f.index) != depths[string(f.key)] {
+			continue
+		}
+
+		var same, tagged int
+
+		for _, h := range all {
+			if len(h.index) == len(f.index) && bytes.Equal(h.key, f.key) {
+				same++
+
+				if h.tagged {
+					tagged++
+				}
+			}
+		}
+
+		if same == 1 || tagged == 1 && f.tagged {
+			fs = append(fs, f)
+		}
+	}
+
+	return fs, toArray, nil
+}
+
+func (g *generator) hasMethod(t types.Type, name string) bool {
+	if n, ok := t.(*types.Named); ok && g.gen[n] {
+		return true
+	}
+
+	obj, _, _ := types.LookupFieldOrMethod(t, true, g.pkg, name)
+	_, ok := obj.(*types.Func)
+
+	return ok
+}
+
+// local reports whether t is a struct type of the package methods can be generated for.
+func (g *generator) local(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok || n.Obj().Pkg() != g.pkg || n.TypeParams().Len() != 0 {
+		return false
+	}
+
+	_, ok = n.Underlying().(*types.Struct)
+
+	return ok
+}
+
+func (g *generator) typ(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+
+		g.imports[p.Path()] = p.Name()
+
+		return p.Name()
+	})
+}
+
+func (g *generator) next() int {
+	g.tmp++
+	return g.tmp
+}
+
+func (g *generator) p(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (f field) expr() string { return pathExpr(f.path) }
+
+func (f field) typ() types.Type { return f.path[len(f.path)-1].Type() }
+
+func pathExpr(path []*types.Var) string {
+	x := "x"
+
+	for _, v := range path {
+		x += "." + v.Name()
+	}
+
+	return x
+}
+
+// nonEmpty returns the condition x is not empty as omitempty sees it.
+func nonEmpty(x string, t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return x
+		case u.Info()&types.IsString != 0:
+			return x + ` != ""`
+		case u.Info()&types.IsNumeric != 0:
+			return x + " != 0"
+		}
+	case *types.Slice, *types.Map:
+		return "len(" + x + ") != 0"
+	case *types.Array:
+		if u.Len() == 0 {
+			return "false"
+		}
+	case *types.Pointer, *types.Interface:
+		return x + " != nil"
+	}
+
+	return ""
+}
+
+// conv converts x to the basic type if t differs from it.
+func conv(x string, t types.Type, to types.BasicKind) string {
+	if types.Identical(t, types.Typ[to]) {
+		return x
+	}
+
+	return types.Typ[to].Name() + "(" + x + ")"
+}
+
+func paren(x string) string {
+	if strings.HasPrefix(x, "*") {
+		return "(" + x + ")"
+	}
+
+	return x
+}
+
+func isByte(t types.Type) bool {
+	return types.Identical(t, types.Typ[types.Uint8])
+}
+
+func isNamed(t types.Type, pkg, name string) bool {
+	n, ok := t.(*types.Named)
+
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == pkg && n.Obj().Name() == name
+}
+
+func isStd(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+
+	return !strings.Contains(first, ".")
+}
+
+func hasOpt(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGoGolden(tb *testing.T) {
+	src, err := generateGo("internal/example", []string{"Person", "Tags"}, "types_cbor.go")
+	if err != nil {
+		tb.Fatalf("generate: %v", err)
+	}
+
+	testGolden(tb, "internal/example/types_cbor.go", src)
+}
+
+func TestGoErrors(tb *testing.T) {
+	for _, tc := range []struct {
+		Src  string
+		Type string
+		Err  string
+	}{
+		{`type T struct{ A any }`, "T", "field A: unsupported type: any"},
+		{`type T struct{ A chan int }`, "T", "unsupported type: chan int"},
+		{`type T struct{ A complex64 }`, "T", "unsupported type: complex64"},
+		{`type T struct{ A uintptr }`, "T", "unsupported type: uintptr"},
+		{`type T struct{ A struct{} }`, "T", "unsupported type: struct{}"},
+		{`type T[X any] struct{ A X }`, "T", "unsupported type"},
+		{`type T struct{ A int ` + "`cbor:\"x,keyasint\"`" + ` }`, "T", "keyasint"},
+		{`import "net"; type T struct{ A net.IP }`, "T", "implements encoding marshaler"},
+		{`import "nikand.dev/go/cbor"; type T struct{}; func (T) AppendCBOR(e cbor.Encoder, b []byte) []byte { return b }`, "T", "AppendCBOR method already defined"},
+		{`type T int`, "T", "unsupported type: int"},
+		{`type T struct{}`, "U", "type U not found"},
+		{`type T struct{}; var x int = "s"`, "T", "cannot use"},
+		{`type T struct{}; type U struct{}; func f(u U) { _ = u.AppendCBOR }`, "T", "type U has no field or method AppendCBOR"},
+	} {
+		dir := tb.TempDir()
+
+		err := os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p; "+tc.Src+"\n"), 0o644)
+		if err != nil {
+			tb.Fatalf("write: %v", err)
+		}
+
+		_, err = generateGo(dir, []string{tc.Type}, "")
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			tb.Errorf("%v: %v, wanted %q", tc.Src, err, tc.Err)
+		}
+	}
+}
+
+func TestRun(tb *testing.T) {
+	dir := tb.TempDir()
+
+	for name, src := range map[string]string{
+		"p.go":      "package p\n\ntype T struct{ A []int }\n\nfunc f(t T) { _ = t.AppendCBOR }\n",
+		"ignore.go": "//go:build ignore\n\npackage main\n",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644)
+		if err != nil {
+			tb.Fatalf("write: %v", err)
+		}
+	}
+
+	err := run(dir, "T", "", "", "t_cbor.go")
+	if err != nil {
+		tb.Fatalf("run: %v", err)
+	}
+
+	// previous output is skipped
+	err = run(dir, "T", "", "", "t_cbor.go")
+	if err != nil {
+		tb.Fatalf("second run: %v", err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(dir, "t_cbor.go"))
+	if err != nil || !bytes.HasPrefix(src, []byte(header)) || !bytes.Contains(src, []byte("func (x *T) DecodeCBOR(")) {
+		tb.Errorf("output: %v\n%s", err, src)
+	}
+
+	err = run(dir, "", "none.cddl", "", "-")
+	if !errors.Is(err, os.ErrNotExist) {
+		tb.Errorf("missing cddl: %v", err)
+	}
+}
+
+func testGolden(tb testing.TB, name string, src []byte) {
+	tb.Helper()
+
+	if *update {
+		err := os.WriteFile(name, src, 0o644)
+		if err != nil {
+			tb.Fatalf("update: %v", err)
+		}
+
+		return
+	}
+
+	exp, err := os.ReadFile(name)
+	if err != nil {
+		tb.Fatalf("read golden: %v", err)
+	}
+
+	if !bytes.Equal(src, exp) {
+		tb.Errorf("%v is out of date, run go generate or go test -update\n%s", name, src)
+	}
+}
@@ -0,0 +1,203 @@
+package example
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"nikand.dev/go/cbor"
+	"nikand.dev/go/cbor/cddl"
+)
+
+type (
+	codec interface {
+		cbor.Marshaler
+		cbor.Unmarshaler
+	}
+
+	// method-less copies encoded by reflection
+	plainPerson  Person
+	plainAddress Address
+	plainTags    Tags
+	plainReport  Report
+	plainReading Reading
+)
+
+func TestMatchReflection(tb *testing.T) {
+	email := "a@b.c"
+	seq := uint64(7)
+
+	p := Person{
+		Name:   "Alice",
+		Age:    -30,
+		Email:  &email,
+		Tags:   Tags{"a", "b"},
+		Attrs:  map[string]int64{"x": 1, "y": -2, "z": 3},
+		Avatar: []byte{1, 2},
+		Home:   &Address{Street: "Main", Zip: [4]byte{1, 2, 3, 4}, Geo: [2]float64{1.5, -2.5}},
+		Born:   time.Unix(1700000000, 0).UTC(),
+		Kind:   3,
+		Meta:   Meta{ID: 10, Score: 0.5},
+	}
+
+	r := Report{
+		Device:   "dev",
+		Seq:      &seq,
+		Readings: []Reading{{Sensor: "t", Value: 21.5, At: 100}, {Sensor: "h", Value: 0.25}},
+		Labels:   map[string]string{"b": "2", "a": "1"},
+		Key1:     true,
+		KeyN1:    []byte{9},
+		Location: &Point{Lat: 1, Lon: 2},
+	}
+
+	for _, tc := range []struct {
+		Name  string
+		Gen   codec
+		Plain any
+		Into  codec
+	}{
+		{"person", &p, (*plainPerson)(&p), new(Person)},
+		{"empty_person", &Person{Born: time.Unix(0, 0).UTC()}, &plainPerson{Born: time.Unix(0, 0).UTC()}, new(Person)},
+		{"address", p.Home, (*plainAddress)(p.Home), new(Address)},
+		{"tags", &p.Tags, (*plainTags)(&p.Tags), new(Tags)},
+		{"nil_tags", new(Tags), new(plainTags), new(Tags)},
+		{"report", &r, (*plainReport)(&r), new(Report)},
+		{"bare_report", &Report{Readings: []Reading{{}}}, &plainReport{Readings: []Reading{{}}}, new(Report)},
+		{"reading", &r.Readings[0], (*plainReading)(&r.Readings[0]), new(Reading)},
+	} {
+		tb.Run(tc.Name, func(tb *testing.T) {
+			e := cbor.MakeEncoder()
+			e.Flags |= cbor.FtDeterministic
+
+			exp, err := e.AppendValue(nil, tc.Plain)
+			if err != nil {
+				tb.Fatalf("reflection: %v", err)
+			}
+
+			b := tc.Gen.AppendCBOR(e, nil)
+			if !bytes.Equal(b, exp) {
+				tb.Errorf("encoded\n%s\nwanted\n%s", cbor.Decoder{}.Diag(b), cbor.Decoder{}.Diag(exp))
+			}
+
+			b = tc.Gen.AppendCBOR(cbor.MakeEncoder(), nil)
+
+			i, err := tc.Into.DecodeCBOR(cbor.MakeDecoder(), b, 0)
+			if err != nil || i != len(b) {
+				tb.Fatalf("decode: %v %v/%v", err, i, len(b))
+			}
+
+			if !reflect.DeepEqual(tc.Into, tc.Gen) {
+				tb.Errorf("decoded\n%+v\nwanted\n%+v", tc.Into, tc.Gen)
+			}
+
+			plain := reflect.New(reflect.TypeOf(tc.Plain).Elem())
+
+			_, err = cbor.MakeDecoder().DecodeValue(b, 0, plain.Interface())
+			if err != nil || !reflect.DeepEqual(plain.Interface(), tc.Plain) {
+				tb.Errorf("reflection decoded %v\n%#v\nwanted\n%#v", err, plain.Interface(), tc.Plain)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(tb *testing.T) {
+	d := cbor.MakeDecoder()
+
+	var p Person
+
+	b := testDiag(tb, `{"name": "a", "x": [1], 5: 2, h'00': 1}`)
+
+	i, err := p.DecodeCBOR(d, b, 0)
+	if err != nil || i != len(b) || p.Name != "a" {
+		tb.Errorf("unknown fields: %v %v  %+v", i, err, p)
+	}
+
+	d.Flags |= cbor.FtDisallowUnknownFields
+
+	_, err = p.DecodeCBOR(d, b, 0)
+	if !errors.Is(err, cbor.ErrUnknownField) {
+		tb.Errorf("disallow unknown: %v", err)
+	}
+
+	_, err = new(Reading).DecodeCBOR(d, testDiag(tb, `["a", 1.5, 1, 2]`), 0)
+	if !errors.Is(err, cbor.ErrUnknownField) {
+		tb.Errorf("disallow unknown array: %v", err)
+	}
+
+	for _, diag := range []string{`[]`, `{"name": 1}`, `{"age": "1"}`, `{1: [1]}`, `{"id": -1}`, `{"tags": ["a", 1]}`} {
+		var e cbor.Error
+
+		_, err = p.DecodeCBOR(d, testDiag(tb, diag), 0)
+		if !errors.As(err, &e) || e.Code() != cbor.ErrType && e.Code() != cbor.ErrOverflow {
+			tb.Errorf("%v: %v", diag, err)
+		}
+	}
+
+	var r Report
+
+	b = testDiag(tb, `{_ "device": (_ "d", "e"), "readings": [_ ["a", 1.0, 2]], 1: false, "location": null}`)
+
+	i, err = r.DecodeCBOR(d, b, 0)
+	if err != nil || i != len(b) || r.Device != "de" || len(r.Readings) != 1 || r.Location != nil {
+		tb.Errorf("indefinite: %v %v  %+v", i, err, r)
+	}
+
+	for _, diag := range []string{`{}`, `{"device": "d", "readings": [], 1: true}`, `{"device": "d", "readings": [], "location": null}`} {
+		var e cbor.Error
+
+		_, err = r.DecodeCBOR(d, testDiag(tb, diag), 0)
+		if !errors.As(err, &e) || e.Code() != cbor.ErrInvalid || !strings.Contains(err.Error(), "missing") {
+			tb.Errorf("%v: %v", diag, err)
+		}
+	}
+}
+
+func TestSchema(tb *testing.T) {
+	spec, err := os.ReadFile("schema.cddl")
+	if err != nil {
+		tb.Fatalf("read: %v", err)
+	}
+
+	s, err := cddl.Parse(string(spec))
+	if err != nil {
+		tb.Fatalf("parse: %v", err)
+	}
+
+	seq := uint64(7)
+
+	for _, r := range []*Report{
+		{Device: "d"},
+		{Device: "d", Labels: map[string]string{}, KeyN1: []byte{}},
+		{
+			Device:   "dev",
+			Seq:      &seq,
+			Readings: []Reading{{Sensor: "t", Value: 21.5, At: 100}},
+			Labels:   map[string]string{"a": "1"},
+			Key1:     true,
+			KeyN1:    []byte{9},
+			Location: &Point{Lat: 1, Lon: 2},
+		},
+	} {
+		b := r.AppendCBOR(cbor.Encoder{}, nil)
+
+		err = s.Validate(b)
+		if err != nil {
+			tb.Errorf("%+v: %v\n%s", r, err, cbor.Decoder{}.Diag(b))
+		}
+	}
+}
+
+func testDiag(tb testing.TB, diag string) []byte {
+	tb.Helper()
+
+	b, err := cbor.ParseDiag(diag)
+	if err != nil {
+		tb.Fatalf("parse diag %v: %v", diag, err)
+	}
+
+	return b
+}
@@ -0,0 +1,446 @@
+// Code generated by cborgen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+// Report is the report rule.
+type Report struct {
+	Device   DeviceID          `cbor:"device"`
+	Seq      *uint64           `cbor:"seq,omitempty"`
+	Readings []Reading         `cbor:"readings"`
+	Labels   map[string]string `cbor:"labels,omitempty"`
+	Key1     bool              `cbor:"1,keyasint"`
+	KeyN1    []byte            `cbor:"-1,keyasint,omitempty"`
+	Location *Point            `cbor:"location"`
+}
+
+// DeviceID is the device-id rule.
+type DeviceID string
+
+// Reading is the reading rule.
+type Reading struct {
+	_ struct{} `cbor:",toarray"`
+
+	Sensor string
+	Value  float64
+	At     uint64
+}
+
+// Point is the point rule.
+type Point struct {
+	_ struct{} `cbor:",toarray"`
+
+	Lat float64
+	Lon float64
+}
+
+// AppendCBOR implements cbor.Marshaler.
+func (x *Report) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	st := len(b)
+
+	n := 4
+
+	if x.Seq != nil {
+		n++
+	}
+
+	if len(x.Labels) != 0 {
+		n++
+	}
+
+	if len(x.KeyN1) != 0 {
+		n++
+	}
+
+	b = e.AppendMap(b, n)
+
+	b = e.AppendString(b, "device")
+	b = e.AppendString(b, string(x.Device))
+
+	if x.Seq != nil {
+		b = e.AppendString(b, "seq")
+		b = e.AppendUint64(b, *x.Seq)
+	}
+
+	b = e.AppendString(b, "readings")
+	b = e.AppendArray(b, len(x.Readings))
+
+	for _, v1 := range x.Readings {
+		b = v1.AppendCBOR(e, b)
+	}
+
+	if len(x.Labels) != 0 {
+		b = e.AppendString(b, "labels")
+		st2 := len(b)
+		b = e.AppendMap(b, len(x.Labels))
+
+		for k2, v2 := range x.Labels {
+			b = e.AppendString(b, k2)
+			b = e.AppendString(b, v2)
+		}
+
+		if e.Flags.Is(cbor.FtDeterministic) {
+			b = e.SortMap(b, st2)
+		}
+	}
+
+	b = e.AppendInt64(b, 1)
+	b = e.AppendBool(b, x.Key1)
+
+	if len(x.KeyN1) != 0 {
+		b = e.AppendInt64(b, -1)
+		b = e.AppendBytes(b, x.KeyN1)
+	}
+
+	b = e.AppendString(b, "location")
+	if x.Location == nil {
+		b = e.AppendNull(b)
+	} else {
+		b = x.Location.AppendCBOR(e, b)
+	}
+
+	if e.Flags.Is(cbor.FtDeterministic) {
+		b = e.SortMap(b, st)
+	}
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Report) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectMap(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	var has [4]bool
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {
+		kst := i
+
+		switch d.TagOnly(b, i) {
+		case cbor.String:
+			var k []byte
+
+			k, i = d.ExpectString(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			switch string(k) {
+			case "device":
+				has[0] = true
+
+				var v1 []byte
+
+				v1, i = d.ExpectString(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				x.Device = DeviceID(v1)
+
+				continue
+			case "seq":
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Seq = nil
+					i++
+				} else {
+					if x.Seq == nil {
+						x.Seq = new(uint64)
+					}
+
+					*x.Seq, i = d.Uint64(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+				}
+
+				continue
+			case "readings":
+				has[1] = true
+
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Readings = nil
+					i++
+				} else {
+					var l2 int
+
+					l2, i = d.ExpectArray(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					x.Readings = x.Readings[:0]
+
+					for n2 := 0; l2 < 0 && !d.Break(b, &i) || n2 < l2; n2++ {
+						var v2 Reading
+
+						i, err = v2.DecodeCBOR(d, b, i)
+						if err != nil {
+							return i, err
+						}
+
+						x.Readings = append(x.Readings, v2)
+					}
+
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					if x.Readings == nil {
+						x.Readings = []Reading{}
+					}
+				}
+
+				continue
+			case "labels":
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Labels = nil
+					i++
+				} else {
+					var l3 int
+
+					l3, i = d.ExpectMap(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					if x.Labels == nil {
+						x.Labels = make(map[string]string)
+					}
+
+					for n3 := 0; l3 < 0 && !d.Break(b, &i) || n3 < l3; n3++ {
+						var k3 string
+
+						var v4 []byte
+
+						v4, i = d.ExpectString(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						k3 = string(v4)
+
+						var v3 string
+
+						var v5 []byte
+
+						v5, i = d.ExpectString(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						v3 = string(v5)
+
+						x.Labels[k3] = v3
+					}
+
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+				}
+
+				continue
+			case "location":
+				has[3] = true
+
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Location = nil
+					i++
+				} else {
+					if x.Location == nil {
+						x.Location = new(Point)
+					}
+
+					i, err = x.Location.DecodeCBOR(d, b, i)
+					if err != nil {
+						return i, err
+					}
+				}
+
+				continue
+			}
+		case cbor.Int, cbor.Neg:
+			k, ki := d.Signed(b, i)
+			i = d.Skip(b, i)
+
+			if ki >= 0 {
+				switch k {
+				case 1:
+					has[2] = true
+
+					x.Key1, i = d.ExpectBool(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					continue
+				case -1:
+					if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+						x.KeyN1 = nil
+						i++
+					} else {
+						var v6 []byte
+
+						v6, i = d.ExpectBytes(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						x.KeyN1 = append([]byte{}, v6...)
+					}
+
+					continue
+				}
+			}
+		default:
+			i = d.Skip(b, i)
+		}
+
+		if d.Flags.Is(cbor.FtDisallowUnknownFields) {
+			return kst, fmt.Errorf("at %d: %w", kst, cbor.ErrUnknownField)
+		}
+
+		i = d.Skip(b, i)
+		if i < 0 {
+			return st, cbor.Error(i)
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	if !has[0] {
+		return st, fmt.Errorf("%w: missing %q key", cbor.MakeError(cbor.ErrInvalid, st), "device")
+	}
+
+	if !has[1] {
+		return st, fmt.Errorf("%w: missing %q key", cbor.MakeError(cbor.ErrInvalid, st), "readings")
+	}
+
+	if !has[2] {
+		return st, fmt.Errorf("%w: missing %d key", cbor.MakeError(cbor.ErrInvalid, st), 1)
+	}
+
+	if !has[3] {
+		return st, fmt.Errorf("%w: missing %q key", cbor.MakeError(cbor.ErrInvalid, st), "location")
+	}
+
+	return i, nil
+}
+
+// AppendCBOR implements cbor.Marshaler.
+func (x *Reading) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	b = e.AppendArray(b, 3)
+
+	b = e.AppendString(b, x.Sensor)
+
+	b = e.AppendFloat(b, x.Value)
+
+	b = e.AppendUint64(b, x.At)
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Reading) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectArray(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {
+		switch n {
+		case 0:
+			var v1 []byte
+
+			v1, i = d.ExpectString(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			x.Sensor = string(v1)
+		case 1:
+			x.Value, i = d.ExpectFloat(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		case 2:
+			x.At, i = d.Uint64(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		default:
+			if d.Flags.Is(cbor.FtDisallowUnknownFields) {
+				return i, fmt.Errorf("at %d: %w", i, cbor.ErrUnknownField)
+			}
+
+			i = d.Skip(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// AppendCBOR implements cbor.Marshaler.
+func (x *Point) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	b = e.AppendArray(b, 2)
+
+	b = e.AppendFloat(b, x.Lat)
+
+	b = e.AppendFloat(b, x.Lon)
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Point) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectArray(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {
+		switch n {
+		case 0:
+			x.Lat, i = d.ExpectFloat(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		case 1:
+			x.Lon, i = d.ExpectFloat(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		default:
+			if d.Flags.Is(cbor.FtDisallowUnknownFields) {
+				return i, fmt.Errorf("at %d: %w", i, cbor.ErrUnknownField)
+			}
+
+			i = d.Skip(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
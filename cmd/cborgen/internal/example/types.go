@@ -0,0 +1,42 @@
+// Package example holds types cborgen output is tested on.
+package example
+
+import "time"
+
+//go:generate go run nikand.dev/go/cbor/cmd/cborgen -type Person,Tags -o types_cbor.go
+//go:generate go run nikand.dev/go/cbor/cmd/cborgen -cddl schema.cddl -o schema_cbor.go
+
+type (
+	Person struct {
+		Name   string           `cbor:"name"`
+		Age    int              `cbor:"age,omitempty"`
+		Email  *string          `cbor:"email,omitempty"`
+		Tags   Tags             `cbor:"tags,omitempty"`
+		Attrs  map[string]int64 `cbor:"attrs,omitempty"`
+		Avatar []byte           `cbor:"3,keyasint,omitempty"`
+		Home   *Address         `cbor:"1,keyasint"`
+		Born   time.Time
+		Kind   Kind
+		Meta
+
+		Secret  string `cbor:"-"`
+		private int
+	}
+
+	Meta struct {
+		ID    uint64  `cbor:"id"`
+		Score float32 `cbor:"score"`
+	}
+
+	Address struct {
+		_ struct{} `cbor:",toarray"`
+
+		Street string
+		Zip    [4]byte
+		Geo    [2]float64
+	}
+
+	Tags []string
+
+	Kind uint8
+)
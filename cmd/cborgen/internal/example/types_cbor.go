@@ -0,0 +1,461 @@
+// Code generated by cborgen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+// AppendCBOR implements cbor.Marshaler.
+func (x *Person) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	st := len(b)
+
+	n := 6
+
+	if x.Age != 0 {
+		n++
+	}
+
+	if x.Email != nil {
+		n++
+	}
+
+	if len(x.Tags) != 0 {
+		n++
+	}
+
+	if len(x.Attrs) != 0 {
+		n++
+	}
+
+	if len(x.Avatar) != 0 {
+		n++
+	}
+
+	b = e.AppendMap(b, n)
+
+	b = e.AppendString(b, "name")
+	b = e.AppendString(b, x.Name)
+
+	if x.Age != 0 {
+		b = e.AppendString(b, "age")
+		b = e.AppendInt64(b, int64(x.Age))
+	}
+
+	if x.Email != nil {
+		b = e.AppendString(b, "email")
+		b = e.AppendString(b, *x.Email)
+	}
+
+	if len(x.Tags) != 0 {
+		b = e.AppendString(b, "tags")
+		b = x.Tags.AppendCBOR(e, b)
+	}
+
+	if len(x.Attrs) != 0 {
+		b = e.AppendString(b, "attrs")
+		st1 := len(b)
+		b = e.AppendMap(b, len(x.Attrs))
+
+		for k1, v1 := range x.Attrs {
+			b = e.AppendString(b, k1)
+			b = e.AppendInt64(b, v1)
+		}
+
+		if e.Flags.Is(cbor.FtDeterministic) {
+			b = e.SortMap(b, st1)
+		}
+	}
+
+	if len(x.Avatar) != 0 {
+		b = e.AppendInt64(b, 3)
+		b = e.AppendBytes(b, x.Avatar)
+	}
+
+	b = e.AppendInt64(b, 1)
+	if x.Home == nil {
+		b = e.AppendNull(b)
+	} else {
+		b = x.Home.AppendCBOR(e, b)
+	}
+
+	b = e.AppendString(b, "Born")
+	b = e.AppendTime(b, x.Born, e.Time)
+
+	b = e.AppendString(b, "Kind")
+	b = e.AppendUint64(b, uint64(x.Kind))
+
+	b = e.AppendString(b, "id")
+	b = e.AppendUint64(b, x.Meta.ID)
+
+	b = e.AppendString(b, "score")
+	b = e.AppendFloat32(b, x.Meta.Score)
+
+	if e.Flags.Is(cbor.FtDeterministic) {
+		b = e.SortMap(b, st)
+	}
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Person) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectMap(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {
+		kst := i
+
+		switch d.TagOnly(b, i) {
+		case cbor.String:
+			var k []byte
+
+			k, i = d.ExpectString(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			switch string(k) {
+			case "name":
+				var v1 []byte
+
+				v1, i = d.ExpectString(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				x.Name = string(v1)
+
+				continue
+			case "age":
+				x.Age, i = d.Int(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				continue
+			case "email":
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Email = nil
+					i++
+				} else {
+					if x.Email == nil {
+						x.Email = new(string)
+					}
+
+					var v2 []byte
+
+					v2, i = d.ExpectString(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					*x.Email = string(v2)
+				}
+
+				continue
+			case "tags":
+				i, err = x.Tags.DecodeCBOR(d, b, i)
+				if err != nil {
+					return i, err
+				}
+
+				continue
+			case "attrs":
+				if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+					x.Attrs = nil
+					i++
+				} else {
+					var l3 int
+
+					l3, i = d.ExpectMap(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					if x.Attrs == nil {
+						x.Attrs = make(map[string]int64)
+					}
+
+					for n3 := 0; l3 < 0 && !d.Break(b, &i) || n3 < l3; n3++ {
+						var k3 string
+
+						var v4 []byte
+
+						v4, i = d.ExpectString(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						k3 = string(v4)
+
+						var v3 int64
+
+						v3, i = d.Int64(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						x.Attrs[k3] = v3
+					}
+
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+				}
+
+				continue
+			case "Born":
+				x.Born, i = d.Time(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				continue
+			case "Kind":
+				var v5 uint8
+
+				v5, i = d.Uint8(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				x.Kind = Kind(v5)
+
+				continue
+			case "id":
+				x.Meta.ID, i = d.Uint64(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				continue
+			case "score":
+				var v6 float64
+
+				v6, i = d.ExpectFloat(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+
+				x.Meta.Score = float32(v6)
+
+				continue
+			}
+		case cbor.Int, cbor.Neg:
+			k, ki := d.Signed(b, i)
+			i = d.Skip(b, i)
+
+			if ki >= 0 {
+				switch k {
+				case 3:
+					if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+						x.Avatar = nil
+						i++
+					} else {
+						var v7 []byte
+
+						v7, i = d.ExpectBytes(b, i)
+						if i < 0 {
+							return st, cbor.Error(i)
+						}
+
+						x.Avatar = append([]byte{}, v7...)
+					}
+
+					continue
+				case 1:
+					if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+						x.Home = nil
+						i++
+					} else {
+						if x.Home == nil {
+							x.Home = new(Address)
+						}
+
+						i, err = x.Home.DecodeCBOR(d, b, i)
+						if err != nil {
+							return i, err
+						}
+					}
+
+					continue
+				}
+			}
+		default:
+			i = d.Skip(b, i)
+		}
+
+		if d.Flags.Is(cbor.FtDisallowUnknownFields) {
+			return kst, fmt.Errorf("at %d: %w", kst, cbor.ErrUnknownField)
+		}
+
+		i = d.Skip(b, i)
+		if i < 0 {
+			return st, cbor.Error(i)
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// AppendCBOR implements cbor.Marshaler.
+func (x Tags) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	if x == nil {
+		b = e.AppendNull(b)
+	} else {
+		b = e.AppendArray(b, len(x))
+
+		for _, v1 := range x {
+			b = e.AppendString(b, v1)
+		}
+	}
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Tags) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	i = st
+
+	if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+		*x = nil
+		i++
+	} else {
+		var l1 int
+
+		l1, i = d.ExpectArray(b, i)
+		if i < 0 {
+			return st, cbor.Error(i)
+		}
+
+		*x = (*x)[:0]
+
+		for n1 := 0; l1 < 0 && !d.Break(b, &i) || n1 < l1; n1++ {
+			var v1 string
+
+			var v2 []byte
+
+			v2, i = d.ExpectString(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			v1 = string(v2)
+
+			*x = append(*x, v1)
+		}
+
+		if i < 0 {
+			return st, cbor.Error(i)
+		}
+
+		if *x == nil {
+			*x = []string{}
+		}
+	}
+
+	return i, nil
+}
+
+// AppendCBOR implements cbor.Marshaler.
+func (x *Address) AppendCBOR(e cbor.Encoder, b []byte) []byte {
+	b = e.AppendArray(b, 3)
+
+	b = e.AppendString(b, x.Street)
+
+	b = e.AppendBytes(b, x.Zip[:])
+
+	b = e.AppendArray(b, 2)
+
+	for _, v1 := range x.Geo {
+		b = e.AppendFloat(b, v1)
+	}
+
+	return b
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (x *Address) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectArray(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < l; n++ {
+		switch n {
+		case 0:
+			var v1 []byte
+
+			v1, i = d.ExpectString(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			x.Street = string(v1)
+		case 1:
+			x.Zip = [4]byte{}
+
+			var v2 []byte
+
+			v2, i = d.ExpectBytes(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			copy(x.Zip[:], v2)
+		case 2:
+			x.Geo = [2]float64{}
+
+			var l3 int
+
+			l3, i = d.ExpectArray(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+
+			for n3 := 0; l3 < 0 && !d.Break(b, &i) || n3 < l3; n3++ {
+				if n3 >= 2 {
+					i = d.Skip(b, i)
+					if i < 0 {
+						return st, cbor.Error(i)
+					}
+
+					continue
+				}
+
+				x.Geo[n3], i = d.ExpectFloat(b, i)
+				if i < 0 {
+					return st, cbor.Error(i)
+				}
+			}
+
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		default:
+			if d.Flags.Is(cbor.FtDisallowUnknownFields) {
+				return i, fmt.Errorf("at %d: %w", i, cbor.ErrUnknownField)
+			}
+
+			i = d.Skip(b, i)
+			if i < 0 {
+				return st, cbor.Error(i)
+			}
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
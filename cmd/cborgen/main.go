@@ -0,0 +1,253 @@
+// Cborgen generates AppendCBOR and DecodeCBOR methods
+// built on the nikand.dev/go/cbor primitives, so hot paths don't pay for reflection.
+//
+// Usage:
+//
+//	cborgen -type Person,Tags [-o cbor_gen.go] [dir]
+//	cborgen -cddl schema.cddl [-type rule,...] [-pkg name] [-o cbor_gen.go] [dir]
+//
+// The first form generates methods for the named struct, slice, map and array types
+// of the Go package in dir, and for the package struct types they refer to.
+// The encoding is the same as Encoder.AppendValue produces:
+// `cbor:"name,omitempty,keyasint"` tags, toarray structs and embedded structs are honored,
+// time.Time and time.Duration are encoded with their default tags.
+// Tags registered in Encoder.Tags and Decoder.Tags are not consulted.
+// Decoding is stricter than Decoder.DecodeValue: values must be of the field type exactly,
+// Null is accepted only for pointers, slices and maps, and labels are not skipped.
+//
+// The second form generates Go types for CDDL rules along with their methods.
+// The first rule and the rules it refers to are generated by default.
+// See cddl.Schema.Shape for the supported subset of CDDL.
+// Nil slices and maps are encoded empty unless the member is nullable, which makes it a pointer,
+// and DecodeCBOR reports missing required map keys.
+//
+// Like any Unmarshaler, generated DecodeCBOR expects well-formed data.
+// Check untrusted input with Decoder.Validate first, as DecodeValue does.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func main() {
+	typesFlag := flag.String("type", "", "comma separated type names, or CDDL rule names")
+	cddlFlag := flag.String("cddl", "", "CDDL specification file to generate types from")
+	pkgFlag := flag.String("pkg", "", "package name for CDDL mode (default is the package in dir)")
+	outFlag := flag.String("o", "cbor_gen.go", "output file relative to dir, - for stdout")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: cborgen -type T1,T2 [flags] [dir]\n       cborgen -cddl file [flags] [dir]\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() > 1 || *typesFlag == "" && *cddlFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+
+	err := run(dir, *typesFlag, *cddlFlag, *pkgFlag, *outFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cborgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, typeList, cddlFile, pkg, out string) (err error) {
+	var names []string
+
+	if typeList != "" {
+		names = strings.Split(typeList, ",")
+	}
+
+	var src, spec []byte
+
+	if cddlFile != "" {
+		spec, err = os.ReadFile(cddlFile)
+		if err != nil {
+			return err
+		}
+
+		if pkg == "" {
+			pkg, err = packageName(dir, out)
+			if err != nil {
+				return err
+			}
+		}
+
+		src, err = generateCDDL(string(spec), pkg, names)
+	} else {
+		src, err = generateGo(dir, names, out)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if out == "-" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, out), src, 0o644)
+}
+
+// generateGo generates methods for the named types of the package in dir.
+// The skip file, previous output, is not loaded.
+func generateGo(dir string, names []string, skip string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	files, err := parseDir(fset, dir, skip, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) { errs = append(errs, err) },
+	}
+
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+
+	for _, err := range errs {
+		if missingMethod(err) == "" {
+			return nil, err
+		}
+	}
+
+	g := newGenerator(pkg)
+
+	for _, name := range names {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %v not found", name)
+		}
+
+		n, ok := obj.Type().(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("%v: %w", name, errUnsupported)
+		}
+
+		err = g.add(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = g.run()
+	if err != nil {
+		return nil, err
+	}
+
+	// methods being regenerated are expected to be missing
+	for _, err := range errs {
+		obj, _ := pkg.Scope().Lookup(missingMethod(err)).(*types.TypeName)
+		if obj == nil {
+			return nil, err
+		}
+
+		if n, _ := obj.Type().(*types.Named); !g.gen[n] {
+			return nil, err
+		}
+	}
+
+	return g.source(nil)
+}
+
+// missingMethod returns the type name if err is caused by missing AppendCBOR or DecodeCBOR method.
+func missingMethod(err error) string {
+	var terr types.Error
+
+	if !errors.As(err, &terr) {
+		return ""
+	}
+
+	m := missingMethodRE.FindStringSubmatch(terr.Msg)
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+var missingMethodRE = regexp.MustCompile(`\*?(\w+) (?:has no field or method|does not implement \S+ \(missing method) (?:AppendCBOR|DecodeCBOR)\)$`)
+
+func packageName(dir, skip string) (string, error) {
+	files, err := parseDir(token.NewFileSet(), dir, skip, parser.PackageClauseOnly)
+	if errors.Is(err, errNoFiles) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Base(abs), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return files[0].Name.Name, nil
+}
+
+var errNoFiles = errors.New("no go files")
+
+func parseDir(fset *token.FileSet, dir, skip string, mode parser.Mode) (files []*ast.File, err error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range ents {
+		name := e.Name()
+
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == skip {
+			continue
+		}
+
+		ok, err := build.Default.MatchFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, mode)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(files) != 0 && f.Name.Name != files[0].Name.Name {
+			return nil, fmt.Errorf("%v: multiple packages: %v, %v", dir, files[0].Name.Name, f.Name.Name)
+		}
+
+		files = append(files, f)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%v: %w", dir, errNoFiles)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,512 @@
+// Package cose implements CBOR Object Signing and Encryption (RFC 9052, RFC 9053)
+// single recipient messages: COSE_Sign1, COSE_Mac0 and COSE_Encrypt0,
+// and COSE_Key and COSE_KeySet structures.
+//
+// Structures to be signed or MACed are built with the deterministic cbor.Encoder.
+// Protected headers are kept as received, so verification doesn't depend on re-encoding.
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// Alg is a COSE algorithm identifier.
+	Alg int64
+
+	// Headers is a header bucket.
+	// Zero and nil fields are not encoded.
+	Headers struct {
+		Alg         Alg
+		Crit        []int64
+		ContentType any // uint64 CoAP content format or string media type
+		KID         []byte
+		IV          []byte
+		PartialIV   []byte
+
+		Other map[any]any // other labels, int64 or string keys
+	}
+)
+
+// Algorithms.
+const (
+	AlgES256            Alg = -7
+	AlgEdDSA            Alg = -8
+	AlgA128GCM          Alg = 1
+	AlgA192GCM          Alg = 2
+	AlgA256GCM          Alg = 3
+	AlgHMAC256_64       Alg = 4
+	AlgHMAC256          Alg = 5
+	AlgChaCha20Poly1305 Alg = 24 // not implemented, see ErrNotImplemented
+)
+
+// Header labels.
+const (
+	HeaderAlg         = 1
+	HeaderCrit        = 2
+	HeaderContentType = 3
+	HeaderKID         = 4
+	HeaderIV          = 5
+	HeaderPartialIV   = 6
+)
+
+// Message tags.
+const (
+	TagEncrypt0 = 16
+	TagMac0     = 17
+	TagSign1    = 18
+)
+
+var (
+	ErrAlg       = errors.New("unsupported algorithm")
+	ErrVerify    = errors.New("verification failed")
+	ErrMalformed = errors.New("malformed message")
+	ErrDuplicate = errors.New("duplicate label")
+	ErrExtraData = errors.New("extra data after the message")
+	ErrKey       = errors.New("unsupported key")
+	ErrCritical  = errors.New("critical header not understood")
+
+	// ErrNotImplemented is returned for known algorithms this package can't do,
+	// like ChaCha20/Poly1305 which is not in the standard library. It wraps ErrAlg.
+	ErrNotImplemented = fmt.Errorf("%w: not implemented", ErrAlg)
+)
+
+var (
+	enc = cbor.Encoder{Flags: cbor.FtDeterministic}
+	dec = cbor.Decoder{Flags: cbor.FtSafe}
+)
+
+func (a Alg) String() string {
+	switch a {
+	case AlgES256:
+		return "ES256"
+	case AlgEdDSA:
+		return "EdDSA"
+	case AlgA128GCM:
+		return "A128GCM"
+	case AlgA192GCM:
+		return "A192GCM"
+	case AlgA256GCM:
+		return "A256GCM"
+	case AlgHMAC256_64:
+		return "HMAC 256/64"
+	case AlgHMAC256:
+		return "HMAC 256/256"
+	case AlgChaCha20Poly1305:
+		return "ChaCha20/Poly1305"
+	}
+
+	return fmt.Sprintf("Alg(%d)", int64(a))
+}
+
+// alg returns the algorithm from protected or unprotected headers.
+func alg(prot, unprot *Headers) Alg {
+	if prot.Alg != 0 {
+		return prot.Alg
+	}
+
+	return unprot.Alg
+}
+
+// protectedAlg returns the algorithm from protected headers.
+// Unprotected one is not covered by the signature or MAC, so it could be swapped, and is rejected.
+func protectedAlg(prot, unprot *Headers) (Alg, error) {
+	if prot.Alg == 0 && unprot.Alg != 0 {
+		return 0, fmt.Errorf("%w: %v in unprotected headers", ErrAlg, unprot.Alg)
+	}
+
+	return prot.Alg, nil
+}
+
+// Append appends headers map.
+func (h *Headers) Append(b []byte) (_ []byte, err error) {
+	st := len(b)
+	b = enc.AppendMap(b, h.len())
+
+	if h.Alg != 0 {
+		b = enc.AppendInt(b, HeaderAlg)
+		b = enc.AppendInt64(b, int64(h.Alg))
+	}
+
+	if h.Crit != nil {
+		b = enc.AppendInt(b, HeaderCrit)
+		b = enc.AppendArray(b, len(h.Crit))
+
+		for _, l := range h.Crit {
+			b = enc.AppendInt64(b, l)
+		}
+	}
+
+	switch ct := h.ContentType.(type) {
+	case nil:
+	case uint64:
+		b = enc.AppendInt(b, HeaderContentType)
+		b = enc.AppendUint64(b, ct)
+	case string:
+		b = enc.AppendInt(b, HeaderContentType)
+		b = enc.AppendString(b, ct)
+	default:
+		return b, fmt.Errorf("content type: unsupported type %T", ct)
+	}
+
+	for _, x := range []struct {
+		l int
+		v []byte
+	}{{HeaderKID, h.KID}, {HeaderIV, h.IV}, {HeaderPartialIV, h.PartialIV}} {
+		if x.v != nil {
+			b = enc.AppendInt(b, x.l)
+			b = enc.AppendBytes(b, x.v)
+		}
+	}
+
+	for k, v := range h.Other {
+		switch k := k.(type) {
+		case int64:
+			if isHeaderLabel(k) {
+				return b, fmt.Errorf("%w: %v in Other", ErrDuplicate, k)
+			}
+		case string:
+		default:
+			return b, fmt.Errorf("header label: unsupported type %T", k)
+		}
+
+		b, err = enc.AppendValue(b, k)
+		if err != nil {
+			return b, err
+		}
+
+		b, err = enc.AppendValue(b, v)
+		if err != nil {
+			return b, fmt.Errorf("header %v: %w", k, err)
+		}
+	}
+
+	return enc.SortMap(b, st), nil
+}
+
+// Decode decodes headers map at st.
+func (h *Headers) Decode(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	return decodeLabels(d, b, st, "header", h.decodeValue)
+}
+
+func (h *Headers) decodeValue(d cbor.Decoder, b []byte, st int, key any) (i int, err error) {
+	var v []byte
+
+	switch key {
+	case int64(HeaderAlg):
+		var a int64
+
+		a, i = d.Int64(b, st)
+		h.Alg = Alg(a)
+	case int64(HeaderCrit):
+		var l int
+
+		l, i = d.ExpectArray(b, st)
+
+		if i >= 0 && l < 1 {
+			return st, fmt.Errorf("at %d: %w: empty crit", st, ErrMalformed)
+		}
+
+		h.Crit = make([]int64, 0, csel(l <= len(b)-i, l, 0))
+
+		for el := 0; i >= 0 && el < l; el++ {
+			var x int64
+
+			x, i = d.Int64(b, i)
+			h.Crit = append(h.Crit, x)
+		}
+	case int64(HeaderContentType):
+		if d.TagOnly(b, st) == cbor.Int {
+			h.ContentType, i = d.Uint64(b, st)
+			break
+		}
+
+		v, i = d.ExpectString(b, st)
+		h.ContentType = string(v)
+	case int64(HeaderKID), int64(HeaderIV), int64(HeaderPartialIV):
+		v, i = d.ExpectBytes(b, st)
+		if i < 0 {
+			break
+		}
+
+		v = append([]byte{}, v...)
+
+		switch key {
+		case int64(HeaderKID):
+			h.KID = v
+		case int64(HeaderIV):
+			h.IV = v
+		default:
+			h.PartialIV = v
+		}
+	default:
+		var x any
+
+		i, err = d.DecodeValue(b, st, &x)
+		if err != nil {
+			return st, err
+		}
+
+		if h.Other == nil {
+			h.Other = map[any]any{}
+		}
+
+		h.Other[key] = x
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// checkCrit checks critical headers are in the protected bucket and all of them are understood.
+// Only the labels defined in RFC 9052 are understood.
+func checkCrit(prot, unprot *Headers) error {
+	if unprot.Crit != nil {
+		return fmt.Errorf("%w: crit in unprotected headers", ErrMalformed)
+	}
+
+	if prot.Crit != nil && len(prot.Crit) == 0 {
+		return fmt.Errorf("%w: empty crit", ErrMalformed)
+	}
+
+	for _, l := range prot.Crit {
+		if !isHeaderLabel(l) {
+			return fmt.Errorf("%w: %v", ErrCritical, l)
+		}
+	}
+
+	return nil
+}
+
+func isHeaderLabel(l int64) bool {
+	return l >= HeaderAlg && l <= HeaderPartialIV
+}
+
+func (h *Headers) len() int {
+	l := len(h.Other)
+
+	for _, ok := range []bool{h.Alg != 0, h.Crit != nil, h.ContentType != nil, h.KID != nil, h.IV != nil, h.PartialIV != nil} {
+		if ok {
+			l++
+		}
+	}
+
+	return l
+}
+
+// decodeLabels decodes a map with int64 or string labels at st.
+// Duplicate labels are rejected. Values are decoded by value.
+func decodeLabels(d cbor.Decoder, b []byte, st int, what string, value func(d cbor.Decoder, b []byte, st int, key any) (int, error)) (i int, err error) {
+	l, i := d.ExpectMap(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	seen := map[any]struct{}{}
+
+	for el := 0; l == -1 && !d.Break(b, &i) || el < l; el++ {
+		kst := i
+
+		var key any
+
+		switch d.TagOnly(b, i) {
+		case cbor.Int, cbor.Neg:
+			key, i = d.Int64(b, i)
+		default:
+			var s []byte
+
+			s, i = d.ExpectString(b, i)
+			key = string(s)
+		}
+
+		if i < 0 {
+			return kst, fmt.Errorf("%s label: %w", what, cbor.Error(i))
+		}
+
+		if _, ok := seen[key]; ok {
+			return kst, fmt.Errorf("at %d: %w: %v", kst, ErrDuplicate, key)
+		}
+
+		seen[key] = struct{}{}
+
+		i, err = value(d, b, i, key)
+		if err != nil {
+			return i, fmt.Errorf("%s %v: %w", what, key, err)
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// encodeProtected encodes protected headers bucket content.
+// Empty bucket is encoded as zero length string.
+func encodeProtected(h *Headers) ([]byte, error) {
+	if h.len() == 0 {
+		return []byte{}, nil
+	}
+
+	return h.Append(nil)
+}
+
+// protectedBytes returns protected headers as signed or received
+// or encodes them if there are none.
+func protectedBytes(raw []byte, h *Headers) ([]byte, error) {
+	if raw != nil {
+		return raw, nil
+	}
+
+	prot, err := encodeProtected(h)
+	if err != nil {
+		return nil, fmt.Errorf("protected: %w", err)
+	}
+
+	return prot, nil
+}
+
+// appendMessage appends a message array with protected and unprotected headers and the fields.
+// nil field is encoded as null.
+func appendMessage(b []byte, tag uint64, prot []byte, unprot *Headers, fields ...[]byte) (_ []byte, err error) {
+	b = enc.AppendTag64(b, cbor.Labeled, tag)
+	b = enc.AppendArray(b, 2+len(fields))
+	b = enc.AppendBytes(b, prot)
+
+	b, err = unprot.Append(b)
+	if err != nil {
+		return b, fmt.Errorf("unprotected: %w", err)
+	}
+
+	for _, f := range fields {
+		if f == nil {
+			b = enc.AppendNull(b)
+			continue
+		}
+
+		b = enc.AppendBytes(b, f)
+	}
+
+	return b, nil
+}
+
+// decodeMessage decodes message at st optionally tagged with tag.
+// Fields could be null, they are set to nil then. Fields content is copied.
+func decodeMessage(d cbor.Decoder, b []byte, st int, tag uint64, prot, unprot *Headers, fields ...*[]byte) (raw []byte, i int, err error) {
+	i = st
+
+	if d.TagOnly(b, i) == cbor.Labeled {
+		var num int64
+
+		_, num, i = d.Tag(b, i)
+		if i < 0 {
+			return nil, st, cbor.Error(i)
+		}
+
+		if uint64(num) != tag {
+			return nil, st, fmt.Errorf("at %d: %w: unexpected tag %d", st, ErrMalformed, uint64(num))
+		}
+	}
+
+	l, i := d.ExpectArray(b, i)
+	if i < 0 {
+		return nil, st, cbor.Error(i)
+	}
+
+	if l != 2+len(fields) {
+		return nil, st, fmt.Errorf("at %d: %w: %d elements", st, ErrMalformed, l)
+	}
+
+	pst := i
+
+	raw, i = d.ExpectBytes(b, i)
+	if i < 0 {
+		return nil, st, cbor.Error(i)
+	}
+
+	raw = append([]byte{}, raw...)
+	*prot = Headers{}
+
+	if len(raw) != 0 {
+		pd := d
+		pd.Flags |= cbor.FtSafe // embedded content is not validated by the caller
+
+		end, err := prot.Decode(pd, raw, 0)
+		if err != nil {
+			return nil, pst, fmt.Errorf("protected: %w", err)
+		}
+
+		if end != len(raw) {
+			return nil, pst, fmt.Errorf("protected: %w", ErrExtraData)
+		}
+	}
+
+	*unprot = Headers{}
+
+	i, err = unprot.Decode(d, b, i)
+	if err != nil {
+		return nil, i, fmt.Errorf("unprotected: %w", err)
+	}
+
+	for _, f := range fields {
+		if d.TagRaw(b, i) == cbor.Simple|cbor.Null {
+			*f = nil
+			i++
+
+			continue
+		}
+
+		var v []byte
+
+		v, i = d.ExpectBytes(b, i)
+		if i < 0 {
+			return nil, st, cbor.Error(i)
+		}
+
+		*f = append([]byte{}, v...)
+	}
+
+	return raw, i, nil
+}
+
+// decodeTop decodes the whole b as a single message.
+func decodeTop(b []byte, decode func(d cbor.Decoder, b []byte, st int) (int, error)) error {
+	i, err := decode(dec, b, 0)
+	if err != nil {
+		return err
+	}
+
+	if i != len(b) {
+		return fmt.Errorf("at %d: %w", i, ErrExtraData)
+	}
+
+	return nil
+}
+
+// toBeSigned builds Sig_structure or MAC_structure.
+func toBeSigned(context string, prot, external, payload []byte) []byte {
+	b := make([]byte, 0, 16+len(context)+len(prot)+len(external)+len(payload))
+
+	b = enc.AppendArray(b, 4)
+	b = enc.AppendString(b, context)
+	b = enc.AppendBytes(b, prot)
+	b = enc.AppendBytes(b, external)
+	b = enc.AppendBytes(b, payload)
+
+	return b
+}
+
+func csel[T any](c bool, x, y T) T {
+	if c {
+		return x
+	}
+
+	return y
+}
@@ -0,0 +1,703 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"nikand.dev/go/cbor"
+)
+
+// Test keys from RFC 9052 Appendix C.7 and cose-wg Examples.
+var (
+	testP256D   = "57c92077664146e876760c9520d054aa93c3afb04e306705db6090308507b4d3"
+	testEdSeed  = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	testHMACKey = "849b57219dae48de646d07dbb533566e976686457c1491be3a76dcea6c427188"
+
+	testContent = []byte("This is the content.")
+)
+
+func testHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		tb.Fatalf("hex: %v", err)
+	}
+
+	return b
+}
+
+func testP256(tb testing.TB) *ecdsa.PrivateKey {
+	d := new(big.Int).SetBytes(testHex(tb, testP256D))
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d.Bytes())
+
+	return key
+}
+
+func TestSign1ES256(tb *testing.T) {
+	// RFC 9052 Appendix C.2.1
+	msg := testHex(tb, `d2 84 43 a10126 a1 04 42 3131 54 546869732069732074686520636f6e74656e742e
+		58 40 8eb33e4ca31d1c465ab05aac34cc6b23d58fef5c083106c4d25a91aef0b0117e
+		2af9a291aa32e14ab834dc56ed2a223444547e01f11d3b0916e5a4c345cacb36`)
+
+	var m Sign1
+
+	err := m.Decode(msg)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	if m.Protected.Alg != AlgES256 || string(m.Unprotected.KID) != "11" || !bytes.Equal(m.Payload, testContent) {
+		tb.Errorf("decoded: %+v", m)
+	}
+
+	key := testP256(tb)
+
+	v, err := NewES256Verifier(&key.PublicKey)
+	if err != nil {
+		tb.Fatalf("verifier: %v", err)
+	}
+
+	err = m.Verify(v, nil)
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	err = m.Verify(v, []byte("aad"))
+	if !errors.Is(err, ErrVerify) {
+		tb.Errorf("verify with external data: %v", err)
+	}
+
+	b, err := m.Append(nil)
+	if err != nil || !bytes.Equal(msg, b) {
+		tb.Errorf("reencoded: %x %v", b, err)
+	}
+
+	s, err := NewES256Signer(key)
+	if err != nil {
+		tb.Fatalf("signer: %v", err)
+	}
+
+	m = Sign1{Unprotected: Headers{KID: []byte("11")}, Payload: []byte("new content")}
+
+	err = m.Sign(s, []byte("aad"))
+	if err != nil {
+		tb.Fatalf("sign: %v", err)
+	}
+
+	b, err = m.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	var r Sign1
+
+	err = r.Decode(b)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	err = r.Verify(v, []byte("aad"))
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	if _, err = NewES256Verifier(&other.PublicKey); !errors.Is(err, ErrAlg) {
+		tb.Errorf("P-384 key: %v", err)
+	}
+}
+
+func TestSign1EdDSA(tb *testing.T) {
+	// cose-wg Examples eddsa-examples/eddsa-sig-01
+	exp := testHex(tb, `d2 84 45 a201270300 a1 04 42 3131 54 546869732069732074686520636f6e74656e742e
+		58 40 7142fd2ff96d56db85bee905a76ba1d0b7321a95c8c4d3607c5781932b7afb87
+		11497dfa751bf40b58b3bcc32300b1487f3db34085eef013bf08f4a44d6fef0d`)
+
+	key := ed25519.NewKeyFromSeed(testHex(tb, testEdSeed))
+
+	m := Sign1{
+		Protected:   Headers{ContentType: uint64(0)},
+		Unprotected: Headers{KID: []byte("11")},
+		Payload:     testContent,
+	}
+
+	err := m.Sign(NewEd25519Signer(key), nil)
+	if err != nil {
+		tb.Fatalf("sign: %v", err)
+	}
+
+	b, err := m.Append(nil)
+	if err != nil || !bytes.Equal(exp, b) {
+		tb.Errorf("encoded: %x %v\nwanted   %x", b, err, exp)
+	}
+
+	var r Sign1
+
+	err = r.Decode(exp)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	v := NewEd25519Verifier(key.Public().(ed25519.PublicKey))
+
+	err = r.Verify(v, nil)
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	r.Payload = []byte("This is not the content.")
+
+	err = r.Verify(v, nil)
+	if !errors.Is(err, ErrVerify) {
+		tb.Errorf("verify modified: %v", err)
+	}
+
+	p256 := testP256(tb)
+	ev, _ := NewES256Verifier(&p256.PublicKey)
+
+	err = r.Verify(ev, nil)
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("verify with other alg: %v", err)
+	}
+	u := Sign1{Unprotected: Headers{Alg: AlgEdDSA}, Payload: testContent, Signature: r.Signature}
+
+	err = u.Verify(v, nil)
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("verify unprotected alg: %v", err)
+	}
+}
+
+func TestSign1Detached(tb *testing.T) {
+	key := ed25519.NewKeyFromSeed(testHex(tb, testEdSeed))
+
+	m := Sign1{Payload: testContent}
+
+	err := m.Sign(NewEd25519Signer(key), nil)
+	if err != nil {
+		tb.Fatalf("sign: %v", err)
+	}
+
+	m.Payload = nil
+
+	b, err := m.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	if d := cbor.Diag(b); !strings.HasPrefix(d, "18([h'a10127', {}, null, h'") {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r Sign1
+
+	err = r.Decode(b)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	r.Payload = testContent
+
+	err = r.Verify(NewEd25519Verifier(key.Public().(ed25519.PublicKey)), nil)
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+}
+
+func TestMac0(tb *testing.T) {
+	// cose-wg Examples mac0-tests/mac0-01
+	exp := testHex(tb, `d1 84 43 a10105 a0 54 546869732069732074686520636f6e74656e742e
+		58 20 a1a848d3471f9d61ee49018d244c824772f223ad4f935293f1789fc3a08d8c58`)
+
+	key := testHex(tb, testHMACKey)
+
+	m := Mac0{Payload: testContent}
+
+	err := m.Create(key, nil)
+	if err != nil {
+		tb.Fatalf("create: %v", err)
+	}
+
+	b, err := m.Append(nil)
+	if err != nil || !bytes.Equal(exp, b) {
+		tb.Errorf("encoded: %x %v\nwanted   %x", b, err, exp)
+	}
+
+	var r Mac0
+
+	err = r.Decode(exp)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	err = r.Verify(key, nil)
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	err = r.Verify(key[1:], nil)
+	if !errors.Is(err, ErrVerify) {
+		tb.Errorf("verify with wrong key: %v", err)
+	}
+
+	m = Mac0{Protected: Headers{Alg: AlgHMAC256_64}, Payload: testContent}
+
+	err = m.Create(key, []byte("aad"))
+	if err != nil || len(m.Tag) != 8 {
+		tb.Fatalf("create truncated: %x %v", m.Tag, err)
+	}
+
+	err = m.Verify(key, []byte("aad"))
+	if err != nil {
+		tb.Errorf("verify truncated: %v", err)
+	}
+
+	// downgrade through the unprotected bucket
+	r = Mac0{Unprotected: Headers{Alg: AlgHMAC256_64}, Payload: testContent, Tag: m.Tag}
+
+	err = r.Verify(key, []byte("aad"))
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("verify unprotected alg: %v", err)
+	}
+
+	err = r.Create(key, nil)
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("create with unprotected alg: %v", err)
+	}
+
+	m = Mac0{Protected: Headers{Alg: AlgEdDSA}}
+
+	err = m.Create(key, nil)
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("create with signature alg: %v", err)
+	}
+}
+
+func TestHeaders(tb *testing.T) {
+	h := Headers{
+		Alg:         AlgEdDSA,
+		Crit:        []int64{-100},
+		ContentType: "application/cbor",
+		KID:         []byte("kid"),
+		IV:          []byte{1, 2},
+		PartialIV:   []byte{3},
+		Other:       map[any]any{int64(-100): "x", "name": int64(7)},
+	}
+
+	b, err := h.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	if d := cbor.Diag(b); d != `{1: -8, 2: [-100], 3: "application/cbor", 4: h'6b6964', 5: h'0102', 6: h'03', -100: "x", "name": 7}` {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r Headers
+
+	i, err := r.Decode(dec, b, 0)
+	if err != nil || i != len(b) {
+		tb.Fatalf("decode: %v %v", i, err)
+	}
+
+	b2, _ := r.Append(nil)
+	if !bytes.Equal(b, b2) {
+		tb.Errorf("roundtrip: %v", cbor.Diag(b2))
+	}
+
+	_, err = (&Headers{Alg: AlgEdDSA, Other: map[any]any{int64(HeaderAlg): int64(AlgES256)}}).Append(nil)
+	if !errors.Is(err, ErrDuplicate) {
+		tb.Errorf("standard label in other: %v", err)
+	}
+
+	for _, diag := range []string{
+		`{1: -8, 1: -7}`,
+		`{1: "EdDSA"}`,
+		`{2: []}`,
+		`{4: "kid"}`,
+		`{1.5: 1}`,
+		`[]`,
+	} {
+		b, err := cbor.ParseDiag(diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		var r Headers
+
+		if _, err = r.Decode(dec, b, 0); err == nil {
+			tb.Errorf("%v: expected error", diag)
+		}
+	}
+}
+
+func TestCrit(tb *testing.T) {
+	key := ed25519.NewKeyFromSeed(testHex(tb, testEdSeed))
+	v := NewEd25519Verifier(key.Public().(ed25519.PublicKey))
+	hkey := testHex(tb, testHMACKey)
+
+	for _, tc := range []struct {
+		prot, unprot Headers
+		err          error
+	}{
+		{Headers{Crit: []int64{HeaderKID}, KID: []byte("11")}, Headers{}, nil},
+		{Headers{Crit: []int64{-100}, Other: map[any]any{int64(-100): 1}}, Headers{}, ErrCritical},
+		{Headers{Crit: []int64{}}, Headers{}, ErrMalformed},
+		{Headers{}, Headers{Crit: []int64{HeaderKID}}, ErrMalformed},
+	} {
+		s := Sign1{Protected: tc.prot, Unprotected: tc.unprot, Payload: testContent}
+
+		err := s.Sign(NewEd25519Signer(key), nil)
+		if err != nil {
+			tb.Fatalf("sign: %v", err)
+		}
+
+		if err = s.Verify(v, nil); !errors.Is(err, tc.err) {
+			tb.Errorf("sign1 %v: %v, wanted %v", tc.prot.Crit, err, tc.err)
+		}
+
+		m := Mac0{Protected: tc.prot, Unprotected: tc.unprot, Payload: testContent}
+
+		err = m.Create(hkey, nil)
+		if err != nil {
+			tb.Fatalf("create: %v", err)
+		}
+
+		if err = m.Verify(hkey, nil); !errors.Is(err, tc.err) {
+			tb.Errorf("mac0 %v: %v, wanted %v", tc.prot.Crit, err, tc.err)
+		}
+
+		e := Encrypt0{Protected: tc.prot, Unprotected: tc.unprot}
+
+		err = e.Encrypt(hkey[:16], testContent, nil)
+		if err != nil {
+			tb.Fatalf("encrypt: %v", err)
+		}
+
+		if _, err = e.Decrypt(hkey[:16], nil); !errors.Is(err, tc.err) {
+			tb.Errorf("encrypt0 %v: %v, wanted %v", tc.prot.Crit, err, tc.err)
+		}
+	}
+}
+
+func TestMessageErrors(tb *testing.T) {
+	for _, diag := range []string{
+		`17([h'', {}, h'', h''])`,
+		`18([h'', {}, h''])`,
+		`18([h'a0', {}, h'', h'', h''])`,
+		`18([h'a101', {}, h'', h''])`,
+		`18([h'a1012600', {}, h'', h''])`,
+		`18(["", {}, h'', h''])`,
+		`18([h'', {}, 1, h''])`,
+		`18([h'', {}, h'', h'']), 0`,
+	} {
+		b, err := cbor.ParseDiag(diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		var m Sign1
+
+		if err = m.Decode(b); err == nil {
+			tb.Errorf("%v: expected error", diag)
+		}
+	}
+
+	for _, x := range []struct {
+		m   cbor.Unmarshaler
+		hex string
+	}{
+		{new(Sign1), `d2 84 42 a101 a0 f6 40`},
+		{new(Sign1), `d2 84 44 a1028201 a0 f6 40`},
+		{new(Mac0), `d1 84 42 a101 a0 f6 40`},
+		{new(Mac0), `d1 84 44 a1028201 a0 f6 40`},
+		{new(Encrypt0), `d0 83 42 a101 a0 40`},
+		{new(Encrypt0), `d0 83 44 a1028201 a0 40`},
+		{new(Key), `a2 01 01 04 82 01`},
+		{new(KeySet), `81 a1 01`},
+	} {
+		if err := cbor.Unmarshal(testHex(tb, x.hex), x.m); err == nil {
+			tb.Errorf("%T %v: expected error", x.m, x.hex)
+		}
+	}
+
+	b := testHex(tb, `d2 84 43 a10126 a1 04 42 3131 54 546869732069732074686520636f6e74656e742e 58 40`)
+
+	var m Sign1
+
+	if err := m.Decode(b); err == nil {
+		tb.Errorf("truncated: expected error")
+	}
+}
+
+func TestEncrypt0(tb *testing.T) {
+	// key and IV from cose-wg Examples aes-gcm-examples/aes-gcm-enc-01
+	exp := testHex(tb, `d0 83 43 a10101 a1 05 4c 02d1f7e6f26c43d4868d87ce
+		58 24 60973a94bb2898009ee52ecfd9ab1dd25867374b162e2c03568b41f57c3cc16f9166250a`)
+
+	key := testHex(tb, "849b57219dae48de646d07dbb533566e")
+
+	var m Encrypt0
+
+	err := m.EncryptWithIV(key, testHex(tb, "02d1f7e6f26c43d4868d87ce"), testContent, nil)
+	if err != nil {
+		tb.Fatalf("encrypt: %v", err)
+	}
+
+	b, err := m.Append(nil)
+	if err != nil || !bytes.Equal(exp, b) {
+		tb.Errorf("encoded: %x %v\nwanted   %x", b, err, exp)
+	}
+
+	var r Encrypt0
+
+	err = r.Decode(exp)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	p, err := r.Decrypt(key, nil)
+	if err != nil || !bytes.Equal(p, testContent) {
+		tb.Errorf("decrypt: %q %v", p, err)
+	}
+
+	_, err = r.Decrypt(key, []byte("aad"))
+	if !errors.Is(err, ErrVerify) {
+		tb.Errorf("decrypt with external data: %v", err)
+	}
+
+	_, err = r.Decrypt(key[1:], nil)
+	if !errors.Is(err, ErrAlg) {
+		tb.Errorf("decrypt with short key: %v", err)
+	}
+
+	key = make([]byte, 32)
+
+	m = Encrypt0{}
+
+	err = m.Encrypt(key, testContent, []byte("aad"))
+	if err != nil || m.Protected.Alg != AlgA256GCM || len(m.Unprotected.IV) != 12 {
+		tb.Fatalf("encrypt: %v %+v", err, m)
+	}
+
+	b, _ = m.Append(nil)
+
+	err = r.Decode(b)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	p, err = r.Decrypt(key, []byte("aad"))
+	if err != nil || !bytes.Equal(p, testContent) {
+		tb.Errorf("decrypt: %q %v", p, err)
+	}
+
+	iv, ct := m.Unprotected.IV, m.Ciphertext
+
+	err = m.Encrypt(key, testContent, []byte("aad"))
+	if err != nil || bytes.Equal(iv, m.Unprotected.IV) || bytes.Equal(ct, m.Ciphertext) {
+		tb.Errorf("encrypt again reused iv: %v  %x %x", err, iv, m.Unprotected.IV)
+	}
+
+	m = Encrypt0{Protected: Headers{IV: iv}}
+
+	err = m.Encrypt(key, testContent, nil)
+	if err != nil || bytes.Equal(iv, m.Protected.IV) || m.Unprotected.IV != nil {
+		tb.Errorf("encrypt with protected iv: %v  %+v", err, m)
+	}
+
+	m = Encrypt0{Protected: Headers{Alg: AlgChaCha20Poly1305}}
+
+	err = m.Encrypt(key, testContent, nil)
+	if !errors.Is(err, ErrNotImplemented) || !errors.Is(err, ErrAlg) {
+		tb.Errorf("encrypt with chacha20: %v", err)
+	}
+
+	m = Encrypt0{}
+
+	err = m.EncryptWithIV(key, []byte{1, 2, 3}, testContent, nil)
+	if !errors.Is(err, ErrMalformed) {
+		tb.Errorf("encrypt with short iv: %v", err)
+	}
+}
+
+func TestKeyEC2(tb *testing.T) {
+	// RFC 9052 Appendix C.7.2
+	exp := `{1: 2, 2: h'3131', -1: 1, ` +
+		`-2: h'bac5b11cad8f99f9c72b05cf4b9e26d244dc189f745228255a219a86d6a09eff', ` +
+		`-3: h'20138bf82dc1b6d562be0fa54ab7804a3a64b6d72ccfed6b6fb6ed28bbfc117e', ` +
+		`-4: h'57c92077664146e876760c9520d054aa93c3afb04e306705db6090308507b4d3'}`
+
+	k, err := NewKey(testP256(tb))
+	if err != nil {
+		tb.Fatalf("new key: %v", err)
+	}
+
+	k.KID = []byte("11")
+
+	b, err := k.Append(nil)
+	if err != nil || cbor.Diag(b) != exp {
+		tb.Errorf("encoded: %v %v", cbor.Diag(b), err)
+	}
+
+	var r Key
+
+	err = r.Decode(b)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	s, err := r.Signer()
+	if err != nil {
+		tb.Fatalf("signer: %v", err)
+	}
+
+	r.D = nil
+
+	v, err := r.Verifier()
+	if err != nil {
+		tb.Fatalf("verifier: %v", err)
+	}
+
+	m := Sign1{Payload: testContent}
+
+	if err = m.Sign(s, nil); err != nil {
+		tb.Fatalf("sign: %v", err)
+	}
+
+	if err = m.Verify(v, nil); err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	r.Y[0] ^= 1
+
+	if _, err = r.PublicKey(); !errors.Is(err, ErrKey) {
+		tb.Errorf("point not on curve: %v", err)
+	}
+
+	k.X[0] ^= 1
+
+	if _, err = k.PrivateKey(); !errors.Is(err, ErrKey) {
+		tb.Errorf("public key mismatch: %v", err)
+	}
+}
+
+func TestKeyOKP(tb *testing.T) {
+	key := ed25519.NewKeyFromSeed(testHex(tb, testEdSeed))
+
+	k, err := NewKey(key)
+	if err != nil {
+		tb.Fatalf("new key: %v", err)
+	}
+
+	b, err := k.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	if d := cbor.Diag(b); !strings.HasPrefix(d, "{1: 1, -1: 6, -2: h'd75a9801") {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r Key
+
+	err = r.Decode(b)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	priv, err := r.PrivateKey()
+	if err != nil || !key.Equal(priv) {
+		tb.Errorf("private key: %v", err)
+	}
+
+	pub, err := r.PublicKey()
+	if err != nil || !key.Public().(ed25519.PublicKey).Equal(pub) {
+		tb.Errorf("public key: %v", err)
+	}
+
+	if _, err = r.Symmetric(); !errors.Is(err, ErrKey) {
+		tb.Errorf("symmetric: %v", err)
+	}
+
+	if _, err = NewKey("key"); !errors.Is(err, ErrKey) {
+		tb.Errorf("string key: %v", err)
+	}
+}
+
+func TestKeySet(tb *testing.T) {
+	sym, _ := NewKey(testHex(tb, testHMACKey))
+	sym.KID = []byte("our-secret")
+	sym.Alg = AlgHMAC256
+	sym.KeyOps = []int64{9, 10}
+
+	ed, _ := NewKey(ed25519.NewKeyFromSeed(testHex(tb, testEdSeed)).Public())
+	ed.KID = []byte("11")
+	ed.Other = map[any]any{"use": "sig"}
+
+	s := KeySet{*sym, *ed}
+
+	b, err := s.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	var r KeySet
+
+	err = r.Decode(b)
+	if err != nil || len(r) != 2 {
+		tb.Fatalf("decode: %v %v", len(r), err)
+	}
+
+	b2, _ := r.Append(nil)
+	if !bytes.Equal(b, b2) {
+		tb.Errorf("roundtrip: %v", cbor.Diag(b2))
+	}
+
+	k, err := r.Lookup([]byte("our-secret")).Symmetric()
+	if err != nil || !bytes.Equal(k, testHex(tb, testHMACKey)) {
+		tb.Errorf("symmetric: %x %v", k, err)
+	}
+
+	if r.Lookup([]byte("none")) != nil {
+		tb.Errorf("lookup: unexpected key")
+	}
+
+	ed.Other = map[any]any{int64(KeyLabelX): []byte{1}}
+
+	if _, err = ed.Append(nil); !errors.Is(err, ErrDuplicate) {
+		tb.Errorf("key label in other: %v", err)
+	}
+
+	for _, diag := range []string{
+		`{}`,
+		`{1: 4, -1: 1}`,
+		`{1: 2, -1: h'00'}`,
+		`{1: 2, -1: 1, -3: true}`,
+		`{1: 1, 1: 1}`,
+		`[]`,
+	} {
+		b, err := cbor.ParseDiag(diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		var k Key
+
+		if err = k.Decode(b); err == nil {
+			tb.Errorf("%v: expected error", diag)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package cose
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// Encrypt0 is COSE_Encrypt0 message.
+	// Nil Ciphertext is encoded as null, that is detached content.
+	// It must be set back before decryption.
+	Encrypt0 struct {
+		Protected   Headers
+		Unprotected Headers
+		Ciphertext  []byte
+
+		protected []byte // as encrypted or received
+	}
+)
+
+const contextEncrypt0 = "Encrypt0"
+
+// Encrypt encrypts plaintext with the key.
+// Protected algorithm header is set to AES-GCM of the key size if no algorithm is set.
+// Fresh random IV is generated on every call, see EncryptWithIV.
+// external is externally supplied data, could be nil.
+// Protected headers must not be changed after that.
+//
+// Only AES-GCM algorithms are supported as ChaCha20/Poly1305 is not in the standard library,
+// ErrNotImplemented is returned for it.
+func (m *Encrypt0) Encrypt(key, plaintext, external []byte) (err error) {
+	return m.EncryptWithIV(key, nil, plaintext, external)
+}
+
+// EncryptWithIV is Encrypt with the caller supplied iv.
+// The iv replaces the IV header in the bucket it's in, or it's set to the unprotected headers.
+// The same iv must never be used twice with the same key as it breaks AES-GCM.
+// Random iv is generated if it's nil.
+func (m *Encrypt0) EncryptWithIV(key, iv, plaintext, external []byte) (err error) {
+	if alg(&m.Protected, &m.Unprotected) == 0 {
+		m.Protected.Alg = AlgA128GCM + Alg(len(key)-16)/8
+	}
+
+	aead, err := m.aead(key)
+	if err != nil {
+		return err
+	}
+
+	if iv == nil {
+		iv = make([]byte, aead.NonceSize())
+
+		_, err = rand.Read(iv)
+		if err != nil {
+			return fmt.Errorf("generate iv: %w", err)
+		}
+	}
+
+	if len(iv) != aead.NonceSize() {
+		return fmt.Errorf("%w: iv size %d", ErrMalformed, len(iv))
+	}
+
+	if m.Protected.IV != nil {
+		m.Protected.IV = iv
+	} else {
+		m.Unprotected.IV = iv
+	}
+
+	m.protected, err = encodeProtected(&m.Protected)
+	if err != nil {
+		return fmt.Errorf("protected: %w", err)
+	}
+
+	m.Ciphertext = aead.Seal(nil, iv, plaintext, encStructure(m.protected, external))
+
+	return nil
+}
+
+// Decrypt decrypts the Ciphertext with the key.
+// ErrVerify is returned if authentication fails.
+// Messages with critical headers other than the standard ones are rejected with ErrCritical.
+func (m *Encrypt0) Decrypt(key, external []byte) ([]byte, error) {
+	if err := checkCrit(&m.Protected, &m.Unprotected); err != nil {
+		return nil, err
+	}
+
+	aead, err := m.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := m.iv(aead)
+	if err != nil {
+		return nil, err
+	}
+
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := aead.Open(nil, iv, m.Ciphertext, encStructure(prot, external))
+	if err != nil {
+		return nil, ErrVerify
+	}
+
+	return p, nil
+}
+
+// Append appends tagged COSE_Encrypt0.
+func (m *Encrypt0) Append(b []byte) ([]byte, error) {
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return b, err
+	}
+
+	return appendMessage(b, TagEncrypt0, prot, &m.Unprotected, m.Ciphertext)
+}
+
+// Decode decodes COSE_Encrypt0 tagged or not. b must contain exactly one message.
+func (m *Encrypt0) Decode(b []byte) error {
+	return decodeTop(b, m.DecodeCBOR)
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (m *Encrypt0) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	m.protected, i, err = decodeMessage(d, b, st, TagEncrypt0, &m.Protected, &m.Unprotected, &m.Ciphertext)
+
+	return i, err
+}
+
+func (m *Encrypt0) aead(key []byte) (cipher.AEAD, error) {
+	var size int
+
+	switch a := alg(&m.Protected, &m.Unprotected); a {
+	case AlgA128GCM:
+		size = 16
+	case AlgA192GCM:
+		size = 24
+	case AlgA256GCM:
+		size = 32
+	case AlgChaCha20Poly1305:
+		return nil, fmt.Errorf("%w: %v", ErrNotImplemented, AlgChaCha20Poly1305)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrAlg, a)
+	}
+
+	if len(key) != size {
+		return nil, fmt.Errorf("%w: %d bytes key for %v", ErrAlg, len(key), alg(&m.Protected, &m.Unprotected))
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(c)
+}
+
+func (m *Encrypt0) iv(aead cipher.AEAD) ([]byte, error) {
+	iv := m.Protected.IV
+	if iv == nil {
+		iv = m.Unprotected.IV
+	}
+
+	if len(iv) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: iv size %d", ErrMalformed, len(iv))
+	}
+
+	return iv, nil
+}
+
+// encStructure builds Enc_structure used as additional authenticated data.
+func encStructure(prot, external []byte) []byte {
+	b := make([]byte, 0, 16+len(prot)+len(external))
+
+	b = enc.AppendArray(b, 3)
+	b = enc.AppendString(b, contextEncrypt0)
+	b = enc.AppendBytes(b, prot)
+	b = enc.AppendBytes(b, external)
+
+	return b
+}
@@ -0,0 +1,489 @@
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// KeyType is COSE key type.
+	KeyType int64
+
+	// Curve is COSE elliptic curve identifier.
+	Curve int64
+
+	// Key is COSE_Key.
+	// Zero and nil fields are not encoded.
+	Key struct {
+		Kty    KeyType
+		KID    []byte
+		Alg    Alg
+		KeyOps []int64
+		BaseIV []byte
+
+		Crv Curve  // EC2 and OKP
+		X   []byte // EC2 and OKP public key
+		Y   []byte // EC2 public key
+		D   []byte // EC2 and OKP private key
+		K   []byte // Symmetric key
+
+		Other map[any]any // other labels, int64 or string keys
+	}
+
+	// KeySet is COSE_KeySet.
+	KeySet []Key
+)
+
+// Key types.
+const (
+	KeyTypeOKP       KeyType = 1
+	KeyTypeEC2       KeyType = 2
+	KeyTypeSymmetric KeyType = 4
+)
+
+// Curves.
+const (
+	CurveP256    Curve = 1
+	CurveP384    Curve = 2
+	CurveP521    Curve = 3
+	CurveX25519  Curve = 4
+	CurveEd25519 Curve = 6
+)
+
+// Key labels.
+const (
+	KeyLabelKty    = 1
+	KeyLabelKID    = 2
+	KeyLabelAlg    = 3
+	KeyLabelKeyOps = 4
+	KeyLabelBaseIV = 5
+
+	KeyLabelCrv = -1 // EC2 and OKP
+	KeyLabelX   = -2
+	KeyLabelY   = -3
+	KeyLabelD   = -4
+
+	KeyLabelK = -1 // Symmetric
+)
+
+// NewKey converts ed25519.PrivateKey, ed25519.PublicKey,
+// *ecdsa.PrivateKey, *ecdsa.PublicKey or []byte symmetric key to Key.
+func NewKey(key any) (*Key, error) {
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return &Key{Kty: KeyTypeOKP, Crv: CurveEd25519, X: []byte(key.Public().(ed25519.PublicKey)), D: key.Seed()}, nil
+	case ed25519.PublicKey:
+		return &Key{Kty: KeyTypeOKP, Crv: CurveEd25519, X: append([]byte{}, key...)}, nil
+	case *ecdsa.PrivateKey:
+		k, err := NewKey(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		k.D = key.D.FillBytes(make([]byte, len(k.X)))
+
+		return k, nil
+	case *ecdsa.PublicKey:
+		crv := curveID(key.Curve)
+		if crv == 0 {
+			return nil, fmt.Errorf("%w: curve %v", ErrKey, key.Curve.Params().Name)
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+
+		return &Key{
+			Kty: KeyTypeEC2,
+			Crv: crv,
+			X:   key.X.FillBytes(make([]byte, size)),
+			Y:   key.Y.FillBytes(make([]byte, size)),
+		}, nil
+	case []byte:
+		return &Key{Kty: KeyTypeSymmetric, K: append([]byte{}, key...)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrKey, key)
+	}
+}
+
+// PublicKey returns ed25519.PublicKey or *ecdsa.PublicKey.
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == KeyTypeOKP && k.Crv == CurveEd25519:
+		if len(k.X) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad Ed25519 public key", ErrKey)
+		}
+
+		return ed25519.PublicKey(append([]byte{}, k.X...)), nil
+	case k.Kty == KeyTypeEC2:
+		c := ellipticCurve(k.Crv)
+		if c == nil {
+			return nil, fmt.Errorf("%w: curve %d", ErrKey, k.Crv)
+		}
+
+		x := new(big.Int).SetBytes(k.X)
+		y := new(big.Int).SetBytes(k.Y)
+
+		if len(k.X) == 0 || len(k.Y) == 0 || !c.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: point is not on curve", ErrKey)
+		}
+
+		return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("%w: key type %d curve %d", ErrKey, k.Kty, k.Crv)
+	}
+}
+
+// PrivateKey returns ed25519.PrivateKey or *ecdsa.PrivateKey.
+// Public key is derived from the private one and checked against X and Y if they are set.
+func (k *Key) PrivateKey() (crypto.PrivateKey, error) {
+	switch {
+	case k.Kty == KeyTypeOKP && k.Crv == CurveEd25519:
+		if len(k.D) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: bad Ed25519 private key", ErrKey)
+		}
+
+		key := ed25519.NewKeyFromSeed(k.D)
+
+		if k.X != nil && !bytes.Equal(k.X, key.Public().(ed25519.PublicKey)) {
+			return nil, fmt.Errorf("%w: public key mismatch", ErrKey)
+		}
+
+		return key, nil
+	case k.Kty == KeyTypeEC2:
+		c := ellipticCurve(k.Crv)
+		if c == nil {
+			return nil, fmt.Errorf("%w: curve %d", ErrKey, k.Crv)
+		}
+
+		d := new(big.Int).SetBytes(k.D)
+
+		if d.Sign() == 0 || d.Cmp(c.Params().N) >= 0 {
+			return nil, fmt.Errorf("%w: bad private key", ErrKey)
+		}
+
+		key := &ecdsa.PrivateKey{D: d}
+		key.Curve = c
+		key.X, key.Y = c.ScalarBaseMult(d.Bytes())
+
+		if k.X != nil && key.X.Cmp(new(big.Int).SetBytes(k.X)) != 0 ||
+			k.Y != nil && key.Y.Cmp(new(big.Int).SetBytes(k.Y)) != 0 {
+			return nil, fmt.Errorf("%w: public key mismatch", ErrKey)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: key type %d curve %d", ErrKey, k.Kty, k.Crv)
+	}
+}
+
+// Symmetric returns symmetric key bytes.
+func (k *Key) Symmetric() ([]byte, error) {
+	if k.Kty != KeyTypeSymmetric || len(k.K) == 0 {
+		return nil, fmt.Errorf("%w: not a symmetric key", ErrKey)
+	}
+
+	return k.K, nil
+}
+
+// Signer creates Signer from the private key.
+func (k *Key) Signer() (Signer, error) {
+	key, err := k.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return NewEd25519Signer(key), nil
+	default:
+		return NewES256Signer(key.(*ecdsa.PrivateKey))
+	}
+}
+
+// Verifier creates Verifier from the public key.
+func (k *Key) Verifier() (Verifier, error) {
+	key, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return NewEd25519Verifier(key), nil
+	default:
+		return NewES256Verifier(key.(*ecdsa.PublicKey))
+	}
+}
+
+// Append appends COSE_Key map.
+func (k *Key) Append(b []byte) (_ []byte, err error) {
+	if k.Crv != 0 && k.K != nil {
+		return b, fmt.Errorf("%w: both crv and k are set", ErrMalformed)
+	}
+
+	st := len(b)
+	b = enc.AppendMap(b, k.len())
+
+	for _, x := range []struct {
+		l int
+		v int64
+	}{{KeyLabelKty, int64(k.Kty)}, {KeyLabelAlg, int64(k.Alg)}, {KeyLabelCrv, int64(k.Crv)}} {
+		if x.v != 0 {
+			b = enc.AppendInt(b, x.l)
+			b = enc.AppendInt64(b, x.v)
+		}
+	}
+
+	if k.KeyOps != nil {
+		b = enc.AppendInt(b, KeyLabelKeyOps)
+		b = enc.AppendArray(b, len(k.KeyOps))
+
+		for _, op := range k.KeyOps {
+			b = enc.AppendInt64(b, op)
+		}
+	}
+
+	for _, x := range []struct {
+		l int
+		v []byte
+	}{{KeyLabelKID, k.KID}, {KeyLabelBaseIV, k.BaseIV}, {KeyLabelK, k.K}, {KeyLabelX, k.X}, {KeyLabelY, k.Y}, {KeyLabelD, k.D}} {
+		if x.v != nil {
+			b = enc.AppendInt(b, x.l)
+			b = enc.AppendBytes(b, x.v)
+		}
+	}
+
+	for l, v := range k.Other {
+		switch l := l.(type) {
+		case int64:
+			if isKeyLabel(l) {
+				return b, fmt.Errorf("%w: %v in Other", ErrDuplicate, l)
+			}
+		case string:
+		default:
+			return b, fmt.Errorf("key label: unsupported type %T", l)
+		}
+
+		b, err = enc.AppendValue(b, l)
+		if err != nil {
+			return b, err
+		}
+
+		b, err = enc.AppendValue(b, v)
+		if err != nil {
+			return b, fmt.Errorf("key %v: %w", l, err)
+		}
+	}
+
+	return enc.SortMap(b, st), nil
+}
+
+// Decode decodes COSE_Key. b must contain exactly one key.
+func (k *Key) Decode(b []byte) error {
+	return decodeTop(b, k.DecodeCBOR)
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+// Key type is required.
+func (k *Key) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	*k = Key{}
+
+	i, err = decodeLabels(d, b, st, "key", k.decodeValue)
+	if err != nil {
+		return i, err
+	}
+
+	if k.Kty == 0 {
+		return st, fmt.Errorf("at %d: %w: no key type", st, ErrMalformed)
+	}
+
+	// label -1 is crv for EC2 and OKP, but k for Symmetric keys
+	if k.Kty == KeyTypeSymmetric && k.Crv != 0 || k.Kty != KeyTypeSymmetric && k.K != nil {
+		return st, fmt.Errorf("at %d: %w: unexpected label -1 type", st, ErrMalformed)
+	}
+
+	return i, nil
+}
+
+func (k *Key) decodeValue(d cbor.Decoder, b []byte, st int, key any) (i int, err error) {
+	var v int64
+
+	switch key {
+	case int64(KeyLabelKty):
+		v, i = d.Int64(b, st)
+		k.Kty = KeyType(v)
+	case int64(KeyLabelAlg):
+		v, i = d.Int64(b, st)
+		k.Alg = Alg(v)
+	case int64(KeyLabelCrv):
+		if tag := d.TagOnly(b, st); tag == cbor.Int || tag == cbor.Neg {
+			v, i = d.Int64(b, st)
+			k.Crv = Curve(v)
+
+			break
+		}
+
+		k.K, i = decodeBytes(d, b, st)
+	case int64(KeyLabelKeyOps):
+		var l int
+
+		l, i = d.ExpectArray(b, st)
+
+		k.KeyOps = make([]int64, 0, csel(l <= len(b)-i, l, 0))
+
+		for el := 0; i >= 0 && el < l; el++ {
+			v, i = d.Int64(b, i)
+			k.KeyOps = append(k.KeyOps, v)
+		}
+	case int64(KeyLabelKID):
+		k.KID, i = decodeBytes(d, b, st)
+	case int64(KeyLabelBaseIV):
+		k.BaseIV, i = decodeBytes(d, b, st)
+	case int64(KeyLabelX):
+		k.X, i = decodeBytes(d, b, st)
+	case int64(KeyLabelY):
+		if d.TagOnly(b, st) != cbor.Bytes {
+			return st, fmt.Errorf("at %d: %w: compressed points are not supported", st, ErrKey)
+		}
+
+		k.Y, i = decodeBytes(d, b, st)
+	case int64(KeyLabelD):
+		k.D, i = decodeBytes(d, b, st)
+	default:
+		var x any
+
+		i, err = d.DecodeValue(b, st, &x)
+		if err != nil {
+			return st, err
+		}
+
+		if k.Other == nil {
+			k.Other = map[any]any{}
+		}
+
+		k.Other[key] = x
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// isKeyLabel reports whether the label is decoded into a Key field.
+func isKeyLabel(l int64) bool {
+	return l >= KeyLabelD && l <= KeyLabelBaseIV && l != 0
+}
+
+func (k *Key) len() int {
+	l := len(k.Other)
+
+	for _, ok := range []bool{k.Kty != 0, k.KID != nil, k.Alg != 0, k.KeyOps != nil, k.BaseIV != nil,
+		k.Crv != 0, k.X != nil, k.Y != nil, k.D != nil, k.K != nil} {
+		if ok {
+			l++
+		}
+	}
+
+	return l
+}
+
+// Lookup returns the first key with the kid or nil.
+func (s KeySet) Lookup(kid []byte) *Key {
+	for i := range s {
+		if bytes.Equal(s[i].KID, kid) {
+			return &s[i]
+		}
+	}
+
+	return nil
+}
+
+// Append appends COSE_KeySet array.
+func (s KeySet) Append(b []byte) (_ []byte, err error) {
+	b = enc.AppendArray(b, len(s))
+
+	for i := range s {
+		b, err = s[i].Append(b)
+		if err != nil {
+			return b, fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+
+	return b, nil
+}
+
+// Decode decodes COSE_KeySet. b must contain exactly one key set.
+func (s *KeySet) Decode(b []byte) error {
+	return decodeTop(b, s.DecodeCBOR)
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (s *KeySet) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	l, i := d.ExpectArray(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	*s = make(KeySet, 0, csel(l >= 0 && l <= len(b)-i, l, 0))
+
+	for el := 0; l == -1 && !d.Break(b, &i) || el < l; el++ {
+		var k Key
+
+		i, err = k.DecodeCBOR(d, b, i)
+		if err != nil {
+			return i, fmt.Errorf("key %d: %w", el, err)
+		}
+
+		*s = append(*s, k)
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+// decodeBytes decodes byte string and copies it.
+func decodeBytes(d cbor.Decoder, b []byte, st int) ([]byte, int) {
+	v, i := d.ExpectBytes(b, st)
+	if i < 0 {
+		return nil, i
+	}
+
+	return append([]byte{}, v...), i
+}
+
+func curveID(c elliptic.Curve) Curve {
+	switch c {
+	case elliptic.P256():
+		return CurveP256
+	case elliptic.P384():
+		return CurveP384
+	case elliptic.P521():
+		return CurveP521
+	}
+
+	return 0
+}
+
+func ellipticCurve(crv Curve) elliptic.Curve {
+	switch crv {
+	case CurveP256:
+		return elliptic.P256()
+	case CurveP384:
+		return elliptic.P384()
+	case CurveP521:
+		return elliptic.P521()
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package cose
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// Mac0 is COSE_Mac0 message.
+	// Nil Payload is encoded as null, that is detached content.
+	// It must be set back before verification.
+	Mac0 struct {
+		Protected   Headers
+		Unprotected Headers
+		Payload     []byte
+		Tag         []byte
+
+		protected []byte // as MACed or received
+	}
+)
+
+const contextMAC0 = "MAC0"
+
+// Create computes the message Tag with the key.
+// Protected algorithm header is set to HMAC 256/256 if no algorithm is set.
+// The algorithm in the unprotected headers is rejected as Verify would do.
+// external is externally supplied data, could be nil.
+// Protected headers must not be changed after that.
+func (m *Mac0) Create(key, external []byte) (err error) {
+	if alg(&m.Protected, &m.Unprotected) == 0 {
+		m.Protected.Alg = AlgHMAC256
+	}
+
+	m.protected, err = encodeProtected(&m.Protected)
+	if err != nil {
+		return fmt.Errorf("protected: %w", err)
+	}
+
+	m.Tag, err = m.mac(key, m.protected, external)
+
+	return err
+}
+
+// Verify checks the message Tag.
+// The algorithm must be in the protected headers, so it can't be downgraded.
+// Messages with critical headers other than the standard ones are rejected with ErrCritical.
+func (m *Mac0) Verify(key, external []byte) error {
+	if err := checkCrit(&m.Protected, &m.Unprotected); err != nil {
+		return err
+	}
+
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return err
+	}
+
+	tag, err := m.mac(key, prot, external)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(tag, m.Tag) {
+		return ErrVerify
+	}
+
+	return nil
+}
+
+// Append appends tagged COSE_Mac0.
+func (m *Mac0) Append(b []byte) ([]byte, error) {
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return b, err
+	}
+
+	return appendMessage(b, TagMac0, prot, &m.Unprotected, m.Payload, m.Tag)
+}
+
+// Decode decodes COSE_Mac0 tagged or not. b must contain exactly one message.
+func (m *Mac0) Decode(b []byte) error {
+	return decodeTop(b, m.DecodeCBOR)
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (m *Mac0) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	m.protected, i, err = decodeMessage(d, b, st, TagMac0, &m.Protected, &m.Unprotected, &m.Payload, &m.Tag)
+
+	return i, err
+}
+
+func (m *Mac0) mac(key, prot, external []byte) ([]byte, error) {
+	a, err := protectedAlg(&m.Protected, &m.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+
+	switch a {
+	case AlgHMAC256:
+		size = 32
+	case AlgHMAC256_64:
+		size = 8
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrAlg, a)
+	}
+
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write(toBeSigned(contextMAC0, prot, external, m.Payload))
+
+	return h.Sum(nil)[:size], nil
+}
@@ -0,0 +1,199 @@
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"nikand.dev/go/cbor"
+)
+
+type (
+	// Signer signs Sig_structure.
+	Signer interface {
+		Alg() Alg
+		Sign(data []byte) ([]byte, error)
+	}
+
+	// Verifier verifies Sig_structure signature.
+	// It returns ErrVerify if the signature is not valid.
+	Verifier interface {
+		Alg() Alg
+		Verify(data, sig []byte) error
+	}
+
+	// Sign1 is COSE_Sign1 message.
+	// Nil Payload is encoded as null, that is detached content.
+	// It must be set back before verification.
+	Sign1 struct {
+		Protected   Headers
+		Unprotected Headers
+		Payload     []byte
+		Signature   []byte
+
+		protected []byte // as signed or received
+	}
+
+	ed25519Signer   ed25519.PrivateKey
+	ed25519Verifier ed25519.PublicKey
+
+	es256Signer struct {
+		key *ecdsa.PrivateKey
+	}
+
+	es256Verifier struct {
+		key *ecdsa.PublicKey
+	}
+)
+
+const contextSignature1 = "Signature1"
+
+// Sign sets protected algorithm header if it's not set, encodes protected headers and signs the message.
+// external is externally supplied data, could be nil.
+// Protected headers must not be changed after signing.
+func (m *Sign1) Sign(s Signer, external []byte) (err error) {
+	if m.Protected.Alg == 0 {
+		m.Protected.Alg = s.Alg()
+	}
+
+	if a := alg(&m.Protected, &m.Unprotected); a != s.Alg() {
+		return fmt.Errorf("%w: %v message with %v key", ErrAlg, a, s.Alg())
+	}
+
+	m.protected, err = encodeProtected(&m.Protected)
+	if err != nil {
+		return fmt.Errorf("protected: %w", err)
+	}
+
+	m.Signature, err = s.Sign(toBeSigned(contextSignature1, m.protected, external, m.Payload))
+
+	return err
+}
+
+// Verify checks the message signature.
+// The algorithm must be in the protected headers and match the Verifier one.
+// Messages with critical headers other than the standard ones are rejected with ErrCritical.
+func (m *Sign1) Verify(v Verifier, external []byte) error {
+	if err := checkCrit(&m.Protected, &m.Unprotected); err != nil {
+		return err
+	}
+
+	a, err := protectedAlg(&m.Protected, &m.Unprotected)
+	if err != nil {
+		return err
+	}
+
+	if a != v.Alg() {
+		return fmt.Errorf("%w: %v message with %v key", ErrAlg, a, v.Alg())
+	}
+
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return err
+	}
+
+	return v.Verify(toBeSigned(contextSignature1, prot, external, m.Payload), m.Signature)
+}
+
+// Append appends tagged COSE_Sign1.
+func (m *Sign1) Append(b []byte) ([]byte, error) {
+	prot, err := protectedBytes(m.protected, &m.Protected)
+	if err != nil {
+		return b, err
+	}
+
+	return appendMessage(b, TagSign1, prot, &m.Unprotected, m.Payload, m.Signature)
+}
+
+// Decode decodes COSE_Sign1 tagged or not. b must contain exactly one message.
+func (m *Sign1) Decode(b []byte) error {
+	return decodeTop(b, m.DecodeCBOR)
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+func (m *Sign1) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	m.protected, i, err = decodeMessage(d, b, st, TagSign1, &m.Protected, &m.Unprotected, &m.Payload, &m.Signature)
+
+	return i, err
+}
+
+// NewEd25519Signer creates EdDSA Signer.
+func NewEd25519Signer(key ed25519.PrivateKey) Signer { return ed25519Signer(key) }
+
+// NewEd25519Verifier creates EdDSA Verifier.
+func NewEd25519Verifier(key ed25519.PublicKey) Verifier { return ed25519Verifier(key) }
+
+// NewES256Signer creates ES256 Signer. The key must be on P-256 curve.
+func NewES256Signer(key *ecdsa.PrivateKey) (Signer, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ES256 requires P-256 key", ErrAlg)
+	}
+
+	return es256Signer{key: key}, nil
+}
+
+// NewES256Verifier creates ES256 Verifier. The key must be on P-256 curve.
+func NewES256Verifier(key *ecdsa.PublicKey) (Verifier, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ES256 requires P-256 key", ErrAlg)
+	}
+
+	return es256Verifier{key: key}, nil
+}
+
+func (s ed25519Signer) Alg() Alg { return AlgEdDSA }
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s), data), nil
+}
+
+func (v ed25519Verifier) Alg() Alg { return AlgEdDSA }
+
+func (v ed25519Verifier) Verify(data, sig []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(v), data, sig) {
+		return ErrVerify
+	}
+
+	return nil
+}
+
+func (s es256Signer) Alg() Alg { return AlgES256 }
+
+// Sign returns r and s concatenated as RFC 9053 Section 2.1 requires.
+func (s es256Signer) Sign(data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, h[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	ss.FillBytes(sig[32:])
+
+	return sig, nil
+}
+
+func (v es256Verifier) Alg() Alg { return AlgES256 }
+
+func (v es256Verifier) Verify(data, sig []byte) error {
+	if len(sig) != 64 {
+		return ErrVerify
+	}
+
+	h := sha256.Sum256(data)
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+
+	if !ecdsa.Verify(v.key, h[:], r, s) {
+		return ErrVerify
+	}
+
+	return nil
+}
@@ -0,0 +1,473 @@
+// Package cwt implements CBOR Web Token (RFC 8392).
+//
+// Claims are encoded as a deterministic map with integer keys
+// and protected by COSE_Sign1 or COSE_Mac0 message.
+package cwt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"nikand.dev/go/cbor"
+	"nikand.dev/go/cbor/cose"
+)
+
+type (
+	// Claims is CWT claims set.
+	// Zero and nil fields are not encoded.
+	// Times are encoded as NumericDate with seconds precision.
+	Claims struct {
+		Iss string
+		Sub string
+		Aud string
+		Exp time.Time
+		Nbf time.Time
+		Iat time.Time
+		Cti []byte
+
+		Extra     map[int]any    // other claims with integer keys
+		ExtraText map[string]any // claims with text string keys
+	}
+
+	// Validator checks CWT claims after the message is verified.
+	Validator struct {
+		Audience string        // required audience, not checked if empty
+		Skew     time.Duration // allowed clock skew for exp and nbf
+		Now      func() time.Time
+	}
+
+	// ClaimError is returned when a claim doesn't pass validation.
+	// Err is one of ErrExpired, ErrNotYetValid, ErrAudience or ErrMissing.
+	ClaimError struct {
+		Claim int
+		Err   error
+	}
+)
+
+// Claim keys.
+const (
+	ClaimIss = 1
+	ClaimSub = 2
+	ClaimAud = 3
+	ClaimExp = 4
+	ClaimNbf = 5
+	ClaimIat = 6
+	ClaimCti = 7
+)
+
+// TagCWT is CWT tag, optional in front of COSE message tag.
+const TagCWT = 61
+
+var (
+	ErrExpired     = errors.New("token expired")
+	ErrNotYetValid = errors.New("token not yet valid")
+	ErrAudience    = errors.New("audience mismatch")
+	ErrMissing     = errors.New("missing claim")
+	ErrMalformed   = errors.New("malformed claims")
+	ErrDuplicate   = errors.New("duplicate claim")
+	ErrExtraData   = errors.New("extra data after the claims")
+)
+
+var (
+	enc = cbor.Encoder{Flags: cbor.FtDeterministic}
+	dec = cbor.Decoder{Flags: cbor.FtSafe}
+)
+
+// Sign encodes claims and signs them into tagged COSE_Sign1 message.
+// kid is set to unprotected headers if not nil.
+func Sign(c *Claims, s cose.Signer, kid []byte) ([]byte, error) {
+	p, err := c.Append(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := cose.Sign1{Unprotected: cose.Headers{KID: kid}, Payload: p}
+
+	err = m.Sign(s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Append(nil)
+}
+
+// MAC encodes claims and MACs them into tagged COSE_Mac0 message.
+// alg is used if not zero, HMAC 256/256 otherwise.
+// kid is set to unprotected headers if not nil.
+func MAC(c *Claims, alg cose.Alg, key, kid []byte) ([]byte, error) {
+	p, err := c.Append(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := cose.Mac0{Protected: cose.Headers{Alg: alg}, Unprotected: cose.Headers{KID: kid}, Payload: p}
+
+	err = m.Create(key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Append(nil)
+}
+
+// VerifySign verifies COSE_Sign1 CWT, decodes and validates its claims.
+// Message may be tagged with TagCWT.
+func (v Validator) VerifySign(b []byte, cv cose.Verifier) (*Claims, error) {
+	b, err := untag(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var m cose.Sign1
+
+	err = m.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Verify(cv, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.claims(m.Payload)
+}
+
+// VerifyMAC verifies COSE_Mac0 CWT, decodes and validates its claims.
+// Message may be tagged with TagCWT.
+func (v Validator) VerifyMAC(b, key []byte) (*Claims, error) {
+	b, err := untag(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var m cose.Mac0
+
+	err = m.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Verify(key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.claims(m.Payload)
+}
+
+// Validate checks expiration, not before and audience claims.
+// Zero Exp and Nbf are not checked. Aud is required if Audience is set.
+func (v Validator) Validate(c *Claims) error {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	t := now()
+
+	if !c.Exp.IsZero() && !t.Before(c.Exp.Add(v.Skew)) {
+		return &ClaimError{Claim: ClaimExp, Err: ErrExpired}
+	}
+
+	if !c.Nbf.IsZero() && t.Before(c.Nbf.Add(-v.Skew)) {
+		return &ClaimError{Claim: ClaimNbf, Err: ErrNotYetValid}
+	}
+
+	if v.Audience != "" && c.Aud == "" {
+		return &ClaimError{Claim: ClaimAud, Err: ErrMissing}
+	}
+
+	if v.Audience != "" && c.Aud != v.Audience {
+		return &ClaimError{Claim: ClaimAud, Err: ErrAudience}
+	}
+
+	return nil
+}
+
+func (v Validator) claims(p []byte) (*Claims, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: detached payload", ErrMalformed)
+	}
+
+	c := new(Claims)
+
+	err := c.Decode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.Validate(c)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// untag strips optional TagCWT.
+func untag(b []byte) ([]byte, error) {
+	if dec.TagOnly(b, 0) != cbor.Labeled {
+		return b, nil
+	}
+
+	_, num, i := dec.Tag(b, 0)
+	if i < 0 {
+		return nil, cbor.Error(i)
+	}
+
+	if num != TagCWT {
+		return b, nil
+	}
+
+	return b[i:], nil
+}
+
+// Append appends claims map.
+func (c *Claims) Append(b []byte) (_ []byte, err error) {
+	st := len(b)
+	b = enc.AppendMap(b, c.len())
+
+	for _, x := range []struct {
+		k int
+		v string
+	}{{ClaimIss, c.Iss}, {ClaimSub, c.Sub}, {ClaimAud, c.Aud}} {
+		if x.v != "" {
+			b = enc.AppendInt(b, x.k)
+			b = enc.AppendString(b, x.v)
+		}
+	}
+
+	for _, x := range []struct {
+		k int
+		v time.Time
+	}{{ClaimExp, c.Exp}, {ClaimNbf, c.Nbf}, {ClaimIat, c.Iat}} {
+		if !x.v.IsZero() {
+			b = enc.AppendInt(b, x.k)
+			b = enc.AppendInt64(b, x.v.Unix())
+		}
+	}
+
+	if c.Cti != nil {
+		b = enc.AppendInt(b, ClaimCti)
+		b = enc.AppendBytes(b, c.Cti)
+	}
+
+	for k, v := range c.Extra {
+		if k >= ClaimIss && k <= ClaimCti {
+			return b, fmt.Errorf("extra claim %d: %w", k, ErrDuplicate)
+		}
+
+		b = enc.AppendInt(b, k)
+
+		b, err = enc.AppendValue(b, v)
+		if err != nil {
+			return b, fmt.Errorf("claim %d: %w", k, err)
+		}
+	}
+
+	for k, v := range c.ExtraText {
+		b = enc.AppendString(b, k)
+
+		b, err = enc.AppendValue(b, v)
+		if err != nil {
+			return b, fmt.Errorf("claim %q: %w", k, err)
+		}
+	}
+
+	return enc.SortMap(b, st), nil
+}
+
+// Decode decodes claims. b must contain exactly one claims map.
+func (c *Claims) Decode(b []byte) error {
+	i, err := c.DecodeCBOR(dec, b, 0)
+	if err != nil {
+		return err
+	}
+
+	if i != len(b) {
+		return fmt.Errorf("at %d: %w", i, ErrExtraData)
+	}
+
+	return nil
+}
+
+// DecodeCBOR implements cbor.Unmarshaler.
+// Claims with text string keys are decoded into ExtraText.
+func (c *Claims) DecodeCBOR(d cbor.Decoder, b []byte, st int) (i int, err error) {
+	*c = Claims{}
+
+	l, i := d.ExpectMap(b, st)
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	seen := map[any]struct{}{}
+
+	for el := 0; l == -1 && !d.Break(b, &i) || el < l; el++ {
+		kst := i
+
+		var key any
+		var k int
+		var s []byte
+
+		if d.TagOnly(b, i) == cbor.String {
+			s, i = d.ExpectString(b, i)
+			key = string(s)
+		} else {
+			k, i = d.Int(b, i)
+			key = k
+		}
+
+		if i < 0 {
+			return kst, fmt.Errorf("claim key: %w", cbor.Error(i))
+		}
+
+		if _, ok := seen[key]; ok {
+			return kst, fmt.Errorf("at %d: %w: %v", kst, ErrDuplicate, key)
+		}
+
+		seen[key] = struct{}{}
+
+		if s, ok := key.(string); ok {
+			i, err = c.decodeText(d, b, i, s)
+		} else {
+			i, err = c.decodeValue(d, b, i, k)
+		}
+
+		if err != nil {
+			return i, fmt.Errorf("claim %v: %w", key, err)
+		}
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+func (c *Claims) decodeValue(d cbor.Decoder, b []byte, st, k int) (i int, err error) {
+	var s []byte
+
+	switch k {
+	case ClaimIss, ClaimSub, ClaimAud:
+		s, i = d.ExpectString(b, st)
+		if i < 0 {
+			break
+		}
+
+		switch k {
+		case ClaimIss:
+			c.Iss = string(s)
+		case ClaimSub:
+			c.Sub = string(s)
+		default:
+			c.Aud = string(s)
+		}
+	case ClaimExp, ClaimNbf, ClaimIat:
+		var t time.Time
+
+		t, i, err = numericDate(d, b, st)
+		if err != nil {
+			return st, err
+		}
+
+		switch k {
+		case ClaimExp:
+			c.Exp = t
+		case ClaimNbf:
+			c.Nbf = t
+		default:
+			c.Iat = t
+		}
+	case ClaimCti:
+		s, i = d.ExpectBytes(b, st)
+		if i < 0 {
+			break
+		}
+
+		c.Cti = append([]byte{}, s...)
+	default:
+		var x any
+
+		i, err = d.DecodeValue(b, st, &x)
+		if err != nil {
+			return st, err
+		}
+
+		if c.Extra == nil {
+			c.Extra = map[int]any{}
+		}
+
+		c.Extra[k] = x
+	}
+
+	if i < 0 {
+		return st, cbor.Error(i)
+	}
+
+	return i, nil
+}
+
+func (c *Claims) decodeText(d cbor.Decoder, b []byte, st int, k string) (i int, err error) {
+	var x any
+
+	i, err = d.DecodeValue(b, st, &x)
+	if err != nil {
+		return st, err
+	}
+
+	if c.ExtraText == nil {
+		c.ExtraText = map[string]any{}
+	}
+
+	c.ExtraText[k] = x
+
+	return i, nil
+}
+
+func (c *Claims) len() int {
+	l := len(c.Extra) + len(c.ExtraText)
+
+	for _, ok := range []bool{c.Iss != "", c.Sub != "", c.Aud != "", !c.Exp.IsZero(), !c.Nbf.IsZero(), !c.Iat.IsZero(), c.Cti != nil} {
+		if ok {
+			l++
+		}
+	}
+
+	return l
+}
+
+// numericDate decodes untagged integer or float seconds since epoch.
+func numericDate(d cbor.Decoder, b []byte, st int) (time.Time, int, error) {
+	if tag := d.TagOnly(b, st); tag == cbor.Int || tag == cbor.Neg {
+		v, i := d.Int64(b, st)
+		if i < 0 {
+			return time.Time{}, st, cbor.Error(i)
+		}
+
+		return time.Unix(v, 0), i, nil
+	}
+
+	f, i := d.ExpectFloat(b, st)
+	if i < 0 {
+		return time.Time{}, st, cbor.Error(i)
+	}
+
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > 1<<62 {
+		return time.Time{}, st, fmt.Errorf("at %d: %w: bad date %v", st, ErrMalformed, f)
+	}
+
+	sec, frac := math.Modf(f)
+
+	return time.Unix(int64(sec), int64(frac*1e9)), i, nil
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("claim %d: %v", e.Claim, e.Err)
+}
+
+func (e *ClaimError) Unwrap() error { return e.Err }
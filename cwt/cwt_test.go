@@ -0,0 +1,229 @@
+package cwt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"nikand.dev/go/cbor"
+	"nikand.dev/go/cbor/cose"
+)
+
+// RFC 8392 Appendix A.
+const (
+	testClaims = `a7 0175 636f61703a2f2f61732e6578616d706c652e636f6d 0265 6572696b77
+		0378 18 636f61703a2f2f6c696768742e6578616d706c652e636f6d
+		041a 5612aeb0 051a 5610d9f0 061a 5610d9f0 0742 0b71`
+
+	testMACKey = "403697de87af64611c1d32a05dab0fe1fcb715a86ab435f1ec99192d79569388"
+	testECD    = "6c1382765aec5358f117733d281c1c7bdc39884d04a45a1e6c67c858bc206c19"
+)
+
+var testNow = time.Unix(1444000000, 0)
+
+func testHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		tb.Fatalf("hex: %v", err)
+	}
+
+	return b
+}
+
+func testExpClaims() *Claims {
+	return &Claims{
+		Iss: "coap://as.example.com",
+		Sub: "erikw",
+		Aud: "coap://light.example.com",
+		Exp: time.Unix(1444064944, 0),
+		Nbf: time.Unix(1443944944, 0),
+		Iat: time.Unix(1443944944, 0),
+		Cti: []byte{0x0b, 0x71},
+	}
+}
+
+func TestClaims(tb *testing.T) {
+	exp := testHex(tb, testClaims)
+
+	b, err := testExpClaims().Append(nil)
+	if err != nil || !bytes.Equal(exp, b) {
+		tb.Errorf("encoded: %x %v\nwanted   %x", b, err, exp)
+	}
+
+	c := Claims{Extra: map[int]any{-70000: "x", 8: int64(1)}, ExtraText: map[string]any{"iss": "text", "n": int64(2)}}
+
+	b, err = c.Append(nil)
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	var r Claims
+
+	err = r.Decode(b)
+	if err != nil || r.Extra[-70000] != "x" || r.Extra[8] != int64(1) || r.ExtraText["iss"] != "text" || r.ExtraText["n"] != int64(2) || r.Iss != "" {
+		tb.Errorf("decoded: %+v %v", r, err)
+	}
+
+	c = Claims{Extra: map[int]any{ClaimExp: 1}}
+
+	if _, err = c.Append(nil); !errors.Is(err, ErrDuplicate) {
+		tb.Errorf("extra standard claim: %v", err)
+	}
+
+	b, _ = cbor.ParseDiag(`{4: 1.5}`)
+
+	err = r.Decode(b)
+	if err != nil || !r.Exp.Equal(time.Unix(1, 5e8)) {
+		tb.Errorf("float date: %v %v", r.Exp, err)
+	}
+
+	for _, diag := range []string{
+		`{1: 1}`,
+		`{4: "now"}`,
+		`{4: 1(1)}`,
+		`{4: NaN}`,
+		`{1: "a", 1: "b"}`,
+		`{"a": 1, "a": 2}`,
+		`{h'01': 1}`,
+		`{7: 1}`,
+		`{}, 0`,
+		`[]`,
+	} {
+		b, err := cbor.ParseDiag(diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		var c Claims
+
+		if err = c.Decode(b); err == nil {
+			tb.Errorf("%v: expected error", diag)
+		}
+	}
+}
+
+func TestVerifySign(tb *testing.T) {
+	// RFC 8392 Appendix A.3
+	msg := testHex(tb, `d2 84 43 a10126 a1 04 52 4173796d6d657472696345434453413235365850`+testClaims+`
+		58 40 5427c1ff28d23fbad1f29c4c7c6a555e601d6fa29f9179bc3d7438bacaca5acd
+		08c8d4d4f96131680c429a01f85951ecee743a52b9b63632c57209120e1c9e30`)
+
+	d := new(big.Int).SetBytes(testHex(tb, testECD))
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d.Bytes())
+
+	v, err := cose.NewES256Verifier(&key.PublicKey)
+	if err != nil {
+		tb.Fatalf("verifier: %v", err)
+	}
+
+	val := Validator{Audience: "coap://light.example.com", Now: func() time.Time { return testNow }}
+
+	c, err := val.VerifySign(msg, v)
+	if err != nil {
+		tb.Fatalf("verify: %v", err)
+	}
+
+	if c.Sub != "erikw" || !c.Exp.Equal(time.Unix(1444064944, 0)) {
+		tb.Errorf("claims: %+v", c)
+	}
+
+	s, _ := cose.NewES256Signer(key)
+
+	b, err := Sign(testExpClaims(), s, []byte("kid"))
+	if err != nil {
+		tb.Fatalf("sign: %v", err)
+	}
+
+	_, err = val.VerifySign(b, v)
+	if err != nil {
+		tb.Errorf("verify: %v", err)
+	}
+
+	b[len(b)-1] ^= 1
+
+	_, err = val.VerifySign(b, v)
+	if !errors.Is(err, cose.ErrVerify) {
+		tb.Errorf("verify modified: %v", err)
+	}
+}
+
+func TestVerifyMAC(tb *testing.T) {
+	// RFC 8392 Appendix A.4
+	exp := testHex(tb, `d83d d1 84 43 a10104 a1 04 4c 53796d6d65747269633235365850`+testClaims+`
+		48 093101ef6d789200`)
+
+	key := testHex(tb, testMACKey)
+
+	b, err := MAC(testExpClaims(), cose.AlgHMAC256_64, key, []byte("Symmetric256"))
+	if err != nil || !bytes.Equal(exp[2:], b) {
+		tb.Errorf("encoded: %x %v\nwanted     %x", b, err, exp[2:])
+	}
+
+	val := Validator{Now: func() time.Time { return testNow }}
+
+	c, err := val.VerifyMAC(exp, key)
+	if err != nil || c.Iss != "coap://as.example.com" {
+		tb.Errorf("verify: %+v %v", c, err)
+	}
+
+	_, err = val.VerifyMAC(exp, key[1:])
+	if !errors.Is(err, cose.ErrVerify) {
+		tb.Errorf("verify with wrong key: %v", err)
+	}
+}
+
+func TestValidate(tb *testing.T) {
+	c := testExpClaims()
+
+	for _, tc := range []struct {
+		Now   time.Time
+		Skew  time.Duration
+		Aud   string
+		Claim int
+		Err   error
+	}{
+		{Now: testNow},
+		{Now: testNow, Aud: c.Aud},
+		{Now: c.Exp, Claim: ClaimExp, Err: ErrExpired},
+		{Now: c.Exp, Skew: time.Minute},
+		{Now: c.Nbf.Add(-time.Second), Claim: ClaimNbf, Err: ErrNotYetValid},
+		{Now: c.Nbf.Add(-time.Second), Skew: time.Minute},
+		{Now: testNow, Aud: "other", Claim: ClaimAud, Err: ErrAudience},
+	} {
+		v := Validator{Audience: tc.Aud, Skew: tc.Skew, Now: func() time.Time { return tc.Now }}
+
+		err := v.Validate(c)
+
+		var ce *ClaimError
+
+		if tc.Err == nil && err != nil || tc.Err != nil && (!errors.As(err, &ce) || ce.Claim != tc.Claim || !errors.Is(err, tc.Err)) {
+			tb.Errorf("now %v skew %v aud %q: %v", tc.Now.Unix(), tc.Skew, tc.Aud, err)
+		}
+	}
+
+	err := Validator{Audience: "a"}.Validate(&Claims{})
+	if !errors.Is(err, ErrMissing) || err.Error() != "claim 3: missing claim" {
+		tb.Errorf("missing aud: %v", err)
+	}
+
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+	b, _ := Sign(&Claims{Exp: testNow.Add(-time.Hour)}, cose.NewEd25519Signer(key), nil)
+
+	c, err = Validator{}.VerifySign(b, cose.NewEd25519Verifier(key.Public().(ed25519.PublicKey)))
+	if !errors.Is(err, ErrExpired) || c == nil {
+		tb.Errorf("expired: %v %v", c, err)
+	}
+}
@@ -4,19 +4,54 @@ import "math"
 
 type (
 	Decoder struct {
-		Flags FeatureFlags
+		Flags  FeatureFlags
+		Limits Limits
+		Tags   *TagRegistry // used by DecodeValue and Dump, DefaultTags if nil
+	}
+
+	// Limits restricts resources spent on untrusted input.
+	// Zero field means no limit.
+	// Exceeded limit is reported as ErrLimit.
+	Limits struct {
+		MaxDepth      int // containers and labels nesting
+		MaxArrayLen   int
+		MaxMapLen     int // number of key-value pairs
+		MaxStringLen  int // bytes and string length, or each chunk length
+		MaxTotalItems int // number of items in a top-level value including itself
 	}
 )
 
 func MakeDecoder() Decoder { return Decoder{Flags: FtDefault} }
 
+// MakeSafeDecoder makes a Decoder which never panics on truncated input.
+// All the methods return negative Error encoded index instead.
+func MakeSafeDecoder() Decoder { return Decoder{Flags: FtDefault | FtSafe} }
+
 func (d Decoder) Skip(b []byte, st int) (i int) {
 	_, _, i = d.SkipTag(b, st)
 	return
 }
 
 func (d Decoder) SkipTag(b []byte, st int) (tag Tag, sub int64, i int) {
+	var items int
+
+	return d.skipTag(b, st, 0, &items)
+}
+
+func (d Decoder) skip(b []byte, st, depth int, items *int) (i int) {
+	_, _, i = d.skipTag(b, st, depth, items)
+	return
+}
+
+func (d Decoder) skipTag(b []byte, st, depth int, items *int) (tag Tag, sub int64, i int) {
 	tag, sub, i = d.Tag(b, st)
+	if i < 0 {
+		return
+	}
+
+	if err := d.Limits.check(tag, sub, st, depth, items); err < 0 {
+		return tag, sub, err
+	}
 
 	//	println(fmt.Sprintf("Skip %x  tag %x %x  i %x  data % x", st, tag, sub, i, b[st:]))
 
@@ -29,18 +64,34 @@ func (d Decoder) SkipTag(b []byte, st int) (tag Tag, sub int64, i int) {
 		}
 
 		for !d.Break(b, &i) {
-			i = d.Skip(b, i)
+			i = d.skip(b, i, depth+1, items)
 		}
 	case Array, Map:
+		if d.Flags.Is(FtSafe) && sub > int64(len(b)-i) {
+			return tag, sub, newError(ErrUnexpectedEOF, st)
+		}
+
 		for el := 0; sub == -1 && !d.Break(b, &i) || el < int(sub); el++ {
+			if sub == -1 {
+				if err := d.Limits.checkLen(tag, el, st); err < 0 {
+					return tag, sub, err
+				}
+			}
+
 			if tag == Map {
-				i = d.Skip(b, i)
+				i = d.skip(b, i, depth+1, items)
+				if i < 0 {
+					return
+				}
 			}
 
-			i = d.Skip(b, i)
+			i = d.skip(b, i, depth+1, items)
+			if i < 0 {
+				return
+			}
 		}
 	case Labeled:
-		i = d.Skip(b, i)
+		i = d.skip(b, i, depth+1, items)
 	case Simple:
 	}
 
@@ -49,11 +100,25 @@ func (d Decoder) SkipTag(b []byte, st int) (tag Tag, sub int64, i int) {
 
 func (d Decoder) Raw(b []byte, st int) ([]byte, int) {
 	i := d.Skip(b, st)
+	if i < 0 {
+		return nil, i
+	}
 
 	return b[st:i], i
 }
 
+// Break checks if there is a Break at *i and advances *i if so.
+// It also reports true if *i is an error, so the loops over indefinite length values stop.
 func (d Decoder) Break(b []byte, i *int) bool {
+	if *i < 0 {
+		return true
+	}
+
+	if d.Flags.Is(FtSafe) && *i >= len(b) {
+		*i = newError(ErrUnexpectedEOF, *i)
+		return true
+	}
+
 	if Tag(b[*i]) != Simple|Break {
 		return false
 	}
@@ -65,19 +130,42 @@ func (d Decoder) Break(b []byte, i *int) bool {
 
 func (d Decoder) Bytes(b []byte, st int) (v []byte, i int) {
 	_, l, i := d.Tag(b, st)
+	if i < 0 {
+		return nil, i
+	}
+
+	if d.Flags.Is(FtSafe) && (l < 0 || l > int64(len(b)-i)) {
+		return nil, newError(ErrUnexpectedEOF, st)
+	}
 
 	return b[i : i+int(l)], i + int(l)
 }
 
+// TagOnly returns major type of the value at st.
+// In FtSafe mode it returns Simple|None if st is out of bounds.
 func (d Decoder) TagOnly(b []byte, st int) (tag Tag) {
+	if d.Flags.Is(FtSafe) && (st < 0 || st >= len(b)) {
+		return Simple | None
+	}
+
 	return Tag(b[st]) & TagMask
 }
 
+// TagRaw returns the first byte of the value at st.
+// In FtSafe mode it returns Simple|None if st is out of bounds.
 func (d Decoder) TagRaw(b []byte, st int) (tag Tag) {
+	if d.Flags.Is(FtSafe) && (st < 0 || st >= len(b)) {
+		return Simple | None
+	}
+
 	return Tag(b[st])
 }
 
 func (d Decoder) Tag(b []byte, st int) (tag Tag, sub int64, i int) {
+	if d.Flags.Is(FtSafe) {
+		return d.tagSafe(b, st)
+	}
+
 	i = st
 
 	tag = Tag(b[i]) & TagMask
@@ -116,6 +204,94 @@ func (d Decoder) Tag(b []byte, st int) (tag Tag, sub int64, i int) {
 	return
 }
 
+func (d Decoder) tagSafe(b []byte, st int) (tag Tag, sub int64, i int) {
+	if st < 0 {
+		return tag, sub, st
+	}
+
+	tag, sub, i = readTag(b, st)
+	if i < 0 {
+		return tag, sub, newError(-i, st)
+	}
+
+	if sub < 0 && tag >= Bytes && tag <= Map && b[st]&SubMask != LenBreak {
+		return tag, sub, newError(ErrOverflow, st)
+	}
+
+	if tag == Simple && sub >= Float8 && sub <= Float64 {
+		i += 1 << (sub - Float8)
+
+		if i > len(b) {
+			return tag, sub, newError(ErrUnexpectedEOF, st)
+		}
+	}
+
+	return tag, sub, i
+}
+
+// floatEnd checks the float at st fits into b and returns its end.
+func (d Decoder) floatEnd(b []byte, st int) int {
+	if st < 0 {
+		return st
+	}
+
+	if st >= len(b) {
+		return newError(ErrUnexpectedEOF, st)
+	}
+
+	i := st + 1
+
+	if sub := b[st] & SubMask; sub >= Float8 && sub <= Float64 {
+		i += 1 << (sub - Float8)
+	}
+
+	if i > len(b) {
+		return newError(ErrUnexpectedEOF, st)
+	}
+
+	return i
+}
+
+// check checks the value head against the limits.
+// It returns 0 or negative Error.
+func (l *Limits) check(tag Tag, sub int64, st, depth int, items *int) int {
+	*items++
+
+	if l.MaxTotalItems != 0 && *items > l.MaxTotalItems ||
+		l.MaxDepth != 0 && depth > l.MaxDepth {
+		return newError(ErrLimit, st)
+	}
+
+	if max := l.maxLen(tag); max != 0 && sub > int64(max) {
+		return newError(ErrLimit, st)
+	}
+
+	return 0
+}
+
+// checkLen checks indefinite length container does not exceed the limit.
+// el is the number of elements (pairs for Map) already read.
+func (l *Limits) checkLen(tag Tag, el, st int) int {
+	if max := l.maxLen(tag); max != 0 && el >= max {
+		return newError(ErrLimit, st)
+	}
+
+	return 0
+}
+
+func (l *Limits) maxLen(tag Tag) int {
+	switch tag {
+	case Bytes, String:
+		return l.MaxStringLen
+	case Array:
+		return l.MaxArrayLen
+	case Map:
+		return l.MaxMapLen
+	}
+
+	return 0
+}
+
 func (d Decoder) u8(b []byte, i int) uint64 {
 	return uint64(b[i])
 }
@@ -133,8 +309,18 @@ func (d Decoder) u64(b []byte, i int) uint64 {
 		uint64(b[i+4])<<24 | uint64(b[i+5])<<16 | uint64(b[i+6])<<8 | uint64(b[i+7])
 }
 
+// Signed decodes Int or Neg value.
+// ErrOverflow is returned if it doesn't fit into int64.
 func (d Decoder) Signed(b []byte, st int) (v int64, i int) {
 	tag, v, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if v < 0 && (tag == Int || tag == Neg) {
+		return 0, newError(ErrOverflow, st)
+	}
+
 	if tag == Neg {
 		v++
 		v = -v
@@ -143,8 +329,18 @@ func (d Decoder) Signed(b []byte, st int) (v int64, i int) {
 	return v, i
 }
 
+// Unsigned decodes Int value or the absolute value of Neg.
+// ErrOverflow is returned for -2^64 which absolute value doesn't fit into uint64.
 func (d Decoder) Unsigned(b []byte, st int) (v uint64, i int) {
 	tag, x, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if tag == Neg && uint64(x) == math.MaxUint64 {
+		return 0, newError(ErrOverflow, st)
+	}
+
 	if tag == Neg {
 		x++
 	}
@@ -153,6 +349,12 @@ func (d Decoder) Unsigned(b []byte, st int) (v uint64, i int) {
 }
 
 func (d Decoder) Float32(b []byte, st int) (v float32, i int) {
+	if d.Flags.Is(FtSafe) {
+		if i = d.floatEnd(b, st); i < 0 {
+			return 0, i
+		}
+	}
+
 	i = st
 
 	sub := b[i] & SubMask
@@ -177,6 +379,12 @@ func (d Decoder) Float32(b []byte, st int) (v float32, i int) {
 }
 
 func (d Decoder) Float(b []byte, st int) (v float64, i int) {
+	if d.Flags.Is(FtSafe) {
+		if i = d.floatEnd(b, st); i < 0 {
+			return 0, i
+		}
+	}
+
 	i = st
 
 	sub := b[i] & SubMask
@@ -216,6 +424,14 @@ func (d Decoder) float16(b []byte, i int) float32 {
 		return math.Float32frombits(r<<16 | exp32)
 	case r&exp == exp:
 		return math.Float32frombits(r<<16 | exp32 | 1)
+	case r&exp == 0: // subnormal
+		v := float32(r&man) / (1 << 24)
+
+		if r&sig != 0 {
+			v = -v
+		}
+
+		return v
 	}
 
 	e := r&exp>>10 - 15 + 127
@@ -1,6 +1,8 @@
 package cbor
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -30,3 +32,133 @@ func TestDecoderSkipNeg(tb *testing.T) {
 		tb.Errorf("%x -> %x %x %x", st, tag, sub, i)
 	}
 }
+
+func TestDecoderSafeTruncated(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	for _, tc := range [][]byte{
+		{0x18, 0x64},
+		{0x19, 0x03, 0xe8},
+		{0x1a, 0x00, 0x0f, 0x42, 0x40},
+		{0x1b, 0x00, 0x00, 0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00},
+		{0x39, 0x03, 0xe7},
+		{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		{0xf8, 0x7f},
+		{0xf9, 0x3c, 0x00},
+		{0xfa, 0x47, 0xc3, 0x50, 0x00},
+		{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+		{0x44, 1, 2, 3, 4},
+		{0x64, 0x49, 0x45, 0x54, 0x46},
+		{0x83, 0x01, 0x82, 0x02, 0x03, 0x82, 0x04, 0x05},
+		{0xa2, 0x61, 'a', 1, 0x61, 'b', 0x82, 2, 3},
+		{0x5f, 0x42, 1, 2, 0x43, 3, 4, 5, 0xff},
+		{0x7f, 0x65, 's', 't', 'r', 'e', 'a', 0x64, 'm', 'i', 'n', 'g', 0xff},
+		{0x9f, 0x01, 0x82, 0x02, 0x03, 0x9f, 0x04, 0x05, 0xff, 0xff},
+		{0x83, 0x01, 0x9f, 0x02, 0x03, 0xff, 0x82, 0x04, 0x05},
+		{0xbf, 0x61, 'a', 1, 0x61, 'b', 0x9f, 0x02, 0x03, 0xff, 0xff},
+		{0xd8, 32, 1},
+		{0xc0, 0x64, 'a', 'b', 'c', 'd'},
+	} {
+		i := d.Skip(tc, 0)
+		if i != len(tc) {
+			tb.Errorf("full % x: i %v", tc, Error(i))
+		}
+
+		for n := 0; n < len(tc); n++ {
+			b := tc[:n]
+
+			i := d.Skip(b, 0)
+			if i >= 0 || Error(i).Code() != ErrUnexpectedEOF {
+				tb.Errorf("truncated % x (%d/%d): expected eof error, got %v", b, n, len(tc), Error(i))
+			}
+
+			// must not panic
+			_, _, _ = d.Tag(b, 0)
+			_, _ = d.Raw(b, 0)
+			_, _ = d.Bytes(b, 0)
+			_, _ = d.Signed(b, 0)
+			_, _ = d.Unsigned(b, 0)
+			_, _ = d.Float(b, 0)
+			_, _ = d.Float32(b, 0)
+			_ = d.TagOnly(b, 0)
+			_ = d.TagRaw(b, 0)
+		}
+	}
+}
+
+func TestDecoderSafeHugeLength(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	for _, b := range [][]byte{
+		{0x5b, 0x10, 0, 0, 0, 0, 0, 0, 0},
+		{0x9b, 0x10, 0, 0, 0, 0, 0, 0, 0, 0x01},
+		{0xbb, 0x10, 0, 0, 0, 0, 0, 0, 0, 0x01},
+	} {
+		i := d.Skip(b, 0)
+		if i >= 0 {
+			tb.Errorf("% x: expected error, got %v", b, i)
+		}
+	}
+}
+
+func TestDecoderLimits(tb *testing.T) {
+	var e Encoder
+	var b []byte
+
+	deep := func(n int) []byte {
+		b := make([]byte, n+1)
+
+		for i := 0; i < n; i++ {
+			b[i] = byte(Array | 1)
+		}
+
+		return b
+	}
+
+	for j, tc := range []struct {
+		Limits Limits
+		Data   []byte
+		Index  int
+	}{
+		{Limits{MaxDepth: 3}, deep(3), 4},
+		{Limits{MaxDepth: 3}, deep(4), -4},
+		{Limits{MaxDepth: 100}, deep(10000), -101},
+		{Limits{MaxArrayLen: 2}, []byte{0x82, 1, 2}, 3},
+		{Limits{MaxArrayLen: 2}, []byte{0x83, 1, 2, 3}, 0},
+		{Limits{MaxArrayLen: 2}, []byte{0x9f, 1, 2, 3, 0xff}, 0},
+		{Limits{MaxArrayLen: 2}, []byte{0x9b, 0x10, 0, 0, 0, 0, 0, 0, 0}, 0},
+		{Limits{MaxMapLen: 1}, []byte{0xa1, 1, 2}, 3},
+		{Limits{MaxMapLen: 1}, []byte{0xbf, 1, 2, 3, 4, 0xff}, 0},
+		{Limits{MaxStringLen: 4}, e.AppendString(b[:0], "abcd"), 5},
+		{Limits{MaxStringLen: 4}, e.AppendString(b[:0], "abcde"), 0},
+		{Limits{MaxStringLen: 4}, []byte{0x7f, 0x61, 'a', 0x65, 'b', 'c', 'd', 'e', 'f', 0xff}, -3},
+		{Limits{MaxTotalItems: 4}, []byte{0x83, 1, 2, 3}, 4},
+		{Limits{MaxTotalItems: 4}, []byte{0x83, 1, 0x81, 2, 3}, -4},
+	} {
+		d := Decoder{Flags: FtSafe, Limits: tc.Limits}
+		exp := tc.Index
+		if exp <= 0 {
+			exp = newError(ErrLimit, -exp)
+		}
+
+		if i := d.Skip(tc.Data, 0); i != exp {
+			tb.Errorf("%d: skip: %v, wanted %v", j, Error(i), Error(exp))
+		}
+
+		if i := d.Validate(tc.Data, 0); i != exp {
+			tb.Errorf("%d: validate: %v, wanted %v", j, Error(i), Error(exp))
+		}
+
+		r := NewReader(bytes.NewReader(tc.Data))
+		r.Limits = tc.Limits
+
+		_, err := r.Decode()
+		if exp < 0 && err != Error(exp) || exp >= 0 && err != nil {
+			tb.Errorf("%d: reader: %v, wanted %v", j, err, Error(exp))
+		}
+
+		if s := d.Dump(tc.Data); exp < 0 && !strings.Contains(s, "limit exceeded") {
+			tb.Errorf("%d: dump: no error\n%s", j, s)
+		}
+	}
+}
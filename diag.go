@@ -0,0 +1,241 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Diag returns RFC 8949 diagnostic notation (EDN) of the data items sequence.
+// Items are separated by ", ".
+// Malformed data is reported as a comment and stops the output.
+func Diag(b []byte) string {
+	return Decoder{}.Diag(b)
+}
+
+// DiagIndicators is like Diag but it adds encoding indicators
+// (_0.._3 for argument size, _1.._3 for float size),
+// so that the exact encoding could be reproduced.
+func DiagIndicators(b []byte) string {
+	return Decoder{}.DiagIndicators(b)
+}
+
+// Diag is the same as package level Diag but it honors Decoder flags and limits.
+func (d Decoder) Diag(b []byte) string {
+	return string(d.appendDiagSeq(nil, b, false))
+}
+
+// DiagIndicators is the same as package level DiagIndicators but it honors Decoder flags and limits.
+func (d Decoder) DiagIndicators(b []byte) string {
+	return string(d.appendDiagSeq(nil, b, true))
+}
+
+func (d Decoder) appendDiagSeq(w, b []byte, ind bool) []byte {
+	for i := 0; i < len(b); {
+		if i != 0 {
+			w = append(w, ", "...)
+		}
+
+		end := d.Validate(b, i)
+		if end < 0 {
+			return append(w, "/ error: "+Error(end).Error()+" /"...)
+		}
+
+		w, i = d.diag(w, b, i, ind)
+	}
+
+	return w
+}
+
+// diag appends the item at st. b must be valid.
+func (d Decoder) diag(w, b []byte, st int, ind bool) (_ []byte, i int) {
+	tag, sub, i := d.Tag(b, st)
+	ai := b[st] & SubMask
+
+	switch tag {
+	case Int, Neg:
+		w = appendDiagInt(w, tag, sub)
+		w = appendIndicator(w, ai, ind)
+	case Bytes, String:
+		if sub >= 0 {
+			w = appendDiagString(w, tag, b[i:i+int(sub)])
+			w = appendIndicator(w, ai, ind)
+			i += int(sub)
+
+			break
+		}
+
+		w = append(w, "(_ "...)
+
+		for n := 0; !d.Break(b, &i); n++ {
+			if n != 0 {
+				w = append(w, ", "...)
+			}
+
+			w, i = d.diag(w, b, i, ind)
+		}
+
+		w = append(w, ')')
+	case Array, Map:
+		w = append(w, csel(tag == Array, "[", "{")...)
+
+		if sub < 0 {
+			w = append(w, "_ "...)
+		} else if ind && ai >= Len1 {
+			w = append(w, '_', '0'+ai-Len1, ' ')
+		}
+
+		for n := 0; sub < 0 && !d.Break(b, &i) || n < int(sub); n++ {
+			if n != 0 {
+				w = append(w, ", "...)
+			}
+
+			if tag == Map {
+				w, i = d.diag(w, b, i, ind)
+				w = append(w, ": "...)
+			}
+
+			w, i = d.diag(w, b, i, ind)
+		}
+
+		w = append(w, csel(tag == Array, "]", "}")...)
+	case Labeled:
+		w = strconv.AppendUint(w, uint64(sub), 10)
+		w = appendIndicator(w, ai, ind)
+		w = append(w, '(')
+		w, i = d.diag(w, b, i, ind)
+		w = append(w, ')')
+	case Simple:
+		switch {
+		case sub == False:
+			w = append(w, "false"...)
+		case sub == True:
+			w = append(w, "true"...)
+		case sub == Null:
+			w = append(w, "null"...)
+		case sub == Undefined:
+			w = append(w, "undefined"...)
+		case sub == Float8 && !d.Flags.Is(FtFloat8Int):
+			w = append(w, "simple("...)
+			w = strconv.AppendUint(w, uint64(b[st+1]), 10)
+			w = append(w, ')')
+		case sub >= Float8 && sub <= Float64:
+			f, _ := d.Float(b, st)
+			w = appendDiagFloat(w, f)
+
+			if ind && sub > Float8 {
+				w = append(w, '_', '0'+byte(sub-Float8))
+			}
+		default:
+			w = append(w, "simple("...)
+			w = strconv.AppendUint(w, uint64(sub), 10)
+			w = append(w, ')')
+		}
+	}
+
+	return w, i
+}
+
+func appendIndicator(w []byte, ai byte, ind bool) []byte {
+	if !ind || ai < Len1 || ai > Len8 {
+		return w
+	}
+
+	return append(w, '_', '0'+ai-Len1)
+}
+
+func appendDiagString(w []byte, tag Tag, s []byte) []byte {
+	if tag == Bytes || !utf8.Valid(s) {
+		w = append(w, "h'"...)
+		w = append(w, hex.EncodeToString(s)...)
+
+		return append(w, '\'')
+	}
+
+	const hexdig = "0123456789abcdef"
+
+	u := func(w []byte, r rune) []byte {
+		return append(w, '\\', 'u', hexdig[r>>12&0xf], hexdig[r>>8&0xf], hexdig[r>>4&0xf], hexdig[r&0xf])
+	}
+
+	w = append(w, '"')
+
+	for _, r := range string(s) {
+		switch {
+		case r == '"' || r == '\\':
+			w = append(w, '\\', byte(r))
+		case r == '\n':
+			w = append(w, '\\', 'n')
+		case r == '\r':
+			w = append(w, '\\', 'r')
+		case r == '\t':
+			w = append(w, '\\', 't')
+		case r < 0x20 || r >= 0x7f && r < 0x10000:
+			w = u(w, r)
+		case r >= 0x10000:
+			r -= 0x10000
+			w = u(w, 0xd800+r>>10)
+			w = u(w, 0xdc00+r&0x3ff)
+		default:
+			w = append(w, byte(r))
+		}
+	}
+
+	return append(w, '"')
+}
+
+func appendDiagFloat(w []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(w, "NaN"...)
+	case math.IsInf(f, 1):
+		return append(w, "Infinity"...)
+	case math.IsInf(f, -1):
+		return append(w, "-Infinity"...)
+	}
+
+	if a := math.Abs(f); a != 0 && (a < 1e-6 || a >= 1e21) {
+		s := strconv.AppendFloat(nil, f, 'e', -1, 64)
+
+		j := bytes.IndexByte(s, 'e')
+
+		w = append(w, s[:j]...)
+
+		if bytes.IndexByte(s[:j], '.') < 0 {
+			w = append(w, ".0"...)
+		}
+
+		w = append(w, 'e', s[j+1])
+
+		exp := s[j+2:]
+		for len(exp) > 1 && exp[0] == '0' {
+			exp = exp[1:]
+		}
+
+		return append(w, exp...)
+	}
+
+	l := len(w)
+	w = strconv.AppendFloat(w, f, 'f', -1, 64)
+
+	if bytes.IndexByte(w[l:], '.') < 0 {
+		w = append(w, ".0"...)
+	}
+
+	return w
+}
+
+func appendDiagInt(w []byte, tag Tag, sub int64) []byte {
+	switch {
+	case tag == Int:
+		return strconv.AppendUint(w, uint64(sub), 10)
+	case uint64(sub) == math.MaxUint64:
+		return append(w, "-18446744073709551616"...)
+	}
+
+	w = append(w, '-')
+
+	return strconv.AppendUint(w, uint64(sub)+1, 10)
+}
@@ -0,0 +1,615 @@
+package cbor
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type diagParser struct {
+	e Encoder
+	s string
+	i int
+}
+
+var errDiagSyntax = errors.New("diag syntax error")
+
+// ParseDiag parses diagnostic notation (EDN) and encodes it.
+// It's the inverse of Diag and DiagIndicators.
+// Floats without encoding indicators are encoded in the preferred (shortest) form.
+func ParseDiag(s string) ([]byte, error) {
+	return Encoder{Flags: FtFloat16}.AppendDiag(nil, s)
+}
+
+// AppendDiag parses diagnostic notation and appends encoded items to b.
+// A sequence of items separated by commas is accepted.
+// Supported are numbers with encoding indicators, NaN and Infinity,
+// h'..', b64'..', 'text' and "text" strings, embedded CBOR <<...>>,
+// arrays, maps and indefinite length strings and containers,
+// tags N(...), simple(N), false, true, null and undefined.
+// Comments /.../ and # till the end of line are skipped.
+func (e Encoder) AppendDiag(b []byte, s string) ([]byte, error) {
+	p := diagParser{e: e, s: s}
+
+	b, err := p.seq(b, "")
+	if err != nil {
+		return b, err
+	}
+
+	if p.i < len(p.s) {
+		return b, p.errorf("unexpected %q", p.s[p.i])
+	}
+
+	return b, nil
+}
+
+func (p *diagParser) seq(b []byte, end string) (_ []byte, err error) {
+	for n := 0; ; n++ {
+		p.ws()
+
+		if p.i == len(p.s) || end != "" && strings.HasPrefix(p.s[p.i:], end) {
+			return b, nil
+		}
+
+		if n != 0 && !p.skip(",") {
+			return b, p.errorf("comma expected")
+		}
+
+		p.ws()
+
+		b, err = p.item(b)
+		if err != nil {
+			return b, err
+		}
+	}
+}
+
+func (p *diagParser) item(b []byte) (_ []byte, err error) {
+	p.ws()
+
+	if p.i == len(p.s) {
+		return b, p.errorf("value expected")
+	}
+
+	switch c := p.s[p.i]; {
+	case c == '[':
+		p.i++
+		return p.container(b, Array, "]")
+	case c == '{':
+		p.i++
+		return p.container(b, Map, "}")
+	case c == '(':
+		p.i++
+		return p.indefString(b)
+	case c == '"':
+		s, err := p.quoted('"')
+		if err != nil {
+			return b, err
+		}
+
+		return p.str(b, String, s)
+	case c == '\'':
+		s, err := p.quoted('\'')
+		if err != nil {
+			return b, err
+		}
+
+		return p.str(b, Bytes, s)
+	case strings.HasPrefix(p.s[p.i:], "h'"):
+		return p.encoded(b, 2, func(s string) ([]byte, error) {
+			return hex.DecodeString(strings.Map(dropSpace, s))
+		})
+	case strings.HasPrefix(p.s[p.i:], "b64'"):
+		return p.encoded(b, 4, decodeBase64)
+	case strings.HasPrefix(p.s[p.i:], "<<"):
+		p.i += 2
+
+		var emb []byte
+
+		emb, err = p.seq(nil, ">>")
+		if err != nil {
+			return b, err
+		}
+
+		if !p.skip(">>") {
+			return b, p.errorf(">> expected")
+		}
+
+		return p.str(b, Bytes, string(emb))
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number(b)
+	}
+
+	w := p.word()
+
+	switch w {
+	case "false":
+		return p.e.AppendBool(b, false), nil
+	case "true":
+		return p.e.AppendBool(b, true), nil
+	case "null":
+		return p.e.AppendNull(b), nil
+	case "undefined":
+		return p.e.AppendUndefined(b), nil
+	case "NaN":
+		return p.float(b, math.NaN())
+	case "Infinity":
+		return p.float(b, math.Inf(1))
+	case "simple":
+		if !p.skip("(") {
+			return b, p.errorf("( expected")
+		}
+
+		p.ws()
+
+		st := p.i
+		x, err := strconv.ParseUint(p.word(), 0, 8)
+		if err != nil || x >= Len1 && x < 32 {
+			p.i = st
+			return b, p.errorf("bad simple value")
+		}
+
+		p.ws()
+
+		if !p.skip(")") {
+			return b, p.errorf(") expected")
+		}
+
+		if x < Len1 {
+			return p.e.AppendSimple(b, int(x)), nil
+		}
+
+		return append(b, byte(Simple|Float8), byte(x)), nil
+	case "":
+		return b, p.errorf("unexpected %q", p.s[p.i])
+	}
+
+	return b, p.errorf("unexpected %q", w)
+}
+
+func (p *diagParser) container(b []byte, tag Tag, end string) (_ []byte, err error) {
+	ind, err := p.indicator()
+	if err != nil {
+		return b, err
+	}
+
+	indef := ind < 0 && p.skip("_")
+	if indef && p.e.Flags.Is(FtDeterministic) {
+		return b, p.errorf("indefinite length in deterministic mode")
+	}
+
+	var body []byte
+	var n uint64
+
+	if indef {
+		body = p.e.AppendTagBreak(b, tag)
+	}
+
+	for ; ; n++ {
+		p.ws()
+
+		if p.skip(end) {
+			break
+		}
+
+		if n != 0 && !p.skip(",") {
+			return b, p.errorf("comma or %v expected", end)
+		}
+
+		body, err = p.item(body)
+		if err != nil {
+			return b, err
+		}
+
+		if tag != Map {
+			continue
+		}
+
+		p.ws()
+
+		if !p.skip(":") {
+			return b, p.errorf("colon expected")
+		}
+
+		body, err = p.item(body)
+		if err != nil {
+			return b, err
+		}
+	}
+
+	if indef {
+		return p.e.AppendBreak(body), nil
+	}
+
+	b, err = p.head(b, tag, n, ind)
+	if err != nil {
+		return b, err
+	}
+
+	return append(b, body...), nil
+}
+
+func (p *diagParser) indefString(b []byte) (_ []byte, err error) {
+	if !p.skip("_") {
+		return b, p.errorf("_ expected")
+	}
+
+	if p.e.Flags.Is(FtDeterministic) {
+		return b, p.errorf("indefinite length in deterministic mode")
+	}
+
+	st := len(b)
+	b = append(b, byte(Bytes|LenBreak))
+
+	for n := 0; ; n++ {
+		p.ws()
+
+		if p.skip(")") {
+			break
+		}
+
+		if n != 0 && !p.skip(",") {
+			return b, p.errorf("comma or ) expected")
+		}
+
+		p.ws()
+
+		pos := p.i
+		ch := len(b)
+
+		b, err = p.item(b)
+		if err != nil {
+			return b, err
+		}
+
+		tag := Tag(b[ch]) & TagMask
+
+		if tag != Bytes && tag != String || b[ch]&SubMask == LenBreak || n != 0 && tag != Tag(b[st])&TagMask {
+			p.i = pos
+			return b, p.errorf("bad indefinite string chunk")
+		}
+
+		b[st] = byte(tag | LenBreak)
+	}
+
+	return p.e.AppendBreak(b), nil
+}
+
+func (p *diagParser) str(b []byte, tag Tag, s string) (_ []byte, err error) {
+	ind, err := p.indicator()
+	if err != nil {
+		return b, err
+	}
+
+	b, err = p.head(b, tag, uint64(len(s)), ind)
+	if err != nil {
+		return b, err
+	}
+
+	return append(b, s...), nil
+}
+
+func (p *diagParser) encoded(b []byte, pref int, dec func(string) ([]byte, error)) ([]byte, error) {
+	st := p.i
+	p.i += pref
+
+	end := strings.IndexByte(p.s[p.i:], '\'')
+	if end < 0 {
+		p.i = st
+		return b, p.errorf("unterminated string")
+	}
+
+	s, err := dec(p.s[p.i : p.i+end])
+	if err != nil {
+		p.i = st
+		return b, p.errorf("bad string: %v", err)
+	}
+
+	p.i += end + 1
+
+	return p.str(b, Bytes, string(s))
+}
+
+func (p *diagParser) quoted(q byte) (string, error) {
+	st := p.i
+	p.i++
+
+	var s []byte
+
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		p.i++
+
+		switch {
+		case c == q:
+			return string(s), nil
+		case c != '\\':
+			s = append(s, c)
+			continue
+		case p.i == len(p.s):
+			continue
+		}
+
+		c = p.s[p.i]
+		p.i++
+
+		switch c {
+		case 'b':
+			s = append(s, '\b')
+		case 'f':
+			s = append(s, '\f')
+		case 'n':
+			s = append(s, '\n')
+		case 'r':
+			s = append(s, '\r')
+		case 't':
+			s = append(s, '\t')
+		case 'u':
+			r, ok := p.hex4()
+			if !ok {
+				return "", p.errorf("bad \\u escape")
+			}
+
+			if utf16.IsSurrogate(r) && strings.HasPrefix(p.s[p.i:], "\\u") {
+				p.i += 2
+
+				r2, ok := p.hex4()
+				if !ok {
+					return "", p.errorf("bad \\u escape")
+				}
+
+				r = utf16.DecodeRune(r, r2)
+			}
+
+			s = utf8.AppendRune(s, r)
+		default:
+			s = append(s, c)
+		}
+	}
+
+	p.i = st
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *diagParser) hex4() (rune, bool) {
+	if p.i+4 > len(p.s) {
+		return 0, false
+	}
+
+	x, err := strconv.ParseUint(p.s[p.i:p.i+4], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	p.i += 4
+
+	return rune(x), true
+}
+
+func (p *diagParser) number(b []byte) (_ []byte, err error) {
+	st := p.i
+	neg := p.skip("-")
+
+	if strings.HasPrefix(p.s[p.i:], "Infinity") {
+		p.i += len("Infinity")
+		return p.float(b, math.Inf(-1))
+	}
+
+	num := p.word()
+	isFloat := !strings.HasPrefix(num, "0x") && strings.ContainsAny(num, ".eE")
+
+	if isFloat && strings.ContainsAny(num[len(num)-1:], "eE") && p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+		p.i++
+		num += p.s[p.i-1:p.i] + p.word()
+	}
+
+	if isFloat {
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			p.i = st
+			return b, p.errorf("bad float: %v", num)
+		}
+
+		return p.float(b, csel(neg, -f, f))
+	}
+
+	v, err := strconv.ParseUint(num, 0, 64)
+	min64 := neg && (num == "18446744073709551616" || num == "0x10000000000000000") // -2^64
+
+	if err != nil && !min64 {
+		p.i = st
+		return b, p.errorf("bad integer: %v", num)
+	}
+
+	ind, err := p.indicator()
+	if err != nil {
+		return b, err
+	}
+
+	p.ws()
+
+	if p.skip("(") {
+		if neg {
+			p.i = st
+			return b, p.errorf("negative tag")
+		}
+
+		b, err = p.head(b, Labeled, v, ind)
+		if err != nil {
+			return b, err
+		}
+
+		b, err = p.item(b)
+		if err != nil {
+			return b, err
+		}
+
+		p.ws()
+
+		if !p.skip(")") {
+			return b, p.errorf(") expected")
+		}
+
+		return b, nil
+	}
+
+	if min64 {
+		return p.head(b, Neg, math.MaxUint64, ind)
+	}
+
+	if neg && v != 0 {
+		return p.head(b, Neg, v-1, ind)
+	}
+
+	return p.head(b, Int, v, ind)
+}
+
+func (p *diagParser) float(b []byte, f float64) ([]byte, error) {
+	ind, err := p.indicator()
+	if err != nil {
+		return b, err
+	}
+
+	nan := math.IsNaN(f)
+
+	switch ind {
+	case -1:
+		return p.e.AppendFloat(b, f), nil
+	case 0:
+		if q := int8(f); p.e.Flags.Is(FtFloat8Int) && float64(q) == f {
+			return append(b, byte(Simple|Float8), byte(q)), nil
+		}
+	case 1:
+		if r, ok := float16bits(math.Float32bits(float32(f))); nan || ok && float64(float32(f)) == f {
+			r = csel(nan, 0x7e00, r)
+			return append(b, byte(Simple|Float16), byte(r>>8), byte(r)), nil
+		}
+	case 2:
+		if q := float32(f); nan || float64(q) == f {
+			r := csel(nan, 0x7fc0_0000, math.Float32bits(q))
+			return append(b, byte(Simple|Float32), byte(r>>24), byte(r>>16), byte(r>>8), byte(r)), nil
+		}
+	case 3:
+		r := csel(nan, 0x7ff8_0000_0000_0000, math.Float64bits(f))
+		return append(b, byte(Simple|Float64), byte(r>>56), byte(r>>48), byte(r>>40), byte(r>>32), byte(r>>24), byte(r>>16), byte(r>>8), byte(r)), nil
+	}
+
+	return b, p.errorf("float doesn't fit encoding indicator _%d", ind)
+}
+
+// head appends the item head with the argument size forced by the encoding indicator.
+func (p *diagParser) head(b []byte, tag Tag, v uint64, ind int) ([]byte, error) {
+	if ind < 0 {
+		return p.e.AppendTag64(b, tag, v), nil
+	}
+
+	size := 1 << ind
+	if size < 8 && v >= 1<<(8*size) {
+		return b, p.errorf("value doesn't fit encoding indicator _%d", ind)
+	}
+
+	b = append(b, byte(tag)|byte(Len1+ind))
+
+	for j := size - 1; j >= 0; j-- {
+		b = append(b, byte(v>>(8*j)))
+	}
+
+	return b, nil
+}
+
+// indicator parses optional encoding indicator _0.._3.
+// It returns -1 if there is none.
+func (p *diagParser) indicator() (int, error) {
+	if p.i+1 >= len(p.s) || p.s[p.i] != '_' || p.s[p.i+1] < '0' || p.s[p.i+1] > '9' {
+		return -1, nil
+	}
+
+	if p.s[p.i+1] > '3' {
+		return -1, p.errorf("unsupported encoding indicator")
+	}
+
+	p.i += 2
+
+	return int(p.s[p.i-1] - '0'), nil
+}
+
+func (p *diagParser) word() string {
+	st := p.i
+
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '.') {
+			break
+		}
+
+		p.i++
+	}
+
+	return p.s[st:p.i]
+}
+
+// ws skips whitespaces and comments.
+func (p *diagParser) ws() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		case '/':
+			end := strings.IndexByte(p.s[p.i+1:], '/')
+			if end < 0 {
+				return
+			}
+
+			p.i += end + 2
+		case '#':
+			end := strings.IndexByte(p.s[p.i:], '\n')
+			if end < 0 {
+				end = len(p.s) - p.i
+			}
+
+			p.i += end
+		default:
+			return
+		}
+	}
+}
+
+func (p *diagParser) skip(s string) bool {
+	if !strings.HasPrefix(p.s[p.i:], s) {
+		return false
+	}
+
+	p.i += len(s)
+
+	return true
+}
+
+func (p *diagParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %w: %s", p.i, errDiagSyntax, fmt.Sprintf(format, args...))
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.Map(dropSpace, s), "=")
+
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func dropSpace(r rune) rune {
+	if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+		return -1
+	}
+
+	return r
+}
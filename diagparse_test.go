@@ -0,0 +1,99 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestParseDiag(tb *testing.T) {
+	for _, tc := range diagTests {
+		exp, _ := hex.DecodeString(tc.Hex)
+
+		b, err := ParseDiag(DiagIndicators(exp))
+		if err != nil {
+			tb.Errorf("%v: %v", DiagIndicators(exp), err)
+			continue
+		}
+
+		if !bytes.Equal(exp, b) {
+			tb.Errorf("%v: % x, wanted % x", DiagIndicators(exp), b, exp)
+		}
+
+		b, err = ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Errorf("%v: %v", tc.Diag, err)
+			continue
+		}
+
+		if r := Diag(b); r != tc.Diag {
+			tb.Errorf("%v: roundtrip %v", tc.Diag, r)
+		}
+	}
+}
+
+func TestParseDiagSyntax(tb *testing.T) {
+	for _, tc := range []struct {
+		Diag string
+		Hex  string
+	}{
+		{"", ""},
+		{" 1 , 2 ", "0102"},
+		{"-0", "00"},
+		{"0x10", "10"},
+		{"-0x10", "2f"},
+		{"1e3", "f963d0"},
+		{"-1.5e-1", "fbbfc3333333333333"},
+		{"-Infinity_1", "f9fc00"},
+		{"NaN_3", "fb7ff8000000000000"},
+		{"24_3", "1b0000000000000018"},
+		{"'a\\'b'", "43612762"},
+		{`"ü\u00fc𐅑\n"`, "69c3bcc3bcf09085910a"},
+		{"b64'AQID'", "43010203"},
+		{"b64'-_8'", "42fbff"},
+		{"h'01 02\n03'", "43010203"},
+		{"<<1, h''>>", "420140"},
+		{"<<>>_1", "590000"},
+		{"[_1 1]", "99000101"},
+		{"{_ }", "bfff"},
+		{"(_ )", "5fff"},
+		{"(_ 'a', h'62')", "5f41614162ff"},
+		{"simple(0)", "e0"},
+		{"simple(255)", "f8ff"},
+		{"55799({1: [2] / comment /}) # tail", "d9d9f7a1018102"},
+	} {
+		exp, err := hex.DecodeString(tc.Hex)
+		if err != nil {
+			tb.Fatalf("%v: %v", tc.Hex, err)
+		}
+
+		b, err := ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Errorf("%q: %v", tc.Diag, err)
+			continue
+		}
+
+		if !bytes.Equal(exp, b) {
+			tb.Errorf("%q: % x, wanted % x", tc.Diag, b, exp)
+		}
+	}
+}
+
+func TestParseDiagErrors(tb *testing.T) {
+	for _, s := range []string{
+		"[1", "[1 2]", "{1}", "{1: }", `"abc`, "h'0'", "h'zz'",
+		"1.5_1e", "1.1_1", "256_0", "-1(1)", "simple(24)", "simple(256)",
+		`(_ 1)`, `(_ "a", h'')`, "1 2", "tru", "_5", "1_4", "<<1",
+	} {
+		_, err := ParseDiag(s)
+		if !errors.Is(err, errDiagSyntax) {
+			tb.Errorf("%q: expected syntax error, got %v", s, err)
+		}
+	}
+
+	_, err := Encoder{Flags: FtDeterministic}.AppendDiag(nil, "[_ ]")
+	if err == nil {
+		tb.Errorf("expected deterministic mode error")
+	}
+}
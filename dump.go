@@ -6,7 +6,25 @@ import (
 )
 
 func Dump(r []byte) (s string) {
+	return Decoder{}.Dump(r)
+}
+
+// DumpValue encodes v with Encoder.AppendValue and dumps the result.
+// Marshaler implementations are used as usual.
+func DumpValue(v any) string {
+	b, err := MakeEncoder().AppendValue(nil, v)
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+
+	return Dump(b)
+}
+
+// Dump is the same as package level Dump but it honors Decoder flags and limits.
+// Decoding error is printed in place of the failed value.
+func (d Decoder) Dump(r []byte) (s string) {
 	var w []byte
+	var items int
 
 	defer func() {
 		p := recover()
@@ -21,27 +39,39 @@ func Dump(r []byte) (s string) {
 		s = string(w)
 	}()
 
-	w, _ = dump(w, r, 0, 0)
+	w, _ = d.dump(w, r, 0, 0, &items)
 	return string(w)
 }
 
-func dump(w, r []byte, st, depth int) (_ []byte, i1 int) {
+func (d Decoder) dump(w, r []byte, st, depth int, items *int) (_ []byte, i1 int) {
 	const spaces = "                                          "
-	var d Decoder
 
 	tag, sub, i := d.Tag(r, st)
+	if err := d.Limits.check(tag, sub, st, depth, items); i >= 0 && err < 0 {
+		i = err
+	}
+
+	w = fmt.Appendf(w, "%4x%s  ", st, spaces[:csel(2*depth < len(spaces), 2*depth, len(spaces))])
 
-	w = fmt.Appendf(w, "%4x%s  ", st, spaces[:2*depth])
+	if i < 0 {
+		w = fmt.Appendf(w, "error: %v\n", Error(i))
+		return w, i
+	}
 
 	switch tag {
 	case Int, Neg:
-		v, i := d.Unsigned(r, st)
-
-		w = fmt.Appendf(w, "% x  %s%d\n", r[st:i], csel(tag == Neg, "-", ""), v)
+		w = fmt.Appendf(w, "% x  ", r[st:i])
+		w = appendDiagInt(w, tag, sub)
+		w = append(w, '\n')
 	case Bytes, String:
 		if sub >= 0 {
 			var v []byte
 			v, i = d.Bytes(r, st)
+			if i < 0 {
+				w = fmt.Appendf(w, "error: %v\n", Error(i))
+				return w, i
+			}
+
 			w = fmt.Appendf(w, "% x  %q\n", r[st:i], v)
 			break
 		}
@@ -52,11 +82,18 @@ func dump(w, r []byte, st, depth int) (_ []byte, i1 int) {
 
 		for j := 0; l < 0 || j < l; j++ {
 			if l < 0 && d.Break(r, &i) {
-				w, i = dump(w, r, i-1, depth+1)
+				if i < 0 {
+					return w, i
+				}
+
+				w, i = d.dump(w, r, i-1, depth+1, items)
 				break
 			}
 
-			w, i = dump(w, r, i, depth+1)
+			w, i = d.dump(w, r, i, depth+1, items)
+			if i < 0 {
+				return w, i
+			}
 		}
 	case Array, Map:
 		w = fmt.Appendf(w, "% x  %x\n", r[st:i], sub)
@@ -65,19 +102,42 @@ func dump(w, r []byte, st, depth int) (_ []byte, i1 int) {
 
 		for j := 0; l < 0 || j < l; j++ {
 			if l < 0 && d.Break(r, &i) {
-				w, i = dump(w, r, i-1, depth+1)
+				if i < 0 {
+					return w, i
+				}
+
+				w, i = d.dump(w, r, i-1, depth+1, items)
 				break
 			}
 
+			if l < 0 {
+				if err := d.Limits.checkLen(tag, j, st); err < 0 {
+					w = fmt.Appendf(w, "%4x%s  error: %v\n", i, spaces[:csel(2*depth+2 < len(spaces), 2*depth+2, len(spaces))], Error(err))
+					return w, err
+				}
+			}
+
 			if tag == Map {
-				w, i = dump(w, r, i, depth+1)
+				w, i = d.dump(w, r, i, depth+1, items)
+				if i < 0 {
+					return w, i
+				}
 			}
 
-			w, i = dump(w, r, i, depth+1)
+			w, i = d.dump(w, r, i, depth+1, items)
+			if i < 0 {
+				return w, i
+			}
 		}
 	case Labeled:
-		w = fmt.Appendf(w, "% x\n", r[st:i])
-		w, i = dump(w, r, i, depth+1)
+		w = fmt.Appendf(w, "% x  tag %d", r[st:i], uint64(sub))
+
+		if s, ok := d.tags().Lookup(uint64(sub)); ok {
+			w = fmt.Appendf(w, " (%s)", s.Name)
+		}
+
+		w = append(w, '\n')
+		w, i = d.dump(w, r, i, depth+1, items)
 	case Simple:
 		switch {
 		case sub < 0:
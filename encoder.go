@@ -1,21 +1,35 @@
 package cbor
 
-import "math"
+import (
+	"bytes"
+	"math"
+	"sort"
+)
 
 type (
 	Encoder struct {
 		Flags FeatureFlags
+		Tags  *TagRegistry // used by AppendValue, DefaultTags if nil
+		Time  TimeFormat   // used by AppendValue for time.Time
 	}
 
 	FeatureFlags int
+
+	mapEntry struct {
+		k, v, end int
+	}
 )
 
 const (
 	_ FeatureFlags = 1 << iota
 	FtFloat8Int
 	FtFloat16
+	FtSafe                  // Decoder checks bounds and returns Error instead of panicking
+	FtDeterministic         // RFC 8949 Core Deterministic Encoding: preferred floats; indefinite lengths are not checked, see CheckDeterministic
+	FtDisallowUnknownFields // DecodeValue returns an error on unknown struct fields
+	FtLoadTimeZone          // Decoder loads extended time zone names with time.LoadLocation, see Decoder.Time
 
-	FtDefault    = FtFloat8Int
+	FtDefault    = FtFloat8Int // two-byte simple values below 32 hold int8 floats, see Decoder.Validate
 	FtCompatible = FtFloat16
 )
 
@@ -60,6 +74,53 @@ func (e Encoder) AppendMap(b []byte, l int) []byte {
 	return e.AppendTag(b, Map, l)
 }
 
+// SortMap sorts the entries of the map starting at st bytewise-lexicographically by their encoded keys
+// as RFC 8949 Core Deterministic Encoding requires.
+// Entries are reordered in place, bytes after the map are kept untouched.
+// Nested maps are not sorted, call SortMap on each of them once it's finished.
+// The rest of b capacity is used as a scratch space, so b might be reallocated.
+// Malformed or truncated map is returned untouched.
+// Duplicate keys are not detected, they stay next to each other, avoiding them is the caller's responsibility.
+// CheckDeterministic reports them as ErrNotDeterministic.
+func (e Encoder) SortMap(b []byte, st int) []byte {
+	d := Decoder{Flags: e.Flags}
+
+	if st < 0 || st >= len(b) || d.Validate(b, st) < 0 {
+		return b
+	}
+
+	tag, l, i := d.Tag(b, st)
+	if tag != Map || l == 0 || l == 1 {
+		return b
+	}
+
+	first := i
+	ents := make([]mapEntry, 0, csel(l > 0 && l < 1024, int(l), 16))
+
+	for el := 0; l < 0 && b[i] != byte(Simple|Break) || el < int(l); el++ {
+		k := i
+		v := d.Skip(b, k)
+		i = d.Skip(b, v)
+
+		ents = append(ents, mapEntry{k: k, v: v, end: i})
+	}
+
+	sort.SliceStable(ents, func(x, y int) bool {
+		return bytes.Compare(b[ents[x].k:ents[x].v], b[ents[y].k:ents[y].v]) < 0
+	})
+
+	n := len(b)
+	b = append(b, b[first:i]...)
+	scr := b[n:]
+
+	w := first
+	for _, x := range ents {
+		w += copy(b[w:], scr[x.k-first:x.end-first])
+	}
+
+	return b[:n]
+}
+
 func (e Encoder) AppendArray(b []byte, l int) []byte {
 	return e.AppendTag(b, Array, l)
 }
@@ -121,7 +182,7 @@ func (e Encoder) AppendTagUnsigned(b []byte, tag Tag, v uint64) []byte {
 }
 
 func (e Encoder) AppendFloat32(b []byte, v float32) []byte {
-	if e.Flags.Is(FtFloat8Int) {
+	if e.Flags.Is(FtFloat8Int) && !e.Flags.Is(FtDeterministic) {
 		if q := int8(v); float32(q) == v {
 			return append(b, byte(Simple|Float8), byte(q))
 		}
@@ -131,7 +192,7 @@ func (e Encoder) AppendFloat32(b []byte, v float32) []byte {
 }
 
 func (e Encoder) AppendFloat(b []byte, v float64) []byte {
-	if e.Flags.Is(FtFloat8Int) {
+	if e.Flags.Is(FtFloat8Int) && !e.Flags.Is(FtDeterministic) {
 		if q := int8(v); float64(q) == v {
 			return append(b, byte(Simple|Float8), byte(q))
 		}
@@ -149,7 +210,7 @@ func (e Encoder) AppendFloat(b []byte, v float64) []byte {
 func (e Encoder) appendFloat32(b []byte, v float32) []byte {
 	r := math.Float32bits(v)
 
-	if e.Flags.Is(FtFloat16) {
+	if e.Flags.Is(FtFloat16) || e.Flags.Is(FtDeterministic) {
 		if b, ok := e.appendFloat16(b, r); ok {
 			return b
 		}
@@ -159,40 +220,53 @@ func (e Encoder) appendFloat32(b []byte, v float32) []byte {
 }
 
 func (e Encoder) appendFloat16(b []byte, r uint32) ([]byte, bool) {
+	r16, ok := float16bits(r)
+	if !ok {
+		return b, false
+	}
+
+	return append(b, byte(Simple|Float16), byte(r16>>8), byte(r16)), true
+}
+
+// float16bits converts float32 bits into float16 bits if it's exactly representable.
+func float16bits(r uint32) (r16 uint32, ok bool) {
 	const (
 		// 1 + 8 + 23
 		sig  = 0b1_00000000_00000000000000000000000
 		exp  = 0b0_11111111_00000000000000000000000
 		manm = 0b0_00000000_11111111111111111111111
 		manx = 0b0_00000000_11111111110000000000000
-
-		exp32 = 0b0_11111_0000000000
 	)
 
-	var r16 uint32
-
 	switch {
 	case r&^sig == 0: // zero
-		r16 = r >> 16
+		return r >> 16, true
 	case r&exp == exp && r&manm == 0: // inf
-		r16 = r >> 16 & 0b1_11111_0000000000
+		return r >> 16 & 0b1_11111_0000000000, true
 	case r&exp == exp: // nan
 		r16 = r >> 16 & 0b1_11111_0000000000
 		r16 |= r&1 | r>>22&1<<9
-	case r&manm&^manx == 0:
-		e := r&exp>>23 - 127 + 15
-		if e >= 32 {
-			break
-		}
 
-		r16 = r&sig>>16 | e<<10 | r&manx>>13
+		return r16, true
 	}
 
-	if r16 == 0 && r&^sig != 0 {
-		return b, false
+	e := int(r&exp>>23) - 127 + 15
+
+	switch {
+	case e >= 1 && e < 31 && r&manm&^manx == 0: // normal
+		return r&sig>>16 | uint32(e)<<10 | r&manx>>13, true
+	case e >= -9 && e < 1: // subnormal
+		s := 14 - e
+		m := r&manm | 1<<23
+
+		if m&(1<<s-1) != 0 {
+			return 0, false
+		}
+
+		return r&sig>>16 | m>>s, true
 	}
 
-	return append(b, byte(Simple|Float16), byte(r16>>8), byte(r16)), true
+	return 0, false
 }
 
 func (e Encoder) AppendTag(b []byte, tag Tag, v int) []byte {
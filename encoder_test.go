@@ -2,6 +2,7 @@ package cbor
 
 import (
 	"bytes"
+	"math"
 	"testing"
 )
 
@@ -66,3 +67,101 @@ func TestEncoderInsertLen(tb *testing.T) {
 
 	tb.Logf("buf: % x", b)
 }
+
+func TestEncoderSortMap(tb *testing.T) {
+	var e Encoder
+	var d Decoder
+	var b []byte
+
+	b = append(b, 0xaa) // some prefix
+
+	st := len(b)
+	b = e.AppendMap(b, 5)
+	b = e.AppendString(b, "bb")
+	b = e.AppendInt(b, 1)
+	b = e.AppendInt(b, -1)
+	b = e.AppendInt(b, 2)
+	b = e.AppendString(b, "a")
+	b = e.AppendArray(b, 2)
+	b = e.AppendInt(b, 3)
+	b = e.AppendInt(b, 4)
+	b = e.AppendInt(b, 100)
+	b = e.AppendInt(b, 5)
+	b = e.AppendInt(b, 10)
+	b = e.AppendInt(b, 6)
+
+	end := len(b)
+	b = append(b, 0xbb) // some suffix
+
+	b = e.SortMap(b, st)
+
+	exp := []byte{0xaa, 0xa5, 0x0a, 0x06, 0x18, 0x64, 0x05, 0x20, 0x02, 0x61, 'a', 0x82, 0x03, 0x04, 0x62, 'b', 'b', 0x01, 0xbb}
+
+	if !bytes.Equal(exp, b) {
+		tb.Errorf("sorted map\n% x\nwanted\n% x", b, exp)
+	}
+
+	if i := d.CheckDeterministic(b, st); i != end {
+		tb.Errorf("not deterministic: %v", Error(i))
+	}
+}
+
+func TestEncoderSortMapMalformed(tb *testing.T) {
+	var e Encoder
+	var d Decoder
+
+	for _, x := range [][]byte{
+		{},
+		{0xa2, 0x02, 0x01, 0x01},       // truncated
+		{0xa1, 0x02},                   // value missing
+		{0xbf, 0x02, 0x01, 0x01},       // no break
+		{0xbf, 0x02, 0x01, 0x01, 0xff}, // odd indefinite map
+		{0xa2, 0x02, 0x01, 0x1c, 0x00}, // reserved additional info
+	} {
+		b := e.SortMap(append([]byte{}, x...), 0)
+		if !bytes.Equal(x, b) {
+			tb.Errorf("malformed map changed: % x -> % x", x, b)
+		}
+	}
+
+	b := []byte{0xa3, 0x02, 0x01, 0x01, 0x02, 0x02, 0x03}
+	b = e.SortMap(b, 0)
+
+	exp := []byte{0xa3, 0x01, 0x02, 0x02, 0x01, 0x02, 0x03}
+	if !bytes.Equal(exp, b) {
+		tb.Errorf("sorted map\n% x\nwanted\n% x", b, exp)
+	}
+
+	if i := d.CheckDeterministic(b, 0); Error(i).Code() != ErrNotDeterministic {
+		tb.Errorf("duplicate keys: %v", i)
+	}
+}
+
+func TestEncoderDeterministic(tb *testing.T) {
+	e := Encoder{Flags: FtDefault | FtDeterministic}
+	var b []byte
+
+	for _, tc := range []struct {
+		Data    float64
+		Encoded []byte
+	}{
+		{0, []byte{0xf9, 0x00, 0x00}},
+		{1, []byte{0xf9, 0x3c, 0x00}},
+		{100000, []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}},
+		{1.1, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{math.NaN(), []byte{0xf9, 0x7e, 0x00}},
+	} {
+		b = e.AppendFloat(b[:0], tc.Data)
+
+		if !bytes.Equal(tc.Encoded, b) {
+			tb.Errorf("%v -> % x, wanted % x", tc.Data, b, tc.Encoded)
+		}
+	}
+
+	b = e.AppendArray(b[:0], -1)
+	b = e.AppendBreak(b)
+
+	if i := (Decoder{}).CheckDeterministic(b, 0); Error(i).Code() != ErrNotDeterministic {
+		tb.Errorf("indefinite length: %v", Error(i))
+	}
+}
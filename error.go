@@ -1,6 +1,9 @@
 package cbor
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 type Error int
 
@@ -10,9 +13,19 @@ const (
 	ErrMalformed
 	ErrUnexpectedEOF
 	ErrOverflow
+	ErrLimit
+	ErrNotDeterministic
+	ErrInvalid
+	ErrType
 
 	errorMask       = 0xff
 	errorIndexShift = 8
+
+	// ErrType errors also keep expected and actual tags if int is large enough.
+	typeErrorPacked     = strconv.IntSize == 64
+	typeErrorExpShift   = 8
+	typeErrorActShift   = 16
+	typeErrorIndexShift = 24
 )
 
 var errStrings = []string{
@@ -20,13 +33,43 @@ var errStrings = []string{
 	"short buffer",
 	"malformed",
 	"unexpected eof",
+	"overflow",
+	"limit exceeded",
+	"not deterministic",
+	"invalid value",
+	"unexpected type",
 }
 
 func newError(code, index int) int {
 	return -(index<<errorIndexShift | code)
 }
 
+// newTypeError returns ErrType error keeping expected and actual tags.
+// Tags are dropped on 32-bit platforms not to limit the index.
+func newTypeError(index int, exp, act Tag) int {
+	if !typeErrorPacked {
+		return newError(ErrType, index)
+	}
+
+	return -(index<<typeErrorIndexShift | int(act)<<typeErrorActShift | int(exp)<<typeErrorExpShift | ErrType)
+}
+
+// MakeError makes Error with the code and the offset it happened at.
+// It's for packages built on top of Decoder to report errors the same way.
+// ErrType errors made this way have no expected and actual tags.
+func MakeError(code, index int) Error {
+	if code == ErrType {
+		return Error(newTypeError(index, 0, 0))
+	}
+
+	return Error(newError(code, index))
+}
+
 func (e Error) Error() string {
+	if e.typed() && (e.Expected() != 0 || e.Actual() != 0) {
+		return fmt.Sprintf("at %d (%#[1]x): %v: expected %v, got %v", e.Index(), errStrings[ErrType], tagString(e.Expected()), tagString(e.Actual()))
+	}
+
 	return fmt.Sprintf("at %d (%#[1]x): %v", e.Index(), errStrings[e.Code()])
 }
 
@@ -43,9 +86,39 @@ func (e Error) Index() int {
 		return int(e)
 	}
 
+	if e.typed() {
+		return int(-e >> typeErrorIndexShift)
+	}
+
 	return int(-e >> errorIndexShift)
 }
 
+// Expected returns the expected tag of ErrType error.
+// It's a major type or a raw simple value.
+// It's zero on 32-bit platforms.
+func (e Error) Expected() Tag {
+	if !e.typed() {
+		return 0
+	}
+
+	return Tag(-e >> typeErrorExpShift)
+}
+
+// Actual returns the first byte of the value which caused ErrType error.
+// It's zero on 32-bit platforms.
+func (e Error) Actual() Tag {
+	if !e.typed() {
+		return 0
+	}
+
+	return Tag(-e >> typeErrorActShift)
+}
+
+// typed reports whether the error keeps the tags.
+func (e Error) typed() bool {
+	return typeErrorPacked && e.Code() == ErrType
+}
+
 func (e Error) CodeIndex() (code, index int) {
 	return e.Code(), e.Index()
 }
@@ -14,6 +14,10 @@ func TestError(tb *testing.T) {
 	} {
 		e := newError(tc.Code, tc.Index)
 
+		if MakeError(tc.Code, tc.Index) != Error(e) {
+			tb.Errorf("code-index %#x %#x: make error mismatch", tc.Code, tc.Index)
+		}
+
 		code, index := Error(e).CodeIndex()
 		if code != tc.Code || index != tc.Index {
 			tb.Errorf("code-index %#x %#x -> error %#x -> code-index %#x %#x", tc.Code, tc.Index, e, code, index)
@@ -28,3 +32,19 @@ func TestError(tb *testing.T) {
 		}
 	}
 }
+
+func TestErrorString(tb *testing.T) {
+	for code := ErrShortBuffer; code <= ErrType; code++ {
+		e := MakeError(code, 5)
+
+		s := e.Error()
+		if s == "at 5 (0x5): " || e.Code() != code || e.Index() != 5 {
+			tb.Errorf("code %d: %v  %v %v", code, s, e.Code(), e.Index())
+		}
+	}
+
+	e := MakeError(ErrType, 5)
+	if s := e.Error(); s != "at 5 (0x5): unexpected type" || e.Expected() != 0 || e.Actual() != 0 {
+		tb.Errorf("type error: %v  %v %v", s, e.Expected(), e.Actual())
+	}
+}
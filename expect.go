@@ -0,0 +1,95 @@
+package cbor
+
+// ExpectTag decodes the head of the value at st checking its major type.
+// ErrType is returned if the type differs.
+func (d Decoder) ExpectTag(b []byte, st int, tag Tag) (sub int64, i int) {
+	t, sub, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if t != tag {
+		return 0, newTypeError(st, tag, Tag(b[st]))
+	}
+
+	return sub, i
+}
+
+// ExpectString returns definite or indefinite length string content.
+// Definite length content is not copied.
+func (d Decoder) ExpectString(b []byte, st int) (s []byte, i int) {
+	return d.expectContent(b, st, String)
+}
+
+// ExpectBytes is like ExpectString but for Bytes.
+func (d Decoder) ExpectBytes(b []byte, st int) (s []byte, i int) {
+	return d.expectContent(b, st, Bytes)
+}
+
+// ExpectArray decodes Array head and returns its length, -1 for indefinite length.
+func (d Decoder) ExpectArray(b []byte, st int) (l, i int) {
+	sub, i := d.ExpectTag(b, st, Array)
+
+	return int(sub), i
+}
+
+// ExpectMap decodes Map head and returns the number of pairs, -1 for indefinite length.
+func (d Decoder) ExpectMap(b []byte, st int) (l, i int) {
+	sub, i := d.ExpectTag(b, st, Map)
+
+	return int(sub), i
+}
+
+// ExpectBool decodes false or true.
+func (d Decoder) ExpectBool(b []byte, st int) (v bool, i int) {
+	_, _, i = d.Tag(b, st)
+	if i < 0 {
+		return false, i
+	}
+
+	switch Tag(b[st]) {
+	case Simple | False:
+		return false, i
+	case Simple | True:
+		return true, i
+	}
+
+	return false, newTypeError(st, Simple|False, Tag(b[st]))
+}
+
+// ExpectNull checks the value is null.
+func (d Decoder) ExpectNull(b []byte, st int) (i int) {
+	_, _, i = d.Tag(b, st)
+	if i < 0 {
+		return i
+	}
+
+	if Tag(b[st]) != Simple|Null {
+		return newTypeError(st, Simple|Null, Tag(b[st]))
+	}
+
+	return i
+}
+
+// ExpectFloat decodes float value.
+// Integers are not converted, ErrType is returned for them.
+func (d Decoder) ExpectFloat(b []byte, st int) (v float64, i int) {
+	_, _, i = d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if !d.isFloat(Tag(b[st])) {
+		return 0, newTypeError(st, Simple|Float64, Tag(b[st]))
+	}
+
+	return d.Float(b, st)
+}
+
+func (d Decoder) expectContent(b []byte, st int, tag Tag) ([]byte, int) {
+	if _, i := d.ExpectTag(b, st, tag); i < 0 {
+		return nil, i
+	}
+
+	return d.content(b, st)
+}
@@ -0,0 +1,122 @@
+package cbor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpect(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	b, err := ParseDiag(`["str", (_ "a", "b"), h'0102', {1: true}, false, null, 1.5, [_ ]]`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	l, i := d.ExpectArray(b, 0)
+	if l != 8 {
+		tb.Fatalf("array: %v %v", l, Error(i))
+	}
+
+	s, i := d.ExpectString(b, i)
+	if string(s) != "str" {
+		tb.Errorf("string: %q %v", s, Error(i))
+	}
+
+	s, i = d.ExpectString(b, i)
+	if string(s) != "ab" {
+		tb.Errorf("indefinite string: %q %v", s, Error(i))
+	}
+
+	s, i = d.ExpectBytes(b, i)
+	if string(s) != "\x01\x02" {
+		tb.Errorf("bytes: %q %v", s, Error(i))
+	}
+
+	l, i = d.ExpectMap(b, i)
+	if l != 1 {
+		tb.Errorf("map: %v %v", l, Error(i))
+	}
+
+	i = d.Skip(b, i)
+
+	v, i := d.ExpectBool(b, i)
+	if !v || i < 0 {
+		tb.Errorf("bool: %v %v", v, Error(i))
+	}
+
+	v, i = d.ExpectBool(b, i)
+	if v || i < 0 {
+		tb.Errorf("bool: %v %v", v, Error(i))
+	}
+
+	i = d.ExpectNull(b, i)
+	if i < 0 {
+		tb.Fatalf("null: %v", Error(i))
+	}
+
+	f, i := d.ExpectFloat(b, i)
+	if f != 1.5 {
+		tb.Errorf("float: %v %v", f, Error(i))
+	}
+
+	l, i = d.ExpectArray(b, i)
+	if l != -1 || !d.Break(b, &i) || i != len(b) {
+		tb.Errorf("indefinite array: %v %v", l, Error(i))
+	}
+}
+
+func TestExpectErrors(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	b, err := ParseDiag(`[1, "str"]`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	for j, i := range []int{
+		func() int { _, i := d.ExpectMap(b, 0); return i }(),
+		func() int { _, i := d.ExpectString(b, 1); return i }(),
+		func() int { _, i := d.ExpectBytes(b, 2); return i }(),
+		func() int { _, i := d.ExpectBool(b, 1); return i }(),
+		d.ExpectNull(b, 2),
+		func() int { _, i := d.ExpectFloat(b, 1); return i }(),
+		func() int { _, i := d.Int(b, 2); return i }(),
+	} {
+		exp := []struct {
+			Off      int
+			Exp, Act Tag
+			Msg      string
+		}{
+			{0, Map, 0x82, "expected map, got array"},
+			{1, String, 0x01, "expected string, got int"},
+			{2, Bytes, 0x63, "expected bytes, got string"},
+			{1, Simple | False, 0x01, "expected bool, got int"},
+			{2, Simple | Null, 0x63, "expected null, got string"},
+			{1, Simple | Float64, 0x01, "expected float, got int"},
+			{2, Int, 0x63, "expected int, got string"},
+		}[j]
+
+		err := Error(i)
+
+		if err.Code() != ErrType || err.Index() != exp.Off || err.Expected() != exp.Exp || err.Actual() != exp.Act {
+			tb.Errorf("%d: error %d %v exp %#x act %#x", j, err.Code(), err.Index(), err.Expected(), err.Actual())
+		}
+
+		if !strings.HasSuffix(err.Error(), exp.Msg) {
+			tb.Errorf("%d: error %v", j, err)
+		}
+	}
+
+	if _, i := d.ExpectString(b[:4], 2); Error(i).Code() != ErrUnexpectedEOF {
+		tb.Errorf("truncated: %v", Error(i))
+	}
+
+	if e := Error(newError(ErrMalformed, 5)); e.Index() != 5 || e.Expected() != 0 || e.Actual() != 0 {
+		tb.Errorf("not a type error: %v", e)
+	}
+
+	if e := Error(newTypeError(1<<30, String, Simple|Null)); e.Code() != ErrType || e.Index() != 1<<30 {
+		tb.Errorf("large index: %v", e)
+	}
+}
@@ -0,0 +1,90 @@
+package cbor
+
+type (
+	signed interface {
+		~int | ~int8 | ~int16 | ~int32 | ~int64
+	}
+
+	unsigned interface {
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+	}
+)
+
+// Int decodes Int or Neg value.
+// ErrType is returned for other types and ErrOverflow if the value doesn't fit into the result type.
+func (d Decoder) Int(b []byte, st int) (int, int) { return decodeSigned[int](d, b, st) }
+
+// Int8 is like Int but for int8.
+func (d Decoder) Int8(b []byte, st int) (int8, int) { return decodeSigned[int8](d, b, st) }
+
+// Int16 is like Int but for int16.
+func (d Decoder) Int16(b []byte, st int) (int16, int) { return decodeSigned[int16](d, b, st) }
+
+// Int32 is like Int but for int32.
+func (d Decoder) Int32(b []byte, st int) (int32, int) { return decodeSigned[int32](d, b, st) }
+
+// Int64 is like Int but for int64.
+func (d Decoder) Int64(b []byte, st int) (int64, int) { return decodeSigned[int64](d, b, st) }
+
+// Uint decodes Int value.
+// ErrOverflow is returned for Neg values and values which don't fit into the result type.
+// ErrType is returned for other types.
+func (d Decoder) Uint(b []byte, st int) (uint, int) { return decodeUnsigned[uint](d, b, st) }
+
+// Uint8 is like Uint but for uint8.
+func (d Decoder) Uint8(b []byte, st int) (uint8, int) { return decodeUnsigned[uint8](d, b, st) }
+
+// Uint16 is like Uint but for uint16.
+func (d Decoder) Uint16(b []byte, st int) (uint16, int) { return decodeUnsigned[uint16](d, b, st) }
+
+// Uint32 is like Uint but for uint32.
+func (d Decoder) Uint32(b []byte, st int) (uint32, int) { return decodeUnsigned[uint32](d, b, st) }
+
+// Uint64 is like Uint but for uint64.
+func (d Decoder) Uint64(b []byte, st int) (uint64, int) { return decodeUnsigned[uint64](d, b, st) }
+
+func decodeSigned[T signed](d Decoder, b []byte, st int) (T, int) {
+	tag, v, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if tag != Int && tag != Neg {
+		return 0, newTypeError(st, Int, Tag(b[st]))
+	}
+
+	if v < 0 {
+		return 0, newError(ErrOverflow, st)
+	}
+
+	if tag == Neg {
+		v = -1 - v
+	}
+
+	if int64(T(v)) != v {
+		return 0, newError(ErrOverflow, st)
+	}
+
+	return T(v), i
+}
+
+func decodeUnsigned[T unsigned](d Decoder, b []byte, st int) (T, int) {
+	tag, v, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	switch tag {
+	case Int:
+	case Neg:
+		return 0, newError(ErrOverflow, st)
+	default:
+		return 0, newTypeError(st, Int, Tag(b[st]))
+	}
+
+	if uint64(T(v)) != uint64(v) {
+		return 0, newError(ErrOverflow, st)
+	}
+
+	return T(v), i
+}
@@ -0,0 +1,85 @@
+package cbor
+
+import "testing"
+
+func TestIntAccessors(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	type res struct {
+		V    int64
+		Code int
+	}
+
+	ok := func(v int64) res { return res{V: v} }
+	ovf := res{Code: ErrOverflow}
+	typ := res{Code: ErrType}
+
+	for _, tc := range []struct {
+		Diag string
+
+		I8, I16, I32, I64, U8, U16, U32, U64 res
+	}{
+		{`0`, ok(0), ok(0), ok(0), ok(0), ok(0), ok(0), ok(0), ok(0)},
+		{`127`, ok(127), ok(127), ok(127), ok(127), ok(127), ok(127), ok(127), ok(127)},
+		{`128`, ovf, ok(128), ok(128), ok(128), ok(128), ok(128), ok(128), ok(128)},
+		{`-128`, ok(-128), ok(-128), ok(-128), ok(-128), ovf, ovf, ovf, ovf},
+		{`-129`, ovf, ok(-129), ok(-129), ok(-129), ovf, ovf, ovf, ovf},
+		{`65535`, ovf, ovf, ok(65535), ok(65535), ovf, ok(65535), ok(65535), ok(65535)},
+		{`-2147483648`, ovf, ovf, ok(-2147483648), ok(-2147483648), ovf, ovf, ovf, ovf},
+		{`4294967295`, ovf, ovf, ovf, ok(4294967295), ovf, ovf, ok(4294967295), ok(4294967295)},
+		{`18446744073709551615`, ovf, ovf, ovf, ovf, ovf, ovf, ovf, ok(-1)},
+		{`-18446744073709551616`, ovf, ovf, ovf, ovf, ovf, ovf, ovf, ovf},
+		{`"1"`, typ, typ, typ, typ, typ, typ, typ, typ},
+		{`1.0`, typ, typ, typ, typ, typ, typ, typ, typ},
+		{`1(1)`, typ, typ, typ, typ, typ, typ, typ, typ},
+	} {
+		b, err := ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Fatalf("parse diag: %v", err)
+		}
+
+		check := func(name string, v int64, i int, exp res) {
+			tb.Helper()
+
+			if exp.Code != ErrOK {
+				if Error(i).Code() != exp.Code {
+					tb.Errorf("%v: %v: error %v, wanted %v", tc.Diag, name, Error(i), errStrings[exp.Code])
+				}
+
+				return
+			}
+
+			if i != len(b) || v != exp.V {
+				tb.Errorf("%v: %v: decoded %v %v, wanted %v", tc.Diag, name, v, Error(i), exp.V)
+			}
+		}
+
+		i8, i := d.Int8(b, 0)
+		check("int8", int64(i8), i, tc.I8)
+
+		i16, i := d.Int16(b, 0)
+		check("int16", int64(i16), i, tc.I16)
+
+		i32, i := d.Int32(b, 0)
+		check("int32", int64(i32), i, tc.I32)
+
+		i64, i := d.Int64(b, 0)
+		check("int64", i64, i, tc.I64)
+
+		u8, i := d.Uint8(b, 0)
+		check("uint8", int64(u8), i, tc.U8)
+
+		u16, i := d.Uint16(b, 0)
+		check("uint16", int64(u16), i, tc.U16)
+
+		u32, i := d.Uint32(b, 0)
+		check("uint32", int64(u32), i, tc.U32)
+
+		u64, i := d.Uint64(b, 0)
+		check("uint64", int64(u64), i, tc.U64)
+	}
+
+	if _, i := d.Int([]byte{0x19, 0x01}, 0); Error(i).Code() != ErrUnexpectedEOF {
+		tb.Errorf("truncated: %v", Error(i))
+	}
+}
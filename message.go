@@ -0,0 +1,335 @@
+package cbor
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ParseMessage validates b and makes a Message of it.
+// b must contain exactly one data item.
+// Message keeps a reference to b, so it must not be changed while Message is used.
+func ParseMessage(b []byte) (*Message, error) {
+	m := &Message{
+		b: b,
+		d: MakeDecoder(),
+	}
+
+	i := m.d.Validate(b, 0)
+	if i < 0 {
+		return nil, Error(i)
+	}
+
+	if i != len(b) {
+		return nil, fmt.Errorf("at %d: %w", i, errExtraData)
+	}
+
+	return m, nil
+}
+
+// Root returns the top-level Value.
+func (m *Message) Root() Value {
+	return Value{m: m, st: m.root}
+}
+
+// Bytes returns the underlaying buffer.
+func (m *Message) Bytes() []byte {
+	return m.b
+}
+
+func (m *Message) kids(st int) []int {
+	if c, ok := m.children[st]; ok {
+		return c
+	}
+
+	d := m.d
+
+	tag, l, i := d.Tag(m.b, st)
+
+	n := int(l)
+	if tag == Map {
+		n *= 2
+	}
+
+	c := make([]int, 0, csel(n > 0, n, 8))
+
+	for el := 0; l < 0 && !d.Break(m.b, &i) || el < n; el++ {
+		c = append(c, i)
+		i = d.Skip(m.b, i)
+	}
+
+	if m.children == nil {
+		m.children = make(map[int][]int)
+	}
+
+	m.children[st] = c
+
+	return c
+}
+
+// Exists reports whether the Value refers to an existing item.
+func (v Value) Exists() bool {
+	return v.m != nil
+}
+
+// Offset returns the Value offset in the Message.
+func (v Value) Offset() int {
+	return v.st
+}
+
+// Raw returns the encoded Value including labels.
+func (v Value) Raw() []byte {
+	if v.m == nil {
+		return nil
+	}
+
+	raw, _ := v.m.d.Raw(v.m.b, v.st)
+
+	return raw
+}
+
+// Tag returns the Value major type.
+// Labeled values are reported as Labeled, see Content.
+// It returns Simple|None for non-existent Value.
+func (v Value) Tag() Tag {
+	if v.m == nil {
+		return Simple | None
+	}
+
+	return v.m.d.TagOnly(v.m.b, v.st)
+}
+
+// Label returns the tag number of Labeled Value.
+func (v Value) Label() (x uint64, ok bool) {
+	if v.Tag() != Labeled {
+		return 0, false
+	}
+
+	_, sub, _ := v.m.d.Tag(v.m.b, v.st)
+
+	return uint64(sub), true
+}
+
+// Content returns Value labels point to.
+// It returns v itself if it's not Labeled.
+// All the other methods call Content implicitly.
+func (v Value) Content() Value {
+	if v.m == nil {
+		return v
+	}
+
+	for v.m.d.TagOnly(v.m.b, v.st) == Labeled {
+		_, _, v.st = v.m.d.Tag(v.m.b, v.st)
+	}
+
+	return v
+}
+
+// Len returns the number of elements of Array, pairs of Map, or bytes of Bytes or String.
+// It returns 0 for other types.
+func (v Value) Len() int {
+	v = v.Content()
+	if v.m == nil {
+		return 0
+	}
+
+	tag, l, _ := v.m.d.Tag(v.m.b, v.st)
+
+	switch {
+	case tag == Array:
+		return csel(l >= 0, int(l), len(v.m.kids(v.st)))
+	case tag == Map:
+		return csel(l >= 0, int(l), len(v.m.kids(v.st))/2)
+	case (tag == Bytes || tag == String) && l >= 0:
+		return int(l)
+	case tag == Bytes || tag == String:
+		n := 0
+
+		for _, ch := range v.m.kids(v.st) {
+			_, cl, _ := v.m.d.Tag(v.m.b, ch)
+			n += int(cl)
+		}
+
+		return n
+	}
+
+	return 0
+}
+
+// Indefinite reports whether Array, Map, Bytes or String is encoded with indefinite length.
+func (v Value) Indefinite() bool {
+	v = v.Content()
+
+	switch v.Tag() {
+	case Array, Map, Bytes, String:
+		return v.m.b[v.st]&SubMask == LenBreak
+	}
+
+	return false
+}
+
+// Index returns i-th element of Array.
+func (v Value) Index(i int) Value {
+	v = v.Content()
+	if v.Tag() != Array {
+		return Value{}
+	}
+
+	kids := v.m.kids(v.st)
+	if i < 0 || i >= len(kids) {
+		return Value{}
+	}
+
+	return Value{m: v.m, st: kids[i]}
+}
+
+// Key returns Map value by String key.
+func (v Value) Key(k string) Value {
+	v = v.Content()
+	if v.Tag() != Map {
+		return Value{}
+	}
+
+	kids := v.m.kids(v.st)
+
+	for j := 0; j < len(kids); j += 2 {
+		kv := Value{m: v.m, st: kids[j]}
+
+		if kv.Tag() == String && kv.String() == k {
+			return Value{m: v.m, st: kids[j+1]}
+		}
+	}
+
+	return Value{}
+}
+
+// MapKey returns i-th key of Map.
+func (v Value) MapKey(i int) Value {
+	return v.mapChild(2 * i)
+}
+
+// MapValue returns i-th value of Map.
+func (v Value) MapValue(i int) Value {
+	return v.mapChild(2*i + 1)
+}
+
+func (v Value) mapChild(i int) Value {
+	v = v.Content()
+	if v.Tag() != Map {
+		return Value{}
+	}
+
+	kids := v.m.kids(v.st)
+	if i < 0 || i >= len(kids) {
+		return Value{}
+	}
+
+	return Value{m: v.m, st: kids[i]}
+}
+
+// Int returns integer value.
+// It returns 0 if the Value is not Int or Neg or if it doesn't fit into int64.
+func (v Value) Int() int64 {
+	v = v.Content()
+	if tag := v.Tag(); tag != Int && tag != Neg {
+		return 0
+	}
+
+	x, _ := v.m.d.Signed(v.m.b, v.st)
+
+	return x
+}
+
+// Uint returns unsigned integer value.
+// It returns 0 if the Value is not Int.
+func (v Value) Uint() uint64 {
+	v = v.Content()
+	if v.Tag() != Int {
+		return 0
+	}
+
+	x, _ := v.m.d.Unsigned(v.m.b, v.st)
+
+	return x
+}
+
+// Float returns float value. Integers are converted.
+// It returns 0 for other types.
+func (v Value) Float() float64 {
+	v = v.Content()
+
+	switch tag := v.Tag(); {
+	case tag == Int || tag == Neg:
+		return float64(v.Int())
+	case v.m != nil && v.m.d.isFloat(v.m.d.TagRaw(v.m.b, v.st)):
+		f, _ := v.m.d.Float(v.m.b, v.st)
+
+		return f
+	}
+
+	return 0
+}
+
+// Bool returns bool value.
+// It returns false for other types.
+func (v Value) Bool() bool {
+	v = v.Content()
+
+	return v.m != nil && v.m.d.TagRaw(v.m.b, v.st) == Simple|True
+}
+
+// IsNull reports whether the Value is Null or Undefined.
+func (v Value) IsNull() bool {
+	v = v.Content()
+	if v.m == nil {
+		return false
+	}
+
+	raw := v.m.d.TagRaw(v.m.b, v.st)
+
+	return raw == Simple|Null || raw == Simple|Undefined
+}
+
+// String returns String or Bytes value as a string without copying.
+// The string refers to the Message buffer the same way Bytes does,
+// so the buffer must not be changed while the string is used.
+// It returns "" for other types.
+func (v Value) String() string {
+	s := v.Bytes()
+
+	return *(*string)(unsafe.Pointer(&s))
+}
+
+// Bytes returns String or Bytes value without copying.
+// Indefinite length strings are concatenated into a new slice.
+// It returns nil for other types.
+func (v Value) Bytes() []byte {
+	v = v.Content()
+	if tag := v.Tag(); tag != String && tag != Bytes {
+		return nil
+	}
+
+	if v.m.b[v.st]&SubMask != LenBreak {
+		s, _ := v.m.d.Bytes(v.m.b, v.st)
+		return s
+	}
+
+	var s []byte
+
+	for _, ch := range v.m.kids(v.st) {
+		p, _ := v.m.d.Bytes(v.m.b, ch)
+		s = append(s, p...)
+	}
+
+	return s
+}
+
+// Decode decodes the Value into x using Decoder.DecodeValue.
+func (v Value) Decode(x any) error {
+	if v.m == nil {
+		return errNotExist
+	}
+
+	_, err := v.m.d.DecodeValue(v.m.b, v.st, x)
+
+	return err
+}
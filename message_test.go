@@ -0,0 +1,93 @@
+package cbor
+
+import (
+	"testing"
+)
+
+var testSink string
+
+func TestMessage(tb *testing.T) {
+	var e Encoder
+	var b []byte
+
+	b = e.AppendMap(b, 3)
+	b = e.AppendString(b, "arr")
+	b = e.AppendArray(b, -1)
+	b = e.AppendInt(b, 1)
+	b = e.AppendInt(b, -2)
+	b = e.AppendFloat(b, 1.5)
+	b = e.AppendBreak(b)
+	b = e.AppendString(b, "str")
+	b = e.AppendTag(b, String, -1)
+	b = e.AppendString(b, "ab")
+	b = e.AppendString(b, "c")
+	b = e.AppendBreak(b)
+	b = e.AppendString(b, "lab")
+	b = e.AppendLabeled(b, 10)
+	b = e.AppendMap(b, 1)
+	b = e.AppendString(b, "x")
+	b = e.AppendBool(b, true)
+
+	m, err := ParseMessage(b)
+	if err != nil {
+		tb.Fatalf("parse: %v", err)
+	}
+
+	root := m.Root()
+
+	if root.Tag() != Map || root.Len() != 3 {
+		tb.Errorf("root: %v %v", root.Tag(), root.Len())
+	}
+
+	arr := root.Key("arr")
+	if arr.Len() != 3 || arr.Index(0).Int() != 1 || arr.Index(1).Int() != -2 || arr.Index(2).Float() != 1.5 || arr.Index(0).Float() != 1 {
+		tb.Errorf("arr: %x", arr.Raw())
+	}
+
+	if v := arr.Index(3); v.Exists() || v.Int() != 0 || v.Tag() != Simple|None {
+		tb.Errorf("out of bounds: %v", v)
+	}
+
+	if s := root.Key("str"); s.String() != "abc" || s.Len() != 3 || !s.Indefinite() || root.Indefinite() {
+		tb.Errorf("str: %q %v %v", s.String(), s.Len(), s.Indefinite())
+	}
+
+	lab := root.Key("lab")
+	if x, ok := lab.Label(); !ok || x != 10 || lab.Tag() != Labeled || lab.Content().Tag() != Map {
+		tb.Errorf("label: %v %v", x, ok)
+	}
+
+	if !lab.Key("x").Bool() || lab.Key("y").Exists() {
+		tb.Errorf("labeled map key")
+	}
+
+	if root.MapKey(2).String() != "lab" || root.MapValue(0).Len() != 3 || root.MapKey(3).Exists() {
+		tb.Errorf("map iteration")
+	}
+
+	var x map[string]bool
+
+	err = lab.Decode(&x)
+	if err != nil || !x["x"] {
+		tb.Errorf("decode: %v %v", x, err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = root.Key("arr").Index(2).Float() + float64(root.Key("lab").Key("x").Len())
+		testSink = root.MapKey(2).String()
+	})
+
+	if allocs != 0 {
+		tb.Errorf("allocs: %v", allocs)
+	}
+
+	_, err = ParseMessage(b[:len(b)-1])
+	if err == nil {
+		tb.Errorf("expected error on truncated message")
+	}
+
+	_, err = ParseMessage(append(b, 0))
+	if err == nil {
+		tb.Errorf("expected error on extra data")
+	}
+}
@@ -10,30 +10,141 @@ type (
 	Reader struct {
 		io.Reader
 
+		Limits Limits
+
+		// MaxBuffer limits the buffer size, so an item larger than that
+		// can't be decoded with Decode and ErrLimit is returned.
+		// Next returns strings in parts, so they are not limited.
+		// Zero means no limit.
+		MaxBuffer int
+
+		// Resync enables recovery from malformed data in Decode.
+		// Reader skips bytes until the next well-formed item which fits into MaxBuffer
+		// and calls OnResync with the number of skipped bytes and the first error.
+		// If ResyncMagic is set only items labeled with the self-describe tag 55799 are accepted.
+		// It's not applied inside containers entered by Next.
+		Resync      bool
+		ResyncMagic bool
+		OnResync    func(skipped int64, err error)
+
+		skipped int64
+		skipErr error
+
 		b    []byte
 		i    int
 		boff int64
+
+		// token iterator state
+		stack   []readerFrame
+		strLeft int64 // unread bytes of the current string
+		strTok  Token
+		items   int
+	}
+
+	readerFrame struct {
+		tag Tag
+		n   int64 // remaining items, -1 for indefinite
+		el  int   // read items of indefinite container
 	}
 )
 
+const defaultReaderSize = 1024
+
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
 		Reader: r,
 	}
 }
 
+// NewReaderSize creates a Reader with the initial buffer of size bytes.
+func NewReaderSize(r io.Reader, size int) *Reader {
+	return &Reader{
+		Reader: r,
+		b:      make([]byte, 0, size),
+	}
+}
+
+// Reset resets the Reader state to read from rd keeping its settings.
+// If buf is not nil, it's used as the buffer, its capacity is the initial buffer size.
+// buf could be taken from a pool and returned back after the Reader is done, see Buffer.
+func (r *Reader) Reset(rd io.Reader, buf []byte) {
+	if buf != nil {
+		r.b = buf
+	}
+
+	r.Reader = rd
+	r.b = r.b[:0]
+	r.i = 0
+	r.boff = 0
+	r.stack = r.stack[:0]
+	r.strLeft = 0
+	r.strTok = Token{}
+	r.items = 0
+	r.skipped = 0
+	r.skipErr = nil
+}
+
+// Buffer returns the internal buffer, so it could be reused after the Reader is done.
+func (r *Reader) Buffer() []byte {
+	return r.b[:0]
+}
+
+// Decode reads the next data item entirely.
+// Returned data refers to the internal buffer and is valid until the next Reader call,
+// use DecodeCopy to keep it longer.
+// It can be called inside a container entered by Next to read its element,
+// but not on the Break of indefinite length container, use Peek to check for it.
 func (r *Reader) Decode() (data []byte, err error) {
+	if r.strLeft != 0 {
+		return nil, Error(r.newError(ErrMalformed, r.i))
+	}
+
 	end, err := r.skipRead()
 	if err != nil {
 		return nil, err
 	}
 
 	st := r.i
+
+	if len(r.stack) != 0 {
+		if r.b[st] == byte(Simple|Break) {
+			return nil, Error(r.newError(ErrMalformed, st))
+		}
+
+		r.itemDone()
+	}
+
 	r.i = end
 
 	return r.b[st:end:end], nil
 }
 
+// DecodeCopy reads the next data item and appends it to buf.
+func (r *Reader) DecodeCopy(buf []byte) ([]byte, error) {
+	data, err := r.Decode()
+	if err != nil {
+		return buf, err
+	}
+
+	return append(buf, data...), nil
+}
+
+// DecodeValue reads the next data item and decodes it into v using Decoder.DecodeValue.
+// Unmarshaler implementations are used as usual.
+func (r *Reader) DecodeValue(v any) error {
+	data, err := r.Decode()
+	if err != nil {
+		return err
+	}
+
+	d := MakeDecoder()
+	d.Limits = r.Limits
+
+	_, err = d.DecodeValue(data, 0, v)
+
+	return err
+}
+
 func (r *Reader) Read(p []byte) (n int, err error) {
 	end, err := r.skipRead()
 	if err != nil {
@@ -70,35 +181,151 @@ func (r *Reader) WriteTo(w io.Writer) (n int64, err error) {
 
 func (r *Reader) skipRead() (end int, err error) {
 	for {
-		end = r.skip(r.i)
+		var items int
+
+		end = r.skip(r.i, 0, &items)
 		//	println("skip", r.i, end)
+		if end > 0 && r.ResyncMagic && r.skipped != 0 && !r.magic(r.i, false) {
+			end = r.newError(ErrMalformed, r.i)
+		}
+
 		if end > 0 {
+			r.resynced()
 			return end, nil
 		}
 
-		if end < 0 {
+		if Error(end).Code() != ErrUnexpectedEOF {
+			if r.resync(end) {
+				continue
+			}
+
 			return 0, Error(end)
 		}
 
 		err = r.more()
+
+		var lim Error
+		if errors.As(err, &lim) && lim.Code() == ErrLimit && r.resync(int(lim)) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && r.i < len(r.b) {
+			if r.resync(end) {
+				continue
+			}
+
+			return 0, Error(end)
+		}
+		if errors.Is(err, io.EOF) {
+			r.resynced()
+		}
 		if err != nil {
 			return 0, err
 		}
 	}
 }
 
-func (r *Reader) skip(st int) (i int) {
+// resync skips one byte, or till the next magic if ResyncMagic is set, if Resync is enabled.
+func (r *Reader) resync(e int) bool {
+	if !r.Resync || len(r.stack) != 0 {
+		return false
+	}
+
+	if r.skipped == 0 {
+		r.skipErr = Error(e)
+	}
+
+	i := r.i + 1
+
+	if r.ResyncMagic {
+		for i < len(r.b) && !r.magic(i, true) {
+			i++
+		}
+	}
+
+	r.skipped += int64(i - r.i)
+	r.i = i
+
+	return true
+}
+
+// resynced reports skipped bytes if there are any.
+func (r *Reader) resynced() {
+	if r.skipped == 0 {
+		return
+	}
+
+	if r.OnResync != nil {
+		r.OnResync(r.skipped, r.skipErr)
+	}
+
+	r.skipped = 0
+	r.skipErr = nil
+}
+
+// magic reports whether self-describe tag 55799 (d9 d9 f7) is at i.
+// If prefix is set the magic prefix at the end of the buffer is reported as well.
+func (r *Reader) magic(i int, prefix bool) bool {
+	const m = "\xd9\xd9\xf7"
+
+	n := len(r.b) - i
+	if n > len(m) {
+		n = len(m)
+	}
+
+	if n < len(m) && !prefix {
+		return false
+	}
+
+	return string(r.b[i:i+n]) == m[:n]
+}
+
+func (r *Reader) skip(st, depth int, items *int) (i int) {
 	tag, sub, i := readTag(r.b, st)
 	//	println("tag", st, tag, sub, i)
 	if i < 0 {
 		return r.newError(-i, st)
 	}
 
+	if err := r.Limits.check(tag, sub, st, depth, items); err < 0 {
+		return r.newError(Error(err).Code(), st)
+	}
+
+	if sub < 0 && tag >= Bytes && tag <= Map && r.b[st]&SubMask != LenBreak {
+		return r.newError(ErrOverflow, st)
+	}
+
+	if r.b[st]&SubMask == LenBreak && (tag == Int || tag == Neg || tag == Labeled) {
+		return r.newError(ErrMalformed, st)
+	}
+
+	// each element takes at least one byte, so it can't fit into MaxBuffer
+	if r.MaxBuffer != 0 && tag >= Bytes && tag <= Map && sub > int64(r.MaxBuffer-(i-r.i)) {
+		return r.newError(ErrLimit, st)
+	}
+
 	switch tag {
 	case Int, Neg:
 		// already read
 	case Bytes, String:
-		i += int(sub)
+		if sub >= 0 {
+			i += int(sub)
+			break
+		}
+
+		for {
+			if i == len(r.b) {
+				return r.newError(ErrUnexpectedEOF, i)
+			}
+			if r.b[i] == byte(Simple|Break) {
+				i++
+				break
+			}
+
+			i = r.skip(i, depth+1, items)
+			if i < 0 {
+				return i
+			}
+		}
 	case Array, Map:
 		for el := 0; sub == -1 || el < int(sub); el++ {
 			if i == len(r.b) {
@@ -109,28 +336,33 @@ func (r *Reader) skip(st int) (i int) {
 				break
 			}
 
+			if sub == -1 {
+				if err := r.Limits.checkLen(tag, el, st); err < 0 {
+					return r.newError(Error(err).Code(), st)
+				}
+			}
+
 			if tag == Map {
-				i = r.skip(i)
+				i = r.skip(i, depth+1, items)
 				if i < 0 {
 					return i
 				}
 			}
 
-			i = r.skip(i)
+			i = r.skip(i, depth+1, items)
 			if i < 0 {
 				return i
 			}
 		}
 	case Labeled:
-		return r.skip(i)
+		return r.skip(i, depth+1, items)
 	case Simple:
 		switch sub {
 		case False,
 			True,
 			Null,
 			Undefined,
-			None,
-			Break:
+			None:
 		case Float8:
 			i += 1
 		case Float16:
@@ -159,10 +391,22 @@ func (r *Reader) more() (err error) {
 
 	end := len(r.b)
 
-	if len(r.b) == 0 {
-		r.b = make([]byte, 1024)
-	} else {
-		r.b = append(r.b, 0, 0, 0, 0, 0, 0, 0, 0)
+	switch {
+	case cap(r.b) == 0:
+		r.b = make([]byte, 0, csel(r.MaxBuffer != 0 && r.MaxBuffer < defaultReaderSize, r.MaxBuffer, defaultReaderSize))
+	case len(r.b) == cap(r.b):
+		if r.MaxBuffer != 0 && cap(r.b) >= r.MaxBuffer {
+			return Error(r.newError(ErrLimit, 0))
+		}
+
+		size := 2 * cap(r.b)
+		if r.MaxBuffer != 0 && size > r.MaxBuffer {
+			size = r.MaxBuffer
+		}
+
+		b := make([]byte, len(r.b), size)
+		copy(b, r.b)
+		r.b = b
 	}
 
 	r.b = r.b[:cap(r.b)]
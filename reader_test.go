@@ -0,0 +1,75 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReaderBuffer(tb *testing.T) {
+	var e Encoder
+
+	b := e.AppendString(nil, strings.Repeat("a", 100))
+	b = e.AppendString(b, strings.Repeat("b", 300))
+	b = e.AppendInt(b, 1)
+
+	buf := make([]byte, 0, 64)
+
+	r := NewReaderSize(nil, 0)
+	r.MaxBuffer = 256
+	r.Reset(bytes.NewReader(b), buf)
+
+	data, err := r.DecodeCopy(nil)
+	if err != nil || len(data) != 102 {
+		tb.Fatalf("decode: %x %v", data, err)
+	}
+
+	_, err = r.Decode()
+
+	var cerr Error
+	if !errors.As(err, &cerr) || cerr.Code() != ErrLimit || cerr.Index() != 102 {
+		tb.Errorf("expected limit error, got %v", err)
+	}
+
+	if cap(r.Buffer()) > 256 {
+		tb.Errorf("buffer cap: %v", cap(r.Buffer()))
+	}
+
+	// the same string could be read in parts
+	r.Reset(bytes.NewReader(b[102:]), buf)
+
+	var n int
+
+	for {
+		tok, err := r.Next()
+		if err != nil {
+			tb.Fatalf("next: %v", err)
+		}
+
+		n += len(tok.Data)
+
+		if !tok.More {
+			break
+		}
+	}
+
+	if n != 300 {
+		tb.Errorf("read %v bytes", n)
+	}
+
+	data, err = r.Decode()
+	if err != nil || !bytes.Equal(data, []byte{1}) {
+		tb.Errorf("last: %x %v", data, err)
+	}
+
+	_, err = r.Decode()
+	if !errors.Is(err, io.EOF) {
+		tb.Errorf("expected eof: %v", err)
+	}
+
+	if &r.Buffer()[:1][0] != &buf[:1][0] {
+		tb.Errorf("supplied buffer is not used")
+	}
+}
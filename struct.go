@@ -0,0 +1,413 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	structField struct {
+		name      string
+		key       []byte // encoded key
+		keyInt    int64
+		isInt     bool
+		omitEmpty bool
+		tagged    bool
+		index     []int
+		codec     *codec
+	}
+
+	structFields struct {
+		fields  []structField
+		sorted  []structField // by encoded key for FtDeterministic
+		toArray bool
+
+		names map[string]int
+		ints  map[int64]int
+	}
+)
+
+// ErrUnknownField is returned for unknown struct fields if FtDisallowUnknownFields is set.
+var ErrUnknownField = errors.New("unknown field")
+
+func (r *TagRegistry) structCodec(t reflect.Type, building map[reflect.Type]*codec) (encFunc, decFunc) {
+	fs, err := r.collectFields(t, building)
+	if err != nil {
+		return func(e Encoder, b []byte, v reflect.Value) ([]byte, error) { return b, err },
+			func(d Decoder, b []byte, st int, v reflect.Value) (int, error) { return st, err }
+	}
+
+	if fs.toArray {
+		return fs.encArray, fs.decArray
+	}
+
+	return fs.encMap, fs.decMap
+}
+
+func (r *TagRegistry) collectFields(t reflect.Type, building map[reflect.Type]*codec) (fs *structFields, err error) {
+	fs = &structFields{
+		names: map[string]int{},
+		ints:  map[int64]int{},
+	}
+
+	var all []structField
+	depths := map[string]int{}
+
+	var walk func(t reflect.Type, index []int, visited map[reflect.Type]bool) error
+
+	walk = func(t reflect.Type, index []int, visited map[reflect.Type]bool) error {
+		visited[t] = true
+		defer delete(visited, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+
+			tag := sf.Tag.Get("cbor")
+			if tag == "-" {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+
+			if sf.Name == "_" {
+				if len(index) == 0 && hasOpt(opts, "toarray") {
+					fs.toArray = true
+				}
+
+				continue
+			}
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				if visited[ft] {
+					continue
+				}
+
+				err := walk(ft, append(index[:len(index):len(index)], i), visited)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if !sf.IsExported() {
+				continue
+			}
+
+			f := structField{
+				name:      name,
+				tagged:    name != "",
+				omitEmpty: hasOpt(opts, "omitempty"),
+				index:     append(index[:len(index):len(index)], i),
+			}
+
+			if f.name == "" {
+				f.name = sf.Name
+			}
+
+			var e Encoder
+
+			if hasOpt(opts, "keyasint") {
+				f.keyInt, err = strconv.ParseInt(f.name, 10, 64)
+				if err != nil {
+					return fmt.Errorf("%v.%v: keyasint: %w", t, sf.Name, err)
+				}
+
+				f.isInt = true
+				f.key = e.AppendInt64(nil, f.keyInt)
+			} else {
+				f.key = e.AppendString(nil, f.name)
+			}
+
+			f.codec = r.buildCodec(sf.Type, building)
+
+			all = append(all, f)
+		}
+
+		return nil
+	}
+
+	err = walk(t, nil, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	// keep dominant fields only: the shallowest, tagged preferred, ambiguous dropped
+
+	for _, f := range all {
+		if d, ok := depths[string(f.key)]; !ok || len(f.index) < d {
+			depths[string(f.key)] = len(f.index)
+		}
+	}
+
+	for _, f := range all {
+		if len(f.index) != depths[string(f.key)] {
+			continue
+		}
+
+		var same, tagged int
+
+		for _, g := range all {
+			if len(g.index) == len(f.index) && bytes.Equal(g.key, f.key) {
+				same++
+
+				if g.tagged {
+					tagged++
+				}
+			}
+		}
+
+		if same == 1 || tagged == 1 && f.tagged {
+			fs.fields = append(fs.fields, f)
+		}
+	}
+
+	sort.SliceStable(fs.fields, func(i, j int) bool {
+		a, b := fs.fields[i].index, fs.fields[j].index
+
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+
+		return len(a) < len(b)
+	})
+
+	fs.sorted = append([]structField{}, fs.fields...)
+
+	sort.SliceStable(fs.sorted, func(i, j int) bool {
+		return bytes.Compare(fs.sorted[i].key, fs.sorted[j].key) < 0
+	})
+
+	for j, f := range fs.fields {
+		if f.isInt {
+			fs.ints[f.keyInt] = j
+		} else {
+			fs.names[f.name] = j
+		}
+	}
+
+	return fs, nil
+}
+
+func (fs *structFields) encMap(e Encoder, b []byte, v reflect.Value) (_ []byte, err error) {
+	list := fs.fields
+	if e.Flags.Is(FtDeterministic) {
+		list = fs.sorted
+	}
+
+	n := 0
+
+	for _, f := range list {
+		if fv, ok := fieldByIndex(v, f.index, false); ok && !(f.omitEmpty && isEmptyValue(fv)) {
+			n++
+		}
+	}
+
+	b = e.AppendMap(b, n)
+
+	for _, f := range list {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		b = append(b, f.key...)
+
+		b, err = f.codec.enc(e, b, fv)
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+func (fs *structFields) encArray(e Encoder, b []byte, v reflect.Value) (_ []byte, err error) {
+	b = e.AppendArray(b, len(fs.fields))
+
+	for _, f := range fs.fields {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			b = e.AppendNull(b)
+			continue
+		}
+
+		b, err = f.codec.enc(e, b, fv)
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+func (fs *structFields) decMap(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	tag, l, i := d.Tag(b, st)
+	if tag != Map {
+		return st, typeError(b, st, v.Type())
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < int(l); n++ {
+		kst := i
+		j := -1
+
+		switch tag := Tag(b[i]) & TagMask; tag {
+		case String:
+			var k []byte
+
+			if b[i]&SubMask == LenBreak {
+				k, i = d.appendString(nil, b, i)
+			} else {
+				k, i = d.Bytes(b, i)
+			}
+
+			if x, ok := fs.names[string(k)]; ok {
+				j = x
+			}
+		case Int, Neg:
+			k, ki := d.Signed(b, i)
+			i = d.Skip(b, i)
+
+			if x, ok := fs.ints[k]; ok && ki >= 0 {
+				j = x
+			}
+		default:
+			i = d.Skip(b, i)
+		}
+
+		if j < 0 {
+			if d.Flags.Is(FtDisallowUnknownFields) {
+				return kst, fmt.Errorf("at %d: %w", kst, ErrUnknownField)
+			}
+
+			i = d.Skip(b, i)
+
+			continue
+		}
+
+		f := fs.fields[j]
+
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			i = d.Skip(b, i)
+			continue
+		}
+
+		i, err = f.codec.dec(d, b, i, fv)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return i, nil
+}
+
+func (fs *structFields) decArray(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	tag, l, i := d.Tag(b, st)
+	if tag != Array {
+		return st, typeError(b, st, v.Type())
+	}
+
+	for n := 0; l < 0 && !d.Break(b, &i) || n < int(l); n++ {
+		if n >= len(fs.fields) {
+			if d.Flags.Is(FtDisallowUnknownFields) {
+				return i, fmt.Errorf("at %d: %w", i, ErrUnknownField)
+			}
+
+			i = d.Skip(b, i)
+
+			continue
+		}
+
+		f := fs.fields[n]
+
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			i = d.Skip(b, i)
+			continue
+		}
+
+		i, err = f.codec.dec(d, b, i, fv)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return i, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex,
+// but it reports false on nil embedded pointers instead of panicking or allocates them if alloc is set.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for k, x := range index {
+		if k != 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return v, false
+				}
+
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func hasOpt(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
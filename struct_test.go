@@ -0,0 +1,238 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type (
+	testTagged struct {
+		Name    string `cbor:"name"`
+		Skip    int    `cbor:"-"`
+		Empty   string `cbor:"empty,omitempty"`
+		Zero    int    `cbor:",omitempty"`
+		IntKey  int    `cbor:"1,keyasint"`
+		NegKey  string `cbor:"-2,keyasint,omitempty"`
+		Default bool
+	}
+
+	testArray struct {
+		_ struct{} `cbor:",toarray"`
+
+		A int
+		B string
+		C []int
+	}
+
+	TestBase struct {
+		ID   int `cbor:"id"`
+		Base string
+	}
+
+	TestInner struct {
+		Inner string
+		Both  int
+	}
+
+	testEmbedded struct {
+		TestBase
+		*TestInner
+
+		Base string // shadows TestBase.Base
+		Both int    `cbor:"both"`
+	}
+
+	testConflict struct {
+		testConflictA
+		testConflictB
+	}
+
+	testConflictA struct{ X, A int }
+	testConflictB struct{ X, B int }
+)
+
+func TestStructTags(tb *testing.T) {
+	e := Encoder{}
+
+	v := testTagged{Name: "n", Skip: 5, IntKey: 10, Default: true}
+
+	b, err := e.AppendValue(nil, v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	var exp []byte
+	exp = e.AppendMap(exp, 3)
+	exp = e.AppendString(exp, "name")
+	exp = e.AppendString(exp, "n")
+	exp = e.AppendInt(exp, 1)
+	exp = e.AppendInt(exp, 10)
+	exp = e.AppendString(exp, "Default")
+	exp = e.AppendBool(exp, true)
+
+	if !bytes.Equal(exp, b) {
+		tb.Errorf("encoded\n%s\nwanted\n%s", Dump(b), Dump(exp))
+	}
+
+	v.Skip = 0
+	v.NegKey = "neg"
+	v.Zero = 3
+
+	b, err = Marshal(v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	var r testTagged
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(v, r) {
+		tb.Errorf("roundtrip\n%+v\n%+v", v, r)
+	}
+}
+
+func TestStructToArray(tb *testing.T) {
+	var e Encoder
+
+	v := testArray{A: 1, B: "b", C: []int{2, 3}}
+
+	b, err := e.AppendValue(nil, v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	exp := []byte{0x83, 0x01, 0x61, 'b', 0x82, 0x02, 0x03}
+
+	if !bytes.Equal(exp, b) {
+		tb.Errorf("encoded % x, wanted % x", b, exp)
+	}
+
+	var r testArray
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(v, r) {
+		tb.Errorf("roundtrip\n%+v\n%+v", v, r)
+	}
+
+	err = Unmarshal([]byte{0x84, 0x01, 0x61, 'b', 0x80, 0x04}, &r)
+	if err != nil {
+		tb.Errorf("extra element: %v", err)
+	}
+
+	d := Decoder{Flags: FtDisallowUnknownFields}
+
+	_, err = d.DecodeValue([]byte{0x84, 0x01, 0x61, 'b', 0x80, 0x04}, 0, &r)
+	if !errors.Is(err, ErrUnknownField) {
+		tb.Errorf("extra element strict: %v", err)
+	}
+}
+
+func TestStructEmbedded(tb *testing.T) {
+	v := testEmbedded{
+		TestBase:  TestBase{ID: 1, Base: "hidden"},
+		TestInner: &TestInner{Inner: "inner", Both: 2},
+		Base:      "base",
+		Both:      3,
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	var m map[string]any
+
+	err = Unmarshal(b, &m)
+	if err != nil {
+		tb.Fatalf("unmarshal map: %v", err)
+	}
+
+	expm := map[string]any{"id": int64(1), "Inner": "inner", "Both": int64(2), "Base": "base", "both": int64(3)}
+
+	if !reflect.DeepEqual(expm, m) {
+		tb.Errorf("encoded %v, wanted %v", m, expm)
+	}
+
+	var r testEmbedded
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	v.TestBase.Base = ""
+
+	if !reflect.DeepEqual(v, r) {
+		tb.Errorf("roundtrip\n%+v %+v\n%+v %+v", v, v.TestInner, r, r.TestInner)
+	}
+
+	b, err = Marshal(testConflict{testConflictA{X: 1, A: 2}, testConflictB{X: 3, B: 4}})
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	m = nil
+
+	err = Unmarshal(b, &m)
+	if err != nil {
+		tb.Fatalf("unmarshal map: %v", err)
+	}
+
+	expm = map[string]any{"A": int64(2), "B": int64(4)}
+
+	if !reflect.DeepEqual(expm, m) {
+		tb.Errorf("conflict: encoded %v, wanted %v", m, expm)
+	}
+}
+
+func TestStructUnknownFields(tb *testing.T) {
+	var e Encoder
+	var b []byte
+
+	b = e.AppendMap(b, 3)
+	b = e.AppendString(b, "name")
+	b = e.AppendString(b, "n")
+	b = e.AppendString(b, "unknown")
+	b = e.AppendArray(b, 1)
+	b = e.AppendInt(b, 1)
+	b = e.AppendInt(b, 1)
+	b = e.AppendInt(b, 5)
+
+	var v testTagged
+
+	err := Unmarshal(b, &v)
+	if err != nil || v.Name != "n" || v.IntKey != 5 {
+		tb.Errorf("lenient: %v %+v", err, v)
+	}
+
+	d := Decoder{Flags: FtDisallowUnknownFields}
+
+	_, err = d.DecodeValue(b, 0, &v)
+	if !errors.Is(err, ErrUnknownField) {
+		tb.Errorf("strict: %v", err)
+	}
+}
+
+func TestStructDeterministic(tb *testing.T) {
+	e := Encoder{Flags: FtDeterministic}
+	var d Decoder
+
+	b, err := e.AppendValue(nil, testTagged{Name: "n", IntKey: 1, NegKey: "x", Default: true})
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	if i := d.CheckDeterministic(b, 0); i != len(b) {
+		tb.Errorf("not deterministic: %v\n%s", Error(i), Dump(b))
+	}
+}
@@ -0,0 +1,331 @@
+package cbor
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// TagRegistry maps CBOR tag numbers to Go types.
+	// It's used by Encoder.AppendValue, Decoder.DecodeValue and Dump.
+	// It also holds reflection codecs cache, as tags change the way types are encoded.
+	// It's safe for concurrent use.
+	TagRegistry struct {
+		mu     sync.Mutex
+		table  atomic.Pointer[tagTable]
+		codecs atomic.Pointer[sync.Map] // reflect.Type -> *codec
+	}
+
+	// TagSpec describes a tag.
+	//
+	// Values of the bound Type are encoded with the tag followed by their usual encoding.
+	// If Encode is set, it appends the whole item including the tag, so it could choose one of the related tags.
+	// Tag is stripped on decoding and the content is decoded as usual.
+	// Content without a tag is accepted too, but there must be at most one tag bound to a Type
+	// and it must be bound to this Type. Tags without Type are skipped.
+	// If Decode is set, it's called for the item at st including the tag.
+	// Decoding the tag into interface{} produces the value of Type.
+	// Several tags could be bound to the same Type, the last registered is used for encoding.
+	// If Type is a pointer, values of its element type are encoded and decoded through their address.
+	//
+	// Tag without Type is only named, its content is decoded into interface{} as if there were no tag.
+	// Unregistered tags are decoded into interface{} as TagValue.
+	TagSpec struct {
+		Num  uint64
+		Name string
+		Type reflect.Type
+
+		Encode func(e Encoder, b []byte, v reflect.Value) ([]byte, error)
+		Decode func(d Decoder, b []byte, st int, v reflect.Value) (int, error)
+	}
+
+	// TagValue is an unregistered tag with its content decoded into interface{}.
+	// It's encoded back as the tag followed by the content.
+	TagValue struct {
+		Num     uint64
+		Content any
+	}
+
+	tagTable struct {
+		nums  map[uint64]*TagSpec
+		types map[reflect.Type]*TagSpec
+	}
+)
+
+// Standard tags (RFC 8949 Section 3.4 and IANA registry).
+const (
+	TagDateTime      = 0
+	TagEpochTime     = 1
+	TagPosBignum     = 2
+	TagNegBignum     = 3
+	TagDecimal       = 4
+	TagBigfloat      = 5
+	TagBase64URL     = 21
+	TagBase64        = 22
+	TagBase16        = 23
+	TagEmbedded      = 24
+	TagURI           = 32
+	TagUUID          = 37
+	TagSelfDescribed = 55799
+)
+
+// DefaultTags is used by Encoder and Decoder if they have no Tags set.
+// Application private tags could be registered here.
+var DefaultTags = NewTagRegistry()
+
+var (
+	tagValueType = reflect.TypeOf(TagValue{})
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+// NewTagRegistry creates a registry with the standard tags.
+func NewTagRegistry() *TagRegistry {
+	r := &TagRegistry{}
+
+	r.table.Store(&tagTable{
+		nums:  map[uint64]*TagSpec{},
+		types: map[reflect.Type]*TagSpec{},
+	})
+	r.codecs.Store(&sync.Map{})
+
+	for _, s := range []TagSpec{
+		{Num: TagDateTime, Name: "date/time string", Type: timeType, Encode: encTime, Decode: decTime},
+		{Num: TagEpochTime, Name: "epoch date/time", Type: timeType, Encode: encTime, Decode: decTime},
+		{Num: TagPosBignum, Name: "unsigned bignum", Type: bigIntType, Encode: encBigInt, Decode: decBigInt},
+		{Num: TagNegBignum, Name: "negative bignum", Type: bigIntType, Encode: encBigInt, Decode: decBigInt},
+		{Num: TagDecimal, Name: "decimal fraction", Type: bigFloatType, Encode: encBigFloat, Decode: decBigFloat},
+		{Num: TagBigfloat, Name: "bigfloat", Type: bigFloatType, Encode: encBigFloat, Decode: decBigFloat},
+		{Num: TagBase64URL, Name: "expected base64url"},
+		{Num: TagBase64, Name: "expected base64"},
+		{Num: TagBase16, Name: "expected base16"},
+		{Num: TagEmbedded, Name: "embedded CBOR"},
+		{Num: TagURI, Name: "URI", Type: urlType, Encode: encURL, Decode: decURL},
+		{Num: TagUUID, Name: "UUID"},
+		{Num: TagDays, Name: "days since epoch", Type: timeType, Encode: encTime, Decode: decTime},
+		{Num: TagExtendedTime, Name: "extended time", Type: timeType, Encode: encTime, Decode: decTime},
+		{Num: TagDuration, Name: "duration", Type: durationType, Encode: encDuration, Decode: decDuration},
+		{Num: TagFullDate, Name: "full-date string", Type: timeType, Encode: encTime, Decode: decTime},
+		{Num: TagSelfDescribed, Name: "self-described CBOR"},
+	} {
+		err := r.Register(s)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+// Register adds or replaces the tag.
+// If the number was bound to another Type, the Type keeps its other tags
+// and the smallest of them is used for encoding, the Type is not tagged if it had no other tags.
+// Codecs cache is reset, so it's better to register tags before using the registry.
+func (r *TagRegistry) Register(s TagSpec) error {
+	if s.Type == nil && (s.Encode != nil || s.Decode != nil) {
+		return fmt.Errorf("tag %d: Encode and Decode require Type", s.Num)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.table.Load()
+
+	t := &tagTable{
+		nums:  make(map[uint64]*TagSpec, len(old.nums)+1),
+		types: make(map[reflect.Type]*TagSpec, len(old.types)+1),
+	}
+
+	for n, x := range old.nums {
+		if n != s.Num {
+			t.nums[n] = x
+		}
+	}
+
+	for typ, x := range old.types {
+		if x.Num != s.Num {
+			t.types[typ] = x
+		}
+	}
+
+	t.nums[s.Num] = &s
+
+	// rebind the type which lost its encoding tag
+	if x := old.nums[s.Num]; x != nil && x.Type != nil && x.Type != s.Type && t.types[x.Type] == nil {
+		for n, y := range t.nums {
+			if y.Type == x.Type && (t.types[x.Type] == nil || n < t.types[x.Type].Num) {
+				t.types[x.Type] = y
+			}
+		}
+	}
+
+	if s.Type != nil {
+		t.types[s.Type] = &s
+	}
+
+	r.table.Store(t)
+	r.codecs.Store(&sync.Map{})
+
+	return nil
+}
+
+// Lookup returns the tag spec by its number.
+func (r *TagRegistry) Lookup(num uint64) (TagSpec, bool) {
+	s, ok := r.table.Load().nums[num]
+	if !ok {
+		return TagSpec{}, false
+	}
+
+	return *s, true
+}
+
+// LookupType returns the tag spec bound to the type.
+func (r *TagRegistry) LookupType(t reflect.Type) (TagSpec, bool) {
+	s, ok := r.table.Load().types[t]
+	if !ok {
+		return TagSpec{}, false
+	}
+
+	return *s, true
+}
+
+func (e Encoder) tags() *TagRegistry {
+	if e.Tags != nil {
+		return e.Tags
+	}
+
+	return DefaultTags
+}
+
+func (d Decoder) tags() *TagRegistry {
+	if d.Tags != nil {
+		return d.Tags
+	}
+
+	return DefaultTags
+}
+
+func encTagged(s *TagSpec, next encFunc) encFunc {
+	if s.Encode != nil {
+		return s.Encode
+	}
+
+	return func(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+		b = e.AppendTag64(b, Labeled, s.Num)
+
+		return next(e, b, v)
+	}
+}
+
+func decTagged(t *tagTable, s *TagSpec, next decFunc) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+		tagged := false
+
+		for i := st; Tag(b[i])&TagMask == Labeled; {
+			_, num, j := d.Tag(b, i)
+
+			x := t.nums[uint64(num)]
+			if x == nil || x.Type != nil && (tagged || x.Type != s.Type) {
+				return st, fmt.Errorf("at %d: cannot decode tag %d into %v", i, uint64(num), v.Type())
+			}
+
+			tagged = tagged || x.Type != nil
+			i = j
+		}
+
+		i, ok := decNull(d, b, st, v)
+		if ok {
+			return i, nil
+		}
+
+		if s.Decode != nil {
+			return s.Decode(d, b, st, v)
+		}
+
+		return next(d, b, i, v)
+	}
+}
+
+func encTagValue(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	x := v.Interface().(TagValue)
+
+	b = e.AppendTag64(b, Labeled, x.Num)
+
+	return e.AppendValue(b, x.Content)
+}
+
+func decTagValue(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	if b[st] == byte(Simple|Null) || b[st] == byte(Simple|Undefined) {
+		return st + 1, nil
+	}
+
+	tag, num, i := d.Tag(b, st)
+	if tag != Labeled {
+		return st, typeError(b, st, v.Type())
+	}
+
+	x, i, err := d.decodeAny(b, i)
+	if err != nil {
+		return i, err
+	}
+
+	v.Set(reflect.ValueOf(TagValue{Num: uint64(num), Content: x}))
+
+	return i, nil
+}
+
+// decodeTagged decodes the tag num at st with the content at i into interface{}.
+func (d Decoder) decodeTagged(b []byte, st int, num uint64, i int) (x any, _ int, err error) {
+	r := d.tags()
+
+	s, ok := r.table.Load().nums[num]
+	switch {
+	case !ok:
+		x, i, err = d.decodeAny(b, i)
+		if err != nil {
+			return nil, i, err
+		}
+
+		return TagValue{Num: num, Content: x}, i, nil
+	case s.Type == nil:
+		return d.decodeAny(b, i)
+	}
+
+	v := reflect.New(s.Type).Elem()
+
+	i, err = r.codecFor(s.Type).dec(d, b, st, v)
+	if err != nil {
+		return nil, i, err
+	}
+
+	return v.Interface(), i, nil
+}
+
+func encURL(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	u := v.Interface().(url.URL)
+
+	b = e.AppendTag64(b, Labeled, TagURI)
+
+	return e.AppendString(b, u.String()), nil
+}
+
+func decURL(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st = skipLabels(d, b, st)
+
+	if Tag(b[st])&TagMask != String {
+		return st, typeError(b, st, v.Type())
+	}
+
+	s, i := d.appendString(nil, b, st)
+
+	u, err := url.Parse(string(s))
+	if err != nil {
+		return st, fmt.Errorf("at %d: %w", st, err)
+	}
+
+	v.Set(reflect.ValueOf(*u))
+
+	return i, nil
+}
@@ -0,0 +1,203 @@
+package cbor
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTagsURL(tb *testing.T) {
+	u, _ := url.Parse("http://www.example.com/path?q=1")
+
+	type S struct {
+		U url.URL
+		P *url.URL
+	}
+
+	b, err := Marshal(S{U: *u, P: u})
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	if d := Diag(b); d != `{"U": 32("http://www.example.com/path?q=1"), "P": 32("http://www.example.com/path?q=1")}` {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r S
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if r.U.String() != u.String() || r.P == nil || r.P.String() != u.String() {
+		tb.Errorf("decoded: %+v", r)
+	}
+
+	var x any
+
+	err = Unmarshal(b, &x)
+	if err != nil {
+		tb.Fatalf("unmarshal any: %v", err)
+	}
+
+	if xu, ok := x.(map[any]any)["U"].(url.URL); !ok || xu.String() != u.String() {
+		tb.Errorf("decoded any: %#v", x)
+	}
+
+	if s := Dump(b); !strings.Contains(s, "tag 32 (URI)") {
+		tb.Errorf("dump:\n%s", s)
+	}
+}
+
+func TestTagsUnknown(tb *testing.T) {
+	b, err := ParseDiag(`[55799(1), 1000([h'01', 2])]`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	var x any
+
+	err = Unmarshal(b, &x)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	exp := []any{int64(1), TagValue{Num: 1000, Content: []any{[]byte{1}, int64(2)}}}
+
+	if !reflect.DeepEqual(exp, x) {
+		tb.Errorf("decoded %#v, wanted %#v", x, exp)
+	}
+
+	r, err := Marshal(x)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	if d := Diag(r); d != `[1, 1000([h'01', 2])]` {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var tv TagValue
+
+	err = Unmarshal(b[5:], &tv)
+	if err != nil || tv.Num != 1000 {
+		tb.Errorf("tag value: %+v %v", tv, err)
+	}
+}
+
+func TestTagsRegister(tb *testing.T) {
+	r := NewTagRegistry()
+
+	for _, num := range []uint64{40001, 40000} {
+		err := r.Register(TagSpec{Num: num, Name: "point", Type: reflect.TypeOf(testPoint{})})
+		if err != nil {
+			tb.Fatalf("register: %v", err)
+		}
+	}
+
+	err := r.Register(TagSpec{Num: 40002, Encode: encURL})
+	if err == nil {
+		tb.Errorf("expected error for Encode without Type")
+	}
+
+	b, err := Encoder{Tags: r}.AppendValue(nil, []testPoint{{1, 2}})
+	if err != nil {
+		tb.Fatalf("encode: %v", err)
+	}
+
+	if d := Diag(b); d != "[40000([1, 2])]" {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var x any
+
+	_, err = Decoder{Tags: r}.DecodeValue(b, 0, &x)
+	if err != nil {
+		tb.Fatalf("decode: %v", err)
+	}
+
+	if !reflect.DeepEqual([]any{testPoint{1, 2}}, x) {
+		tb.Errorf("decoded: %#v", x)
+	}
+
+	_, err = Decoder{Tags: r}.DecodeValue([]byte{0xd9, 0x9c, 0x41, 0x82, 0x03, 0x04}, 0, &x)
+	if err != nil || !reflect.DeepEqual(testPoint{3, 4}, x) {
+		tb.Errorf("decoded: %#v", x)
+	}
+
+	if s := (Decoder{Tags: r}).Dump(b); !strings.Contains(s, "tag 40000 (point)") {
+		tb.Errorf("dump:\n%s", s)
+	}
+
+	// default registry is not affected
+	b, err = Marshal(testPoint{1, 2})
+	if err != nil || Diag(b) != "[1, 2]" {
+		tb.Errorf("default registry: %v %v", Diag(b), err)
+	}
+
+	_, err = Decoder{Tags: r}.DecodeValue([]byte{0xd8, 0x20, 0x01}, 0, &x)
+	if err == nil || errors.As(err, new(Error)) {
+		tb.Errorf("expected type error: %v", err)
+	}
+
+	var p testPoint
+
+	for _, diag := range []string{`40001([5, 6])`, `55799(40000([5, 6]))`, `[5, 6]`} {
+		p = testPoint{}
+
+		_, err = Decoder{Tags: r}.DecodeValue(mustDiag(tb, diag), 0, &p)
+		if err != nil || p != (testPoint{5, 6}) {
+			tb.Errorf("%v: decoded %v %v", diag, p, err)
+		}
+	}
+
+	for _, diag := range []string{`2([5, 6])`, `40002([5, 6])`, `40000(2([5, 6]))`} {
+		_, err = Decoder{Tags: r}.DecodeValue(mustDiag(tb, diag), 0, &p)
+		if err == nil {
+			tb.Errorf("%v: expected tag error: %v", diag, p)
+		}
+	}
+
+	var tm time.Time
+
+	err = Unmarshal(mustDiag(tb, `2(h'01')`), &tm)
+	if err == nil {
+		tb.Errorf("bignum into time: %v", tm)
+	}
+
+	// 40000 is taken by another type, testPoint falls back to 40001
+	err = r.Register(TagSpec{Num: 40000, Type: reflect.TypeOf(testBin{})})
+	if err != nil {
+		tb.Fatalf("register: %v", err)
+	}
+
+	b, err = Encoder{Tags: r}.AppendValue(nil, testPoint{1, 2})
+	if err != nil || Diag(b) != "40001([1, 2])" {
+		tb.Errorf("rebound: %v %v", Diag(b), err)
+	}
+
+	err = r.Register(TagSpec{Num: 40001, Type: reflect.TypeOf(testBin{})})
+	if err != nil {
+		tb.Fatalf("register: %v", err)
+	}
+
+	b, err = Encoder{Tags: r}.AppendValue(nil, testPoint{1, 2})
+	if err != nil || Diag(b) != "[1, 2]" {
+		tb.Errorf("unbound: %v %v", Diag(b), err)
+	}
+}
+
+func mustDiag(tb testing.TB, diag string) []byte {
+	tb.Helper()
+
+	b, err := ParseDiag(diag)
+	if err != nil {
+		tb.Fatalf("parse diag %v: %v", diag, err)
+	}
+
+	return b
+}
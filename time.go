@@ -0,0 +1,510 @@
+package cbor
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+type (
+	// TimeFormat selects time.Time encoding: format, precision and UTC normalization.
+	//
+	//	f := TimeString | TimeMilli | TimeUTC
+	TimeFormat int
+)
+
+// Time formats.
+const (
+	TimeEpoch    TimeFormat = iota // tag 1, integer seconds if the time is whole, float otherwise
+	TimeString                     // tag 0, RFC 3339 string
+	TimeExtended                   // tag 1001 map, RFC 9581 extended time with nanoseconds and time zone
+	TimeDays                       // tag 100, days since 1970-01-01
+	TimeDate                       // tag 1004, RFC 3339 full-date string
+
+	timeFormatMask TimeFormat = 0xf
+)
+
+// Time precisions. Time is truncated to the precision.
+// Zero precision keeps all the time digits.
+const (
+	TimeSeconds TimeFormat = (iota + 1) << 4
+	TimeMilli
+	TimeMicro
+	TimeNano
+
+	timePrecisionMask TimeFormat = 0x70
+)
+
+// TimeUTC converts time to UTC before encoding.
+// Otherwise TimeString keeps the time offset and TimeExtended keeps the time zone.
+const TimeUTC TimeFormat = 0x80
+
+// Tags supported by AppendTime and Decoder.Time.
+const (
+	TagDays         = 100
+	TagExtendedTime = 1001
+	TagDuration     = 1002
+	TagFullDate     = 1004
+)
+
+// Extended time map keys.
+const (
+	extSeconds  = 1
+	extMilli    = -3
+	extMicro    = -6
+	extNano     = -9
+	extTimeZone = -10
+)
+
+const daySeconds = 24 * 60 * 60
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// AppendTime encodes t in the format f.
+//
+// TimeEpoch with sub-second precision is encoded as a float, which is exact up to about a microsecond.
+// Use TimeExtended to keep nanoseconds.
+// TimeExtended encodes time zone as its name or as an offset in seconds for unnamed and Local zones.
+func (e Encoder) AppendTime(b []byte, t time.Time, f TimeFormat) []byte {
+	if f&TimeUTC != 0 {
+		t = t.UTC()
+	}
+
+	t = t.Truncate(f.precision())
+
+	switch f & timeFormatMask {
+	case TimeString:
+		layout := "2006-01-02T15:04:05.999999999Z07:00"
+
+		switch f & timePrecisionMask {
+		case TimeSeconds:
+			layout = time.RFC3339
+		case TimeMilli:
+			layout = "2006-01-02T15:04:05.000Z07:00"
+		case TimeMicro:
+			layout = "2006-01-02T15:04:05.000000Z07:00"
+		case TimeNano:
+			layout = "2006-01-02T15:04:05.000000000Z07:00"
+		}
+
+		b = e.AppendTag64(b, Labeled, TagDateTime)
+
+		return e.appendTimeString(b, t, layout)
+	case TimeExtended:
+		return e.appendExtendedTime(b, t)
+	case TimeDays:
+		y, m, d := t.Date()
+		days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / daySeconds
+
+		b = e.AppendTag64(b, Labeled, TagDays)
+
+		return e.AppendInt64(b, days)
+	case TimeDate:
+		b = e.AppendTag64(b, Labeled, TagFullDate)
+
+		return e.appendTimeString(b, t, "2006-01-02")
+	}
+
+	b = e.AppendTag64(b, Labeled, TagEpochTime)
+
+	if t.Nanosecond() == 0 {
+		return e.AppendInt64(b, t.Unix())
+	}
+
+	return e.AppendFloat(b, float64(t.Unix())+float64(t.Nanosecond())/1e9)
+}
+
+// AppendDuration encodes d as tag 1002 map with seconds and nanoseconds keys.
+// Nanoseconds are omitted if zero and are always non-negative.
+func (e Encoder) AppendDuration(b []byte, d time.Duration) []byte {
+	sec, ns := int64(d/time.Second), int64(d%time.Second)
+	if ns < 0 {
+		sec--
+		ns += 1e9
+	}
+
+	b = e.AppendTag64(b, Labeled, TagDuration)
+	b = e.AppendMap(b, csel(ns != 0, 2, 1))
+	b = e.AppendInt(b, extSeconds)
+	b = e.AppendInt64(b, sec)
+
+	if ns != 0 {
+		b = e.AppendInt(b, extNano)
+		b = e.AppendInt64(b, ns)
+	}
+
+	return b
+}
+
+func (e Encoder) appendTimeString(b []byte, t time.Time, layout string) []byte {
+	b = e.AppendTag(b, String, 0)
+	st := len(b)
+	b = t.AppendFormat(b, layout)
+
+	return e.InsertLen(b, String, st, 0, len(b)-st)
+}
+
+func (e Encoder) appendExtendedTime(b []byte, t time.Time) []byte {
+	key, frac := extNano, int64(t.Nanosecond())
+
+	switch {
+	case frac == 0:
+		key = 0
+	case frac%1e6 == 0:
+		key, frac = extMilli, frac/1e6
+	case frac%1e3 == 0:
+		key, frac = extMicro, frac/1e3
+	}
+
+	var zone string
+	var off int
+
+	loc := t.Location()
+	if loc != time.UTC {
+		_, off = t.Zone()
+
+		if loc != time.Local {
+			zone = loc.String()
+		}
+	}
+
+	l := 1 + csel(key != 0, 1, 0) + csel(loc != time.UTC, 1, 0)
+
+	b = e.AppendTag64(b, Labeled, TagExtendedTime)
+	b = e.AppendMap(b, l)
+	b = e.AppendInt(b, extSeconds)
+	b = e.AppendInt64(b, t.Unix())
+
+	if key != 0 {
+		b = e.AppendInt(b, key)
+		b = e.AppendInt64(b, frac)
+	}
+
+	if loc != time.UTC {
+		b = e.AppendInt(b, extTimeZone)
+
+		if zone != "" {
+			b = e.AppendString(b, zone)
+		} else {
+			b = e.AppendInt(b, off)
+		}
+	}
+
+	return b
+}
+
+func (f TimeFormat) precision() time.Duration {
+	switch f & timePrecisionMask {
+	case TimeSeconds:
+		return time.Second
+	case TimeMilli:
+		return time.Millisecond
+	case TimeMicro:
+		return time.Microsecond
+	}
+
+	return 0
+}
+
+// Time decodes time at st.
+// Tags 0, 1, 100, 1001 and 1004 are supported.
+// Untagged numbers are decoded as epoch seconds, strings as RFC 3339 and maps as extended time.
+// Epoch times and dates are returned in UTC.
+// Extended times are returned in their numeric offset zone,
+// or in the named zone if FtLoadTimeZone is set, and in UTC otherwise.
+// Loading a zone does file system I/O for every value,
+// so it's not enabled by default as zone names come from untrusted input.
+// Content which is not a valid time is reported as ErrInvalid.
+func (d Decoder) Time(b []byte, st int) (t time.Time, i int) {
+	tag, num, i := d.Tag(b, st)
+	if i < 0 {
+		return t, i
+	}
+
+	tagged := tag == Labeled
+	if !tagged {
+		i = st
+	} else if num < 0 {
+		return t, newError(ErrInvalid, st)
+	}
+
+	raw := d.TagRaw(b, i)
+	tag = raw & TagMask
+
+	switch {
+	case (!tagged || num == TagDateTime) && tag == String:
+		s, i := d.text(b, i)
+		if i < 0 {
+			return t, i
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return t, newError(ErrInvalid, st)
+		}
+
+		return t, i
+	case tagged && num == TagFullDate && tag == String:
+		s, i := d.text(b, i)
+		if i < 0 {
+			return t, i
+		}
+
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return t, newError(ErrInvalid, st)
+		}
+
+		return t, i
+	case tagged && num == TagDays && IsInt(raw):
+		days, i := d.Int64(b, i)
+		if i < 0 {
+			return t, i
+		}
+
+		if days > math.MaxInt64/daySeconds || days < math.MinInt64/daySeconds {
+			return t, newError(ErrOverflow, st)
+		}
+
+		return time.Unix(days*daySeconds, 0).UTC(), i
+	case (!tagged || num == TagEpochTime) && (IsInt(raw) || d.isFloat(raw)):
+		sec, ns, i := d.seconds(b, i)
+		if i < 0 {
+			return t, i
+		}
+
+		return time.Unix(sec, ns).UTC(), i
+	case (!tagged || num == TagExtendedTime) && tag == Map:
+		sec, ns, loc, i := d.extended(b, i)
+		if i < 0 {
+			return t, i
+		}
+
+		return time.Unix(sec, ns).In(loc), i
+	}
+
+	return t, newError(ErrInvalid, st)
+}
+
+// Duration decodes tag 1002 duration at st.
+// Untagged maps are decoded the same way and untagged numbers are decoded as seconds.
+func (d Decoder) Duration(b []byte, st int) (v time.Duration, i int) {
+	tag, num, i := d.Tag(b, st)
+	if i < 0 {
+		return 0, i
+	}
+
+	if tag != Labeled {
+		i = st
+	} else if num != TagDuration {
+		return 0, newError(ErrInvalid, st)
+	}
+
+	raw := d.TagRaw(b, i)
+
+	var sec, ns int64
+
+	switch {
+	case raw&TagMask == Map:
+		sec, ns, _, i = d.extended(b, i)
+	case tag != Labeled && (IsInt(raw) || d.isFloat(raw)):
+		sec, ns, i = d.seconds(b, i)
+	default:
+		return 0, newError(ErrInvalid, st)
+	}
+
+	if i < 0 {
+		return 0, i
+	}
+
+	if sec < 0 && ns > 0 {
+		sec, ns = sec+1, ns-1e9
+	}
+
+	if sec > math.MaxInt64/int64(time.Second) || sec < math.MinInt64/int64(time.Second) {
+		return 0, newError(ErrOverflow, st)
+	}
+
+	v = time.Duration(sec) * time.Second
+
+	if ns > 0 && v > math.MaxInt64-time.Duration(ns) || ns < 0 && v < math.MinInt64-time.Duration(ns) {
+		return 0, newError(ErrOverflow, st)
+	}
+
+	return v + time.Duration(ns), i
+}
+
+// extended decodes RFC 9581 extended time or duration map.
+// Unsupported keys are skipped.
+func (d Decoder) extended(b []byte, st int) (sec, ns int64, loc *time.Location, i int) {
+	_, l, i := d.Tag(b, st)
+	if i < 0 {
+		return
+	}
+
+	loc = time.UTC
+	base := false
+
+	for el := 0; l == -1 && !d.Break(b, &i) || el < int(l); el++ {
+		if !IsInt(d.TagRaw(b, i)) {
+			i = d.Skip(b, i)
+			i = d.Skip(b, i)
+
+			continue
+		}
+
+		var key int64
+
+		key, i = d.Int64(b, i)
+		if i < 0 {
+			return
+		}
+
+		vst := i
+		raw := d.TagRaw(b, vst)
+
+		switch key {
+		case extSeconds:
+			if !IsInt(raw) && !d.isFloat(raw) {
+				return 0, 0, nil, newError(ErrInvalid, vst)
+			}
+
+			var x int64
+
+			sec, x, i = d.seconds(b, i)
+			ns += x
+			base = true
+		case extMilli, extMicro, extNano:
+			var x int64
+
+			x, i = d.Int64(b, i)
+
+			lim := int64(1)
+			for k := key; k < 0; k++ {
+				lim *= 10
+			}
+
+			if raw&TagMask != Int || i >= 0 && x >= lim {
+				return 0, 0, nil, newError(ErrInvalid, vst)
+			}
+
+			ns += x * (1e9 / lim)
+		case extTimeZone:
+			switch {
+			case raw&TagMask == String:
+				var name string
+
+				name, i = d.text(b, i)
+				if i < 0 || !d.Flags.Is(FtLoadTimeZone) {
+					break
+				}
+
+				var err error
+
+				loc, err = time.LoadLocation(name)
+				if err != nil {
+					return 0, 0, nil, newError(ErrInvalid, vst)
+				}
+			case IsInt(raw):
+				var off int64
+
+				off, i = d.Int64(b, i)
+				if i >= 0 && (off <= -daySeconds || off >= daySeconds) {
+					return 0, 0, nil, newError(ErrInvalid, vst)
+				}
+
+				loc = time.FixedZone("", int(off))
+			default:
+				return 0, 0, nil, newError(ErrInvalid, vst)
+			}
+		default:
+			i = d.Skip(b, i)
+		}
+
+		if i < 0 {
+			return
+		}
+	}
+
+	if i >= 0 && !base {
+		return 0, 0, nil, newError(ErrInvalid, st)
+	}
+
+	return sec, ns, loc, i
+}
+
+// seconds decodes integer or float number of seconds.
+func (d Decoder) seconds(b []byte, st int) (sec, ns int64, i int) {
+	if !d.isFloat(d.TagRaw(b, st)) {
+		sec, i = d.Int64(b, st)
+		return sec, 0, i
+	}
+
+	f, i := d.Float(b, st)
+	if i < 0 {
+		return 0, 0, i
+	}
+
+	if !(f > math.MinInt64 && f < math.MaxInt64) {
+		return 0, 0, newError(ErrOverflow, st)
+	}
+
+	fl := math.Floor(f)
+	sec, ns = int64(fl), int64(math.Round((f-fl)*1e9))
+
+	if ns == 1e9 {
+		sec, ns = sec+1, 0
+	}
+
+	return sec, ns, i
+}
+
+// text decodes definite or indefinite length string.
+func (d Decoder) text(b []byte, st int) (string, int) {
+	s, i := d.content(b, st)
+
+	return string(s), i
+}
+
+// content returns definite or indefinite length string or bytes content.
+// Definite length content is not copied.
+func (d Decoder) content(b []byte, st int) ([]byte, int) {
+	if d.TagRaw(b, st)&SubMask == LenBreak {
+		return d.appendString(nil, b, st)
+	}
+
+	return d.Bytes(b, st)
+}
+
+func encTime(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendTime(b, v.Interface().(time.Time), e.Time), nil
+}
+
+func decTime(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	t, i := d.Time(b, st)
+	if i < 0 {
+		return st, Error(i)
+	}
+
+	v.Set(reflect.ValueOf(t))
+
+	return i, nil
+}
+
+func encDuration(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendDuration(b, time.Duration(v.Int())), nil
+}
+
+func decDuration(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	x, i := d.Duration(b, st)
+	if i < 0 {
+		return st, Error(i)
+	}
+
+	v.SetInt(int64(x))
+
+	return i, nil
+}
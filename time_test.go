@@ -0,0 +1,234 @@
+package cbor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeEncode(tb *testing.T) {
+	zone := time.FixedZone("", 3*3600)
+	t := time.Date(2013, 3, 21, 23, 4, 0, 123456789, zone)
+	whole := time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)
+
+	var e Encoder
+
+	for j, tc := range []struct {
+		T    time.Time
+		F    TimeFormat
+		Diag string
+	}{
+		{whole, TimeEpoch, `1(1363896240)`},
+		{t, TimeEpoch | TimeMilli, `1(1363896240.123)`},
+		{t, TimeEpoch | TimeSeconds, `1(1363896240)`},
+		{whole, TimeString, `0("2013-03-21T20:04:00Z")`},
+		{t, TimeString, `0("2013-03-21T23:04:00.123456789+03:00")`},
+		{t, TimeString | TimeMilli | TimeUTC, `0("2013-03-21T20:04:00.123Z")`},
+		{t, TimeString | TimeSeconds, `0("2013-03-21T23:04:00+03:00")`},
+		{t, TimeExtended, `1001({1: 1363896240, -9: 123456789, -10: 10800})`},
+		{t, TimeExtended | TimeMicro | TimeUTC, `1001({1: 1363896240, -6: 123456})`},
+		{t, TimeExtended | TimeSeconds, `1001({1: 1363896240, -10: 10800})`},
+		{t, TimeDays, `100(15785)`},
+		{t, TimeDate, `1004("2013-03-21")`},
+	} {
+		b := e.AppendTime(nil, tc.T, tc.F)
+
+		if d := Diag(b); d != tc.Diag {
+			tb.Errorf("%d: encoded %v, wanted %v", j, d, tc.Diag)
+			continue
+		}
+
+		r, i := MakeSafeDecoder().Time(b, 0)
+		if i != len(b) {
+			tb.Errorf("%d: decode: %v", j, Error(i))
+			continue
+		}
+
+		exp := tc.T.Truncate(tc.F.precision())
+
+		switch tc.F & timeFormatMask {
+		case TimeDays, TimeDate:
+			exp = time.Date(2013, 3, 21, 0, 0, 0, 0, time.UTC)
+		case TimeEpoch:
+			r = r.Round(time.Microsecond)
+		}
+
+		if !r.Equal(exp) {
+			tb.Errorf("%d: decoded %v, wanted %v", j, r, exp)
+		}
+
+		f := tc.F & timeFormatMask
+		if f != TimeString && f != TimeExtended || tc.F&TimeUTC != 0 {
+			continue
+		}
+
+		_, off := r.Zone()
+		_, expOff := tc.T.Zone()
+
+		if off != expOff {
+			tb.Errorf("%d: decoded zone offset %v, wanted %v", j, off, expOff)
+		}
+	}
+}
+
+func TestTimeDecode(tb *testing.T) {
+	d := MakeSafeDecoder()
+
+	for j, tc := range []struct {
+		Diag string
+		T    time.Time
+	}{
+		{`1363896240`, time.Unix(1363896240, 0)},
+		{`-1.5`, time.Unix(-2, 5e8)},
+		{`"2013-03-21T20:04:00Z"`, time.Unix(1363896240, 0)},
+		{`0((_ "2013-03-21T20:04:00", ".5Z"))`, time.Unix(1363896240, 5e8)},
+		{`1001({1: 1363896240, -3: 500, 100: "unknown"})`, time.Unix(1363896240, 5e8)},
+		{`{1: 1.5, -6: 1}`, time.Unix(1, 500001000)},
+		{`100(-1)`, time.Unix(-daySeconds, 0)},
+	} {
+		b, err := ParseDiag(tc.Diag)
+		if err != nil {
+			tb.Fatalf("%d: parse diag: %v", j, err)
+		}
+
+		r, i := d.Time(b, 0)
+		if i != len(b) || !r.Equal(tc.T) {
+			tb.Errorf("%d: %v: decoded %v %v, wanted %v", j, tc.Diag, r, Error(i), tc.T)
+		}
+	}
+
+	for j, diag := range []string{
+		`0(1)`,
+		`1("2013-03-21T20:04:00Z")`,
+		`0("yesterday")`,
+		`1004("2013-03-21T20:04:00Z")`,
+		`2(1)`,
+		`1001({-3: 500})`,
+		`1001({1: 0, -3: 1000})`,
+		`1001({1: 0, -10: true})`,
+		`1(NaN)`,
+		`1(18446744073709551615)`,
+		`true`,
+	} {
+		b, err := ParseDiag(diag)
+		if err != nil {
+			tb.Fatalf("%d: parse diag: %v", j, err)
+		}
+
+		r, i := d.Time(b, 0)
+		if i >= 0 {
+			tb.Errorf("%d: %v: expected error, got %v", j, diag, r)
+		}
+	}
+
+	b, _ := ParseDiag(`1001({1: 1363896240, -10: "Europe/Berlin"})`)
+
+	r, i := d.Time(b, 0)
+	if i != len(b) || r.Location() != time.UTC || r.Unix() != 1363896240 {
+		tb.Errorf("zone name: %v %v", r, Error(i))
+	}
+
+	if _, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		d.Flags |= FtLoadTimeZone
+
+		r, i = d.Time(b, 0)
+		if i != len(b) || r.Location().String() != "Europe/Berlin" || r.Unix() != 1363896240 {
+			tb.Errorf("zone name loaded: %v %v", r, Error(i))
+		}
+
+		b, _ = ParseDiag(`1001({1: 0, -10: "No/Such_Zone"})`)
+
+		if _, i = d.Time(b, 0); i >= 0 {
+			tb.Errorf("unknown zone: %v", i)
+		}
+
+		d.Flags &^= FtLoadTimeZone
+	}
+
+	b, _ = ParseDiag(`0("2013-03-21T20:04:00Z")`)
+
+	for l := 0; l < len(b); l++ {
+		if _, i := d.Time(b[:l], 0); i >= 0 {
+			tb.Errorf("%d: expected error on truncated data", l)
+		}
+	}
+}
+
+func TestTimeDuration(tb *testing.T) {
+	var e Encoder
+
+	for _, tc := range []struct {
+		D    time.Duration
+		Diag string
+	}{
+		{0, `1002({1: 0})`},
+		{90 * time.Second, `1002({1: 90})`},
+		{1500 * time.Millisecond, `1002({1: 1, -9: 500000000})`},
+		{-1500 * time.Millisecond, `1002({1: -2, -9: 500000000})`},
+		{math.MaxInt64, `1002({1: 9223372036, -9: 854775807})`},
+	} {
+		b := e.AppendDuration(nil, tc.D)
+
+		if d := Diag(b); d != tc.Diag {
+			tb.Errorf("%v: encoded %v, wanted %v", tc.D, d, tc.Diag)
+		}
+
+		r, i := Decoder{}.Duration(b, 0)
+		if i != len(b) || r != tc.D {
+			tb.Errorf("%v: decoded %v %v", tc.D, r, Error(i))
+		}
+	}
+
+	r, i := Decoder{}.Duration([]byte{0xf9, 0x3e, 0x00}, 0)
+	if i != 3 || r != 1500*time.Millisecond {
+		tb.Errorf("decoded float: %v %v", r, Error(i))
+	}
+}
+
+func TestTimeValue(tb *testing.T) {
+	type S struct {
+		T time.Time
+		P *time.Time
+		D time.Duration
+	}
+
+	t := time.Date(2013, 3, 21, 20, 4, 0, 500e6, time.UTC)
+	v := S{T: t, P: &t, D: time.Minute}
+
+	b, err := Encoder{Time: TimeString}.AppendValue(nil, v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	if d := Diag(b); d != `{"T": 0("2013-03-21T20:04:00.5Z"), "P": 0("2013-03-21T20:04:00.5Z"), "D": 1002({1: 60})}` {
+		tb.Errorf("encoded: %v", d)
+	}
+
+	var r S
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if !r.T.Equal(t) || r.P == nil || !r.P.Equal(t) || r.D != time.Minute {
+		tb.Errorf("decoded: %+v", r)
+	}
+
+	var x any
+
+	err = Unmarshal(b, &x)
+	if err != nil {
+		tb.Fatalf("unmarshal any: %v", err)
+	}
+
+	m := x.(map[any]any)
+	if xt, ok := m["T"].(time.Time); !ok || !xt.Equal(t) || m["D"] != time.Minute {
+		tb.Errorf("decoded any: %#v", x)
+	}
+
+	b, err = Marshal(t)
+	if err != nil || Diag(b) != `1(1363896240.5)` {
+		tb.Errorf("default format: %v %v", Diag(b), err)
+	}
+}
@@ -0,0 +1,268 @@
+package cbor
+
+import (
+	"errors"
+	"io"
+)
+
+type (
+	// Token is a data item head or a string part returned by Reader.Next.
+	// Raw and Data refer to the Reader buffer and are valid until the next Reader call.
+	Token struct {
+		Tag Tag
+
+		// Sub is Int or Neg argument, string or container length (-1 for indefinite),
+		// Labeled tag number, or Simple value (Break for the end of indefinite container).
+		Sub int64
+
+		Raw  []byte // encoded head including float value
+		Data []byte // string part
+
+		// More is set if Data is not the last part of the string.
+		// The following parts are returned by the next calls as tokens with the same Tag and Sub.
+		More bool
+
+		Depth  int
+		Offset int64 // stream offset of Raw or Data for the following parts
+	}
+)
+
+// Next returns the next token: item head or a part of the string.
+// Strings are returned in parts which fit the buffer, so huge values are processed with constant memory.
+// Arrays and Maps are returned as heads followed by their elements at Depth+1.
+// Indefinite length containers and strings end with Break token.
+// Labeled is followed by its content at Depth+1.
+// It returns io.EOF if the stream ends between top-level items.
+func (r *Reader) Next() (tok Token, err error) {
+	if r.strLeft != 0 {
+		return r.nextPart()
+	}
+
+	depth := len(r.stack)
+
+	if depth == 0 {
+		r.items = 0
+	}
+
+	err = r.ensure(1, depth == 0)
+	if err != nil {
+		return tok, err
+	}
+
+	st := r.i
+
+	err = r.ensure(headSize(r.b[st]), false)
+	if err != nil {
+		return tok, err
+	}
+
+	tag, sub, i := readTag(r.b, st)
+	if i < 0 {
+		return tok, Error(r.newError(-i, st))
+	}
+
+	if r.b[st] == byte(Simple|Break) {
+		if depth == 0 || r.stack[depth-1].n >= 0 {
+			return tok, Error(r.newError(ErrMalformed, st))
+		}
+
+		if f := r.stack[depth-1]; f.tag == Map && f.el%2 == 1 {
+			return tok, Error(r.newError(ErrMalformed, st))
+		}
+
+		r.i = i
+		r.stack = r.stack[:depth-1]
+		r.itemDone()
+
+		return Token{Tag: Simple, Sub: Break, Raw: r.b[st:i], Depth: depth - 1, Offset: r.boff + int64(st)}, nil
+	}
+
+	if e := r.Limits.check(tag, sub, st, depth, &r.items); e < 0 {
+		return tok, Error(r.newError(Error(e).Code(), st))
+	}
+
+	if sub < 0 && tag >= Bytes && tag <= Map && r.b[st]&SubMask != LenBreak {
+		return tok, Error(r.newError(ErrOverflow, st))
+	}
+
+	if r.b[st]&SubMask == LenBreak && (tag == Int || tag == Neg || tag == Labeled) {
+		return tok, Error(r.newError(ErrMalformed, st))
+	}
+
+	if depth != 0 {
+		if f := r.stack[depth-1]; (f.tag == Bytes || f.tag == String) && (tag != f.tag || sub < 0) {
+			return tok, Error(r.newError(ErrMalformed, st))
+		}
+
+		if f := r.stack[depth-1]; f.n < 0 && (f.tag == Array || f.tag == Map) {
+			if e := r.Limits.checkLen(f.tag, csel(f.tag == Map, f.el/2, f.el), st); e < 0 {
+				return tok, Error(r.newError(Error(e).Code(), st))
+			}
+		}
+	}
+
+	if tag == Simple {
+		switch {
+		case sub >= Float8 && sub <= Float64:
+			err = r.ensure(i-st+1<<(sub-Float8), false)
+			if err != nil {
+				return tok, err
+			}
+
+			i += 1 << (sub - Float8)
+		case sub > Float64:
+			return tok, Error(r.newError(ErrMalformed, st))
+		}
+	}
+
+	tok = Token{Tag: tag, Sub: sub, Raw: r.b[st:i], Depth: depth, Offset: r.boff + int64(st)}
+	r.i = i
+
+	switch {
+	case (tag == Bytes || tag == String) && sub > 0:
+		r.strLeft = sub
+		r.strTok = tok
+
+		return r.part(tok)
+	case tag == Array && sub != 0:
+		r.stack = append(r.stack, readerFrame{tag: tag, n: sub})
+	case tag == Map && sub != 0:
+		r.stack = append(r.stack, readerFrame{tag: tag, n: csel(sub < 0, sub, 2*sub)})
+	case (tag == Bytes || tag == String) && sub < 0:
+		r.stack = append(r.stack, readerFrame{tag: tag, n: -1})
+	case tag == Labeled:
+		r.stack = append(r.stack, readerFrame{tag: tag, n: 1})
+	default:
+		r.itemDone()
+	}
+
+	return tok, nil
+}
+
+// SkipValue skips the next data item reading it token by token without buffering it entirely.
+// If the current indefinite length container ends instead, its Break is consumed.
+func (r *Reader) SkipValue() error {
+	depth := len(r.stack)
+
+	for {
+		_, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		if len(r.stack) <= depth && r.strLeft == 0 {
+			return nil
+		}
+	}
+}
+
+// Peek returns the first byte of the next item without consuming it.
+// It's Simple|Break at the end of indefinite length container.
+func (r *Reader) Peek() (Tag, error) {
+	if r.strLeft != 0 {
+		return 0, Error(r.newError(ErrMalformed, r.i))
+	}
+
+	err := r.ensure(1, len(r.stack) == 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return Tag(r.b[r.i]), nil
+}
+
+// Depth returns the number of containers entered by Next.
+func (r *Reader) Depth() int {
+	return len(r.stack)
+}
+
+func (r *Reader) nextPart() (tok Token, err error) {
+	err = r.ensure(1, false)
+	if err != nil {
+		return tok, err
+	}
+
+	tok = r.strTok
+	tok.Raw = nil
+	tok.Offset = r.boff + int64(r.i)
+
+	return r.part(tok)
+}
+
+func (r *Reader) part(tok Token) (Token, error) {
+	if r.i == len(r.b) {
+		err := r.ensure(1, false)
+		if err != nil {
+			return tok, err
+		}
+	}
+
+	n := len(r.b) - r.i
+	if int64(n) > r.strLeft {
+		n = int(r.strLeft)
+	}
+
+	tok.Data = r.b[r.i : r.i+n]
+	r.i += n
+	r.strLeft -= int64(n)
+	tok.More = r.strLeft != 0
+
+	if !tok.More {
+		r.itemDone()
+	}
+
+	return tok, nil
+}
+
+// itemDone accounts finished item in the enclosing containers.
+func (r *Reader) itemDone() {
+	for len(r.stack) != 0 {
+		f := &r.stack[len(r.stack)-1]
+
+		if f.n < 0 {
+			f.el++
+			return
+		}
+
+		f.n--
+
+		if f.n != 0 {
+			return
+		}
+
+		r.stack = r.stack[:len(r.stack)-1]
+	}
+}
+
+// ensure reads until there are at least n unread bytes in the buffer.
+// It returns io.EOF if no bytes left and eof is allowed.
+func (r *Reader) ensure(n int, eof bool) error {
+	for len(r.b)-r.i < n {
+		empty := r.i == len(r.b)
+
+		err := r.more()
+		if errors.Is(err, io.EOF) && eof && empty {
+			return io.EOF
+		}
+		if errors.Is(err, io.EOF) {
+			return Error(r.newError(ErrUnexpectedEOF, len(r.b)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// headSize returns the size of the item head starting with the byte c excluding float value.
+func headSize(c byte) int {
+	switch sub := c & SubMask; {
+	case Tag(c)&TagMask == Simple:
+		return 1
+	case sub >= Len1 && sub <= Len8:
+		return 1 + 1<<(sub-Len1)
+	}
+
+	return 1
+}
@@ -0,0 +1,299 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReaderNext(tb *testing.T) {
+	long := strings.Repeat("x", 3000)
+
+	b, err := ParseDiag(`[_ {"a": 1, "b": [1.5, -2]}, 1(h'0102'), (_ "ab", ""), "` + long + `", [], {_ }], 7`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	r := NewReader(bytes.NewReader(b))
+
+	type tok struct {
+		Tag   Tag
+		Sub   int64
+		Data  string
+		Depth int
+	}
+
+	var toks []tok
+	var str []byte
+
+	for {
+		t, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			tb.Fatalf("next: %v", err)
+		}
+
+		if t.Sub == 3000 {
+			str = append(str, t.Data...)
+
+			if t.More {
+				continue
+			}
+
+			t.Data = str
+		}
+
+		toks = append(toks, tok{t.Tag, t.Sub, string(t.Data), t.Depth})
+	}
+
+	exp := []tok{
+		{Array, -1, "", 0},
+		{Map, 2, "", 1},
+		{String, 1, "a", 2},
+		{Int, 1, "", 2},
+		{String, 1, "b", 2},
+		{Array, 2, "", 2},
+		{Simple, Float16, "", 3},
+		{Neg, 1, "", 3},
+		{Labeled, 1, "", 1},
+		{Bytes, 2, "\x01\x02", 2},
+		{String, -1, "", 1},
+		{String, 2, "ab", 2},
+		{String, 0, "", 2},
+		{Simple, Break, "", 1},
+		{String, 3000, long, 1},
+		{Array, 0, "", 1},
+		{Map, -1, "", 1},
+		{Simple, Break, "", 1},
+		{Simple, Break, "", 0},
+		{Int, 7, "", 0},
+	}
+
+	if len(toks) != len(exp) {
+		tb.Fatalf("tokens: %v, wanted %v", len(toks), len(exp))
+	}
+
+	for j := range exp {
+		if toks[j] != exp[j] {
+			tb.Errorf("token %d: %+v, wanted %+v", j, toks[j], exp[j])
+		}
+	}
+
+	if cap(r.b) > 2048 {
+		tb.Errorf("buffer grown: %v", cap(r.b))
+	}
+}
+
+func TestReaderSkipValue(tb *testing.T) {
+	b, err := ParseDiag(`{"skip": [1, [2, 3], {_ 4: "` + strings.Repeat("y", 5000) + `"}], "keep": [_ 5, 6]}, 8`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	r := NewReader(bytes.NewReader(b))
+
+	next := func() Token {
+		tb.Helper()
+
+		t, err := r.Next()
+		if err != nil {
+			tb.Fatalf("next: %v", err)
+		}
+
+		return t
+	}
+
+	if t := next(); t.Tag != Map {
+		tb.Fatalf("map expected: %+v", t)
+	}
+
+	if t := next(); string(t.Data) != "skip" {
+		tb.Fatalf("skip key expected: %+v", t)
+	}
+
+	err = r.SkipValue()
+	if err != nil {
+		tb.Fatalf("skip: %v", err)
+	}
+
+	if t := next(); string(t.Data) != "keep" {
+		tb.Fatalf("keep key expected: %+v", t)
+	}
+
+	if t := next(); t.Tag != Array || t.Sub != -1 {
+		tb.Fatalf("array expected: %+v", t)
+	}
+
+	var sum int
+
+	for {
+		tag, err := r.Peek()
+		if err != nil {
+			tb.Fatalf("peek: %v", err)
+		}
+
+		if tag == Simple|Break {
+			next()
+			break
+		}
+
+		data, err := r.Decode()
+		if err != nil {
+			tb.Fatalf("decode: %v", err)
+		}
+
+		sum += int(data[0])
+	}
+
+	if sum != 11 || r.Depth() != 0 {
+		tb.Errorf("sum %v depth %v", sum, r.Depth())
+	}
+
+	if cap(r.b) > 2048 {
+		tb.Errorf("buffer grown: %v", cap(r.b))
+	}
+
+	data, err := r.Decode()
+	if err != nil || !bytes.Equal(data, []byte{8}) {
+		tb.Errorf("last: %x %v", data, err)
+	}
+}
+
+func TestReaderNextErrors(tb *testing.T) {
+	for _, tc := range []struct {
+		Data []byte
+		Code int
+	}{
+		{[]byte{0x82, 0x01}, ErrUnexpectedEOF},
+		{[]byte{0x19, 0x01}, ErrUnexpectedEOF},
+		{[]byte{0x62, 0x01}, ErrUnexpectedEOF},
+		{[]byte{0xff}, ErrMalformed},
+		{[]byte{0x81, 0xff}, ErrMalformed},
+		{[]byte{0x5f, 0x61, 0x61}, ErrMalformed},
+		{[]byte{0x7f, 0x7f}, ErrMalformed},
+		{[]byte{0x1c}, ErrMalformed},
+		{[]byte{0xfc}, ErrMalformed},
+		{[]byte{0xbf, 0x01, 0xff}, ErrMalformed},
+		{[]byte{0xbf, 0x01, 0x02, 0x03, 0xff}, ErrMalformed},
+		{[]byte{0x1f}, ErrMalformed},
+		{[]byte{0x3f}, ErrMalformed},
+		{[]byte{0xdf, 0x01}, ErrMalformed},
+	} {
+		r := NewReader(bytes.NewReader(tc.Data))
+
+		var err error
+
+		for err == nil {
+			_, err = r.Next()
+		}
+
+		var e Error
+		if !errors.As(err, &e) || e.Code() != tc.Code {
+			tb.Errorf("% x: %v, wanted code %v", tc.Data, err, tc.Code)
+		}
+	}
+
+	// complete but malformed data is rejected by Decode too
+	for _, data := range []string{"bf01ff", "bf010203ff", "1f", "3f", "df01"} {
+		b, _ := hex.DecodeString(data)
+
+		_, err := NewReader(bytes.NewReader(b)).Decode()
+
+		var e Error
+		if !errors.As(err, &e) || e.Code() != ErrMalformed {
+			tb.Errorf("%v: decode: %v", data, err)
+		}
+	}
+
+	r := NewReaderSize(bytes.NewReader([]byte{0x82, 0x78, 0x40}), 8)
+
+	_, _ = r.Next()
+	_, _ = r.Next() // string part is pending
+
+	r.Reset(bytes.NewReader([]byte{0x01}), nil)
+
+	tok, err := r.Next()
+	if err != nil || tok.Tag != Int || tok.Sub != 1 || tok.Depth != 0 || tok.More {
+		tb.Errorf("after reset: %+v %v", tok, err)
+	}
+
+	r = NewReader(bytes.NewReader([]byte{0x9f, 0x01, 0x02, 0x03}))
+	r.Limits.MaxArrayLen = 2
+
+	for err == nil {
+		_, err = r.Next()
+	}
+
+	var e Error
+	if !errors.As(err, &e) || e.Code() != ErrLimit || e.Index() != 3 {
+		tb.Errorf("limit: %v", err)
+	}
+}
+
+func TestReaderResync(tb *testing.T) {
+	for _, tc := range []struct {
+		Magic   bool
+		Max     int
+		Data    string
+		Items   []string
+		Skipped []int64
+	}{
+		{false, 0, "01 ff 02 1c 1c 03", []string{"01", "02", "03"}, []int64{1, 2}},
+		{false, 0, "01 ff ff", []string{"01"}, []int64{2}},
+		{false, 0, "01 82 01", []string{"01", "01"}, []int64{1}},
+		{true, 0, "01 ff 02 d9 d9 f7 03 d9 d9 f7 fc 04 d9 d9 f7 05", []string{"01", "d9d9f703", "d9d9f705"}, []int64{2, 5}},
+		{true, 0, "ff d9 d9 00 00 d9 d9", nil, []int64{7}},
+		{false, 16, "01 7a ff ff ff ff 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f 10 11 12", []string{"01", "02", "03", "04", "05", "06", "07", "08", "09", "0a", "0b", "0c", "0d", "0e", "0f", "10", "11", "12"}, []int64{5}},
+		{false, 8, "01 9f 02 03 04 05 06 07 08 09 0a 0b", []string{"01", "02", "03", "04", "05", "06", "07", "08", "09", "0a", "0b"}, []int64{1}},
+	} {
+		b, _ := hex.DecodeString(strings.ReplaceAll(tc.Data, " ", ""))
+
+		var skipped []int64
+
+		r := NewReader(iotest.OneByteReader(bytes.NewReader(b)))
+		r.Resync = true
+		r.ResyncMagic = tc.Magic
+		r.MaxBuffer = tc.Max
+		r.OnResync = func(n int64, err error) {
+			if !errors.As(err, new(Error)) {
+				tb.Errorf("%v: resync error: %v", tc.Data, err)
+			}
+
+			skipped = append(skipped, n)
+		}
+
+		var items []string
+
+		for {
+			data, err := r.Decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				tb.Fatalf("%v: decode: %v", tc.Data, err)
+			}
+
+			items = append(items, hex.EncodeToString(data))
+		}
+
+		if !reflect.DeepEqual(tc.Items, items) || !reflect.DeepEqual(tc.Skipped, skipped) {
+			tb.Errorf("%v: items %v skipped %v, wanted %v %v", tc.Data, items, skipped, tc.Items, tc.Skipped)
+		}
+	}
+
+	r := NewReader(bytes.NewReader([]byte{0x01, 0xff, 0x02}))
+
+	_, _ = r.Decode()
+
+	_, err := r.Decode()
+	if !errors.As(err, new(Error)) {
+		tb.Errorf("no resync: %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package cbor
+
+import (
+	"bytes"
+	"math"
+)
+
+// Validate checks the data item at st is well-formed according to RFC 8949 Appendix F.
+// It returns the end of the item or negative Error pointing to the offending head.
+// It never panics whatever the flags and the input are.
+//
+// Two-byte simple values below 32 are rejected unless FtFloat8Int is set,
+// in which case they are treated as Float8.
+//
+// Note that FtFloat8Int is part of FtDefault, so MakeDecoder accepts them,
+// as MakeEncoder output contains them. This is a deviation from Appendix F.
+// Use a Decoder without FtFloat8Int for strict RFC 8949 validation.
+func (d Decoder) Validate(b []byte, st int) (i int) {
+	if st < 0 {
+		return st
+	}
+
+	var items int
+
+	return d.validate(b, st, 0, &items)
+}
+
+// ValidateSequence checks b[st:] is a well-formed CBOR sequence (RFC 8742).
+// It returns len(b) or negative Error.
+func (d Decoder) ValidateSequence(b []byte, st int) (i int) {
+	for i = st; i >= 0 && i < len(b); {
+		i = d.Validate(b, i)
+	}
+
+	return i
+}
+
+func (d Decoder) validate(b []byte, st, depth int, items *int) (i int) {
+	tag, sub, i := readTag(b, st)
+	if i < 0 {
+		return newError(-i, st)
+	}
+
+	if err := d.Limits.check(tag, sub, st, depth, items); err < 0 {
+		return err
+	}
+
+	ai := b[st] & SubMask
+	indef := ai == LenBreak
+
+	switch tag {
+	case Int, Neg:
+		if indef {
+			return newError(ErrMalformed, st)
+		}
+	case Labeled:
+		if indef {
+			return newError(ErrMalformed, st)
+		}
+
+		return d.validate(b, i, depth+1, items)
+	case Bytes, String:
+		if !indef {
+			if sub < 0 || sub > int64(len(b)-i) {
+				return newError(ErrUnexpectedEOF, st)
+			}
+
+			return i + int(sub)
+		}
+
+		for {
+			if i >= len(b) {
+				return newError(ErrUnexpectedEOF, i)
+			}
+
+			if b[i] == byte(Simple|Break) {
+				return i + 1
+			}
+
+			if Tag(b[i])&TagMask != tag || b[i]&SubMask == LenBreak {
+				return newError(ErrMalformed, i)
+			}
+
+			i = d.validate(b, i, depth+1, items)
+			if i < 0 {
+				return i
+			}
+		}
+	case Array, Map:
+		if !indef && (sub < 0 || sub > int64(len(b)-i)) {
+			return newError(ErrUnexpectedEOF, st)
+		}
+
+		n := int(sub)
+		if tag == Map {
+			n *= 2
+		}
+
+		for el := 0; indef || el < n; el++ {
+			if indef && i >= len(b) {
+				return newError(ErrUnexpectedEOF, i)
+			}
+
+			if indef && b[i] == byte(Simple|Break) {
+				if tag == Map && el%2 == 1 {
+					return newError(ErrMalformed, i)
+				}
+
+				return i + 1
+			}
+
+			if indef {
+				if err := d.Limits.checkLen(tag, el/csel(tag == Map, 2, 1), st); err < 0 {
+					return err
+				}
+			}
+
+			i = d.validate(b, i, depth+1, items)
+			if i < 0 {
+				return i
+			}
+		}
+	case Simple:
+		switch {
+		case ai < Float8:
+		case ai == Float8:
+			if i >= len(b) {
+				return newError(ErrUnexpectedEOF, st)
+			}
+
+			if b[i] < 32 && !d.Flags.Is(FtFloat8Int) {
+				return newError(ErrMalformed, st)
+			}
+
+			i++
+		case ai <= Float64:
+			i += 1 << (ai - Float8)
+
+			if i > len(b) {
+				return newError(ErrUnexpectedEOF, st)
+			}
+		default: // reserved and stray break
+			return newError(ErrMalformed, st)
+		}
+	}
+
+	return i
+}
+
+// CheckDeterministic checks the data item at st is well-formed and
+// encoded according to RFC 8949 Core Deterministic Encoding:
+// shortest heads, no indefinite lengths, preferred float serialization
+// and map keys sorted bytewise-lexicographically with no duplicates.
+// It returns the end of the item or negative Error.
+// ErrNotDeterministic code points to the first offending item.
+func (d Decoder) CheckDeterministic(b []byte, st int) (i int) {
+	i = Decoder{Limits: d.Limits}.Validate(b, st)
+	if i < 0 {
+		return i
+	}
+
+	return d.deterministic(b, st)
+}
+
+func (d Decoder) deterministic(b []byte, st int) (i int) {
+	tag, sub, i := Decoder{}.Tag(b, st)
+	ai := b[st] & SubMask
+
+	if tag != Simple && !shortestArg(ai, uint64(sub)) {
+		return newError(ErrNotDeterministic, st)
+	}
+
+	switch tag {
+	case Bytes, String:
+		return i + int(sub)
+	case Array, Map:
+		n := int(sub)
+		if tag == Map {
+			n *= 2
+		}
+
+		var pk, pv int
+
+		for el := 0; el < n; el++ {
+			k := i
+
+			i = d.deterministic(b, i)
+			if i < 0 {
+				return i
+			}
+
+			if tag != Map || el%2 == 1 {
+				continue
+			}
+
+			if el != 0 && bytes.Compare(b[pk:pv], b[k:i]) >= 0 {
+				return newError(ErrNotDeterministic, k)
+			}
+
+			pk, pv = k, i
+		}
+	case Labeled:
+		return d.deterministic(b, i)
+	case Simple:
+		switch ai {
+		case Float32:
+			if _, ok := float16bits(uint32(Decoder{}.u32(b, st+1))); ok {
+				return newError(ErrNotDeterministic, st)
+			}
+		case Float64:
+			v, _ := Decoder{}.Float(b, st)
+
+			if float64(float32(v)) == v || math.IsNaN(v) {
+				return newError(ErrNotDeterministic, st)
+			}
+		}
+	}
+
+	return i
+}
+
+func shortestArg(ai byte, v uint64) bool {
+	switch ai {
+	case LenBreak:
+		return false
+	case Len1:
+		return v >= Len1
+	case Len2:
+		return v > 0xff
+	case Len4:
+		return v > 0xffff
+	case Len8:
+		return v > 0xffff_ffff
+	}
+
+	return true
+}
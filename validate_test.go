@@ -0,0 +1,221 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestValidate(tb *testing.T) {
+	var d Decoder
+
+	for _, x := range []string{
+		"00", "17", "1818", "1903e8", "1b000000e8d4a51000", "1bffffffffffffffff",
+		"20", "3863", "3bffffffffffffffff",
+		"f90000", "f93c00", "fa47c35000", "fb3ff199999999999a",
+		"f4", "f5", "f6", "f7", "f0", "f8ff",
+		"c074323031332d30332d32315432303a30343a30305a", "c11a514b67b0", "d82076687474703a2f2f7777772e6578616d706c652e636f6d",
+		"40", "4401020304", "60", "6449455446",
+		"80", "83010203", "8301820203820405", "a0", "a201020304", "a26161016162820203",
+		"5f42010243030405ff", "7f657374726561646d696e67ff",
+		"9fff", "9f018202039f0405ffff", "83019f0203ff820405",
+		"bf61610161629f0203ffff", "826161bf61626163ff", "bf6346756ef563416d7421ff",
+		"7fff", "5f40ff",
+	} {
+		b, _ := hex.DecodeString(x)
+
+		i := d.Validate(b, 0)
+		if i != len(b) {
+			tb.Errorf("%s: %v", x, Error(i))
+		}
+	}
+}
+
+func TestValidateNotWellFormed(tb *testing.T) {
+	var d Decoder
+
+	for _, tc := range []struct {
+		Hex   string
+		Code  int
+		Index int
+	}{
+		// End of input in a head
+		{"18", ErrUnexpectedEOF, 0},
+		{"1901", ErrUnexpectedEOF, 0},
+		{"1b00000000", ErrUnexpectedEOF, 0},
+		{"38", ErrUnexpectedEOF, 0},
+		{"58", ErrUnexpectedEOF, 0},
+		{"9a01ff00", ErrUnexpectedEOF, 0},
+		{"d8", ErrUnexpectedEOF, 0},
+		{"f8", ErrUnexpectedEOF, 0},
+		{"f900", ErrUnexpectedEOF, 0},
+		{"fb000000", ErrUnexpectedEOF, 0},
+
+		// Definite-length strings with short data
+		{"41", ErrUnexpectedEOF, 0},
+		{"61", ErrUnexpectedEOF, 0},
+		{"5affffffff00", ErrUnexpectedEOF, 0},
+		{"5bffffffffffffffff010203", ErrUnexpectedEOF, 0},
+		{"7b7fffffffffffffff010203", ErrUnexpectedEOF, 0},
+
+		// Definite-length maps and arrays not closed with enough items
+		{"81", ErrUnexpectedEOF, 0},
+		{"818181818181818181", ErrUnexpectedEOF, 8},
+		{"8200", ErrUnexpectedEOF, 0},
+		{"a1", ErrUnexpectedEOF, 0},
+		{"a20102", ErrUnexpectedEOF, 3},
+		{"a100", ErrUnexpectedEOF, 2},
+
+		// Tag number not followed by tag content
+		{"c0", ErrUnexpectedEOF, 1},
+
+		// Indefinite-length strings and containers not closed by a break
+		{"5f4100", ErrUnexpectedEOF, 3},
+		{"7f6100", ErrUnexpectedEOF, 3},
+		{"9f", ErrUnexpectedEOF, 1},
+		{"9f0102", ErrUnexpectedEOF, 3},
+		{"bf", ErrUnexpectedEOF, 1},
+		{"bf01020102", ErrUnexpectedEOF, 5},
+		{"819f", ErrUnexpectedEOF, 2},
+		{"9f8000", ErrUnexpectedEOF, 3},
+		{"9f9f9f9f9fffffffff", ErrUnexpectedEOF, 9},
+		{"9f819f819f9fffffff", ErrUnexpectedEOF, 9},
+
+		// Reserved additional information values
+		{"1c", ErrMalformed, 0},
+		{"3d", ErrMalformed, 0},
+		{"5e", ErrMalformed, 0},
+		{"7c", ErrMalformed, 0},
+		{"9d", ErrMalformed, 0},
+		{"be", ErrMalformed, 0},
+		{"dc", ErrMalformed, 0},
+		{"fd", ErrMalformed, 0},
+
+		// Reserved two-byte encodings of simple values
+		{"f800", ErrMalformed, 0},
+		{"f818", ErrMalformed, 0},
+		{"f81f", ErrMalformed, 0},
+
+		// Indefinite-length string chunks not of the correct type
+		{"5f00ff", ErrMalformed, 1},
+		{"5f21ff", ErrMalformed, 1},
+		{"5f6100ff", ErrMalformed, 1},
+		{"5f80ff", ErrMalformed, 1},
+		{"5fa0ff", ErrMalformed, 1},
+		{"5fc000ff", ErrMalformed, 1},
+		{"5fe0ff", ErrMalformed, 1},
+		{"7f4100ff", ErrMalformed, 1},
+
+		// Indefinite-length string chunks not definite length
+		{"5f5f4100ffff", ErrMalformed, 1},
+		{"7f7f6100ffff", ErrMalformed, 1},
+
+		// Break occurring on its own outside of an indefinite-length item
+		{"ff", ErrMalformed, 0},
+
+		// Break occurring in a definite-length array or map or a tag
+		{"81ff", ErrMalformed, 1},
+		{"8200ff", ErrMalformed, 2},
+		{"a1ff", ErrMalformed, 1},
+		{"a1ff00", ErrMalformed, 1},
+		{"a100ff", ErrMalformed, 2},
+		{"a20000ff", ErrMalformed, 3},
+		{"9f81ff", ErrMalformed, 2},
+		{"9f829f819f9fffffffff", ErrMalformed, 9},
+		{"c0ff", ErrMalformed, 1},
+
+		// Break in an indefinite-length map in a value position
+		{"bf00ff", ErrMalformed, 2},
+		{"bf000000ff", ErrMalformed, 4},
+
+		// Major type 0, 1, 6 with additional information 31
+		{"1f", ErrMalformed, 0},
+		{"3f", ErrMalformed, 0},
+		{"df", ErrMalformed, 0},
+	} {
+		b, _ := hex.DecodeString(tc.Hex)
+
+		i := d.Validate(b, 0)
+		if i >= 0 {
+			tb.Errorf("%s: expected error, got %d", tc.Hex, i)
+			continue
+		}
+
+		code, index := Error(i).CodeIndex()
+		if code != tc.Code || index != tc.Index {
+			tb.Errorf("%s: expected %s at %d, got %v", tc.Hex, errStrings[tc.Code], tc.Index, Error(i))
+		}
+	}
+}
+
+func TestValidateSequence(tb *testing.T) {
+	var d Decoder
+
+	b, _ := hex.DecodeString(strings.Join([]string{"01", "8102", "a16161f5", "f93c00"}, ""))
+
+	if i := d.ValidateSequence(b, 0); i != len(b) {
+		tb.Errorf("sequence: %v", Error(i))
+	}
+
+	if i := d.ValidateSequence(b[:len(b)-1], 0); Error(i).Code() != ErrUnexpectedEOF {
+		tb.Errorf("truncated sequence: %v", Error(i))
+	}
+
+	b = MakeEncoder().AppendFloat(nil, 3)
+
+	if i := d.Validate(b, 0); i >= 0 {
+		tb.Errorf("float8 accepted without flag: % x", b)
+	}
+
+	if i := MakeDecoder().Validate(b, 0); i != len(b) {
+		tb.Errorf("float8 not accepted with flag: %v", Error(i))
+	}
+}
+
+func TestCheckDeterministic(tb *testing.T) {
+	var d Decoder
+
+	for _, tc := range []struct {
+		Hex   string
+		Index int // offending item or -1
+	}{
+		{"17", -1},
+		{"1817", 0},
+		{"1818", -1},
+		{"1900ff", 0},
+		{"1a0000ffff", 0},
+		{"1b00000000ffffffff", 0},
+		{"1b0000000100000000", -1},
+		{"3818", -1},
+		{"5800", 0},
+		{"9f01ff", 0},
+		{"5f4101ff", 0},
+		{"820118ff", -1},
+		{"82011801", 2},
+		{"f93e00", -1},
+		{"fa3fc00000", 0},
+		{"fb3ff8000000000000", 0},
+		{"fa47c35000", -1},
+		{"fb3ff199999999999a", -1},
+		{"fb7ff8000000000000", 0},
+		{"a30102030461610a", -1},
+		{"a2030401020304", 3},
+		{"a2616101616102", 4},
+		{"a2200101020304", 3},
+		{"c11a514b67b0", -1},
+		{"d81801", -1},
+		{"d80101", 0},
+		{"c1d80101", 1},
+	} {
+		b, _ := hex.DecodeString(tc.Hex)
+
+		exp := len(b)
+		if tc.Index >= 0 {
+			exp = newError(ErrNotDeterministic, tc.Index)
+		}
+
+		if i := d.CheckDeterministic(b, 0); i != exp {
+			tb.Errorf("%s: %v, wanted %v", tc.Hex, Error(i), Error(exp))
+		}
+	}
+}
@@ -0,0 +1,974 @@
+package cbor
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+type (
+	// Marshaler is implemented by types encoding themselves.
+	// AppendCBOR appends exactly one data item to b.
+	Marshaler interface {
+		AppendCBOR(e Encoder, b []byte) []byte
+	}
+
+	// Unmarshaler is implemented by types decoding themselves.
+	// DecodeCBOR decodes the data item at st and returns its end.
+	// DecodeValue validates only the outer data item, so it's well-formed,
+	// but the content of byte strings is not checked. Implementations decoding
+	// embedded CBOR must set FtSafe or call Decoder.Validate on it.
+	Unmarshaler interface {
+		DecodeCBOR(d Decoder, b []byte, st int) (int, error)
+	}
+
+	encFunc func(e Encoder, b []byte, v reflect.Value) ([]byte, error)
+	decFunc func(d Decoder, b []byte, st int, v reflect.Value) (int, error)
+
+	// codec is a cached per-type encoder and decoder.
+	codec struct {
+		enc encFunc
+		dec decFunc
+	}
+)
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+var (
+	errNotPointer = errors.New("decode into non-pointer or nil")
+	errExtraData  = errors.New("extra data after the value")
+	errNotExist   = errors.New("value doesn't exist")
+)
+
+// Marshal encodes v using MakeEncoder.
+// See Encoder.AppendValue for details.
+func Marshal(v any) ([]byte, error) {
+	return MakeEncoder().AppendValue(nil, v)
+}
+
+// Unmarshal decodes b into v using MakeDecoder.
+// b must contain exactly one data item.
+// See Decoder.DecodeValue for details.
+func Unmarshal(b []byte, v any) error {
+	i, err := MakeDecoder().DecodeValue(b, 0, v)
+	if err != nil {
+		return err
+	}
+
+	if i != len(b) {
+		return fmt.Errorf("at %d: %w", i, errExtraData)
+	}
+
+	return nil
+}
+
+// AppendValue encodes arbitrary Go value v using reflection.
+//
+// Booleans, integers, floats and strings are encoded as corresponding CBOR types.
+// []byte and [N]byte are encoded as Bytes, other slices and arrays as Array.
+// Maps are encoded as Map, and if FtDeterministic is set map entries are sorted.
+// Structs are encoded as Map with exported field names as keys.
+// Fields are controlled by `cbor:"name,omitempty,keyasint"` tags:
+// "-" skips the field, name renames it, omitempty skips empty values,
+// keyasint makes the name encoded as an integer key.
+// A blank field tagged `cbor:",toarray"` makes the struct encoded as Array of its fields.
+// Embedded structs fields are promoted following the encoding/json rules.
+// Nil pointers, interfaces, slices and maps are encoded as Null.
+//
+// Types implementing Marshaler encode themselves,
+// encoding.BinaryMarshaler is encoded as Bytes and encoding.TextMarshaler as String.
+// Types bound to a tag in Encoder.Tags are encoded with that tag, see TagSpec.
+//
+// Per-type encoders are cached, so repeated encoding of the same type doesn't build them again.
+func (e Encoder) AppendValue(b []byte, v any) ([]byte, error) {
+	if v == nil {
+		return e.AppendNull(b), nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	return e.tags().codecFor(rv.Type()).enc(e, b, rv)
+}
+
+// DecodeValue decodes the data item at st into v which must be a non-nil pointer.
+// The item is validated first, so malformed input is reported as Error and never panics.
+// It returns the end of the item.
+//
+// Decoding into interface{} produces int64, uint64 if it's positive and doesn't fit,
+// *big.Int if it's negative and doesn't fit, float64,
+// string, []byte, bool, nil, []interface{} and map[interface{}]interface{}.
+// Tags are decoded according to Decoder.Tags into the bound types or TagValue, see TagSpec.
+// Null sets pointers, slices, maps and interfaces to nil and leaves other values untouched.
+// Unknown struct fields are skipped unless FtDisallowUnknownFields is set.
+// Types implementing Unmarshaler, encoding.BinaryUnmarshaler or encoding.TextUnmarshaler decode themselves.
+func (d Decoder) DecodeValue(b []byte, st int, v any) (i int, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return st, errNotPointer
+	}
+
+	i = d.Validate(b, st)
+	if i < 0 {
+		return st, Error(i)
+	}
+
+	return d.tags().codecFor(rv.Type().Elem()).dec(d, b, st, rv.Elem())
+}
+
+func (r *TagRegistry) codecFor(t reflect.Type) *codec {
+	codecs := r.codecs.Load()
+
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codecs = r.codecs.Load()
+	building := map[reflect.Type]*codec{}
+
+	c := r.buildCodec(t, building)
+
+	for t, c := range building {
+		codecs.Store(t, c)
+	}
+
+	return c
+}
+
+// buildCodec builds the codec for t. r.mu must be held.
+func (r *TagRegistry) buildCodec(t reflect.Type, building map[reflect.Type]*codec) *codec {
+	if c, ok := r.codecs.Load().Load(t); ok {
+		return c.(*codec)
+	}
+
+	if c, ok := building[t]; ok {
+		return c
+	}
+
+	c := &codec{}
+	building[t] = c
+
+	switch t.Kind() {
+	case reflect.Bool:
+		c.enc, c.dec = encBool, decBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.enc, c.dec = encInt, decInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		c.enc, c.dec = encUint, decUint
+	case reflect.Float32, reflect.Float64:
+		c.enc, c.dec = encFloat, decFloat
+	case reflect.String:
+		c.enc, c.dec = encString, decString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			c.enc, c.dec = encByteSlice, decByteSlice
+			break
+		}
+
+		ec := r.buildCodec(t.Elem(), building)
+		c.enc, c.dec = encSlice(ec), decSlice(ec)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			c.enc, c.dec = encByteArray, decByteArray
+			break
+		}
+
+		ec := r.buildCodec(t.Elem(), building)
+		c.enc, c.dec = encSlice(ec), decArray(ec)
+	case reflect.Map:
+		kc := r.buildCodec(t.Key(), building)
+		vc := r.buildCodec(t.Elem(), building)
+		c.enc, c.dec = encMap(kc, vc), decMap(kc, vc)
+	case reflect.Pointer:
+		ec := r.buildCodec(t.Elem(), building)
+		c.enc, c.dec = encPtr(ec), decPtr(ec)
+	case reflect.Interface:
+		c.enc, c.dec = encIface, decIface
+	case reflect.Struct:
+		c.enc, c.dec = r.structCodec(t, building)
+	default:
+		err := fmt.Errorf("unsupported type: %v", t)
+
+		c.enc = func(e Encoder, b []byte, v reflect.Value) ([]byte, error) { return b, err }
+		c.dec = func(d Decoder, b []byte, st int, v reflect.Value) (int, error) { return st, err }
+	}
+
+	if t == tagValueType {
+		c.enc, c.dec = encTagValue, decTagValue
+	}
+
+	if t.Kind() == reflect.Interface {
+		return c
+	}
+
+	if t.Kind() == reflect.Pointer && r.table.Load().types[t.Elem()] != nil {
+		return c // tagged pointee encoding is preferred over pointer methods
+	}
+
+	switch {
+	case t.Implements(marshalerType):
+		c.enc = encMarshaler
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(marshalerType):
+		c.enc = encAddr(encMarshaler)
+	case t.Implements(binaryMarshalerType):
+		c.enc = encBinaryMarshaler
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(binaryMarshalerType):
+		c.enc = encAddr(encBinaryMarshaler)
+	case t.Implements(textMarshalerType):
+		c.enc = encTextMarshaler
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType):
+		c.enc = encAddr(encTextMarshaler)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if spec, ok := r.table.Load().types[t]; ok {
+			c.enc, c.dec = encTagged(spec, c.enc), decTagged(r.table.Load(), spec, c.dec)
+		}
+
+		return c
+	}
+
+	pt := reflect.PointerTo(t)
+
+	switch {
+	case pt.Implements(unmarshalerType):
+		c.dec = decUnmarshaler
+	case pt.Implements(binaryUnmarshalType) || pt.Implements(textUnmarshalType):
+		c.dec = decBinaryTextUnmarshaler(c.dec)
+	}
+
+	if spec, ok := r.table.Load().types[t]; ok {
+		c.enc, c.dec = encTagged(spec, c.enc), decTagged(r.table.Load(), spec, c.dec)
+	} else if _, ok := r.table.Load().types[pt]; ok {
+		// values of the type bound by pointer are handled in place
+		pc := r.buildCodec(pt, building)
+		c.enc, c.dec = encAddr(pc.enc), decAddr(pc.dec)
+	}
+
+	return c
+}
+
+func encBool(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendBool(b, v.Bool()), nil
+}
+
+func encInt(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendInt64(b, v.Int()), nil
+}
+
+func encUint(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendUint64(b, v.Uint()), nil
+}
+
+func encFloat(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Float32 {
+		return e.AppendFloat32(b, float32(v.Float())), nil
+	}
+
+	return e.AppendFloat(b, v.Float()), nil
+}
+
+func encString(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	return e.AppendString(b, v.String()), nil
+}
+
+func encByteSlice(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return e.AppendNull(b), nil
+	}
+
+	return e.AppendBytes(b, v.Bytes()), nil
+}
+
+func encByteArray(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	b = e.AppendTag(b, Bytes, v.Len())
+
+	if v.CanAddr() {
+		return append(b, v.Bytes()...), nil
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		b = append(b, byte(v.Index(i).Uint()))
+	}
+
+	return b, nil
+}
+
+func encSlice(ec *codec) encFunc {
+	return func(e Encoder, b []byte, v reflect.Value) (_ []byte, err error) {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return e.AppendNull(b), nil
+		}
+
+		b = e.AppendArray(b, v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			b, err = ec.enc(e, b, v.Index(i))
+			if err != nil {
+				return b, err
+			}
+		}
+
+		return b, nil
+	}
+}
+
+func encMap(kc, vc *codec) encFunc {
+	return func(e Encoder, b []byte, v reflect.Value) (_ []byte, err error) {
+		if v.IsNil() {
+			return e.AppendNull(b), nil
+		}
+
+		st := len(b)
+		b = e.AppendMap(b, v.Len())
+
+		it := v.MapRange()
+
+		for it.Next() {
+			b, err = kc.enc(e, b, it.Key())
+			if err != nil {
+				return b, err
+			}
+
+			b, err = vc.enc(e, b, it.Value())
+			if err != nil {
+				return b, err
+			}
+		}
+
+		if e.Flags.Is(FtDeterministic) {
+			b = e.SortMap(b, st)
+		}
+
+		return b, nil
+	}
+}
+
+func encPtr(ec *codec) encFunc {
+	return func(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+		if v.IsNil() {
+			return e.AppendNull(b), nil
+		}
+
+		return ec.enc(e, b, v.Elem())
+	}
+}
+
+func encIface(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return e.AppendNull(b), nil
+	}
+
+	v = v.Elem()
+
+	return e.tags().codecFor(v.Type()).enc(e, b, v)
+}
+
+func encMarshaler(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return e.AppendNull(b), nil
+	}
+
+	return v.Interface().(Marshaler).AppendCBOR(e, b), nil
+}
+
+func encBinaryMarshaler(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return e.AppendNull(b), nil
+	}
+
+	data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return b, err
+	}
+
+	return e.AppendBytes(b, data), nil
+}
+
+func encTextMarshaler(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return e.AppendNull(b), nil
+	}
+
+	data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return b, err
+	}
+
+	return e.AppendTagBytes(b, String, data), nil
+}
+
+// encAddr calls enc with pointer to the value for methods with pointer receivers.
+// Not addressable value is copied.
+func encAddr(enc encFunc) encFunc {
+	return func(e Encoder, b []byte, v reflect.Value) ([]byte, error) {
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+
+		return enc(e, b, v.Addr())
+	}
+}
+
+func decAddr(dec decFunc) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+		if i, ok := decNull(d, b, st, v); ok {
+			return i, nil
+		}
+
+		return dec(d, b, st, v.Addr())
+	}
+}
+
+func decUnmarshaler(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	return v.Addr().Interface().(Unmarshaler).DecodeCBOR(d, b, st)
+}
+
+func decBinaryTextUnmarshaler(next decFunc) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+		p := v.Addr().Interface()
+
+		bu, isBin := p.(encoding.BinaryUnmarshaler)
+		tu, isText := p.(encoding.TextUnmarshaler)
+
+		tag := d.TagOnly(b, st)
+
+		switch {
+		case tag == Bytes && isBin, tag == String && isBin && !isText:
+			var s []byte
+			s, i = d.appendString(nil, b, st)
+
+			return i, bu.UnmarshalBinary(s)
+		case tag == String && isText, tag == Bytes && isText && !isBin:
+			var s []byte
+			s, i = d.appendString(nil, b, st)
+
+			return i, tu.UnmarshalText(s)
+		}
+
+		return next(d, b, st, v)
+	}
+}
+
+// decNull handles Null and Undefined for all the kinds.
+// It also skips labels, tags bound to types are handled by their codecs.
+func decNull(d Decoder, b []byte, st int, v reflect.Value) (i int, ok bool) {
+	st = skipLabels(d, b, st)
+
+	if b[st] != byte(Simple|Null) && b[st] != byte(Simple|Undefined) {
+		return st, false
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		v.Set(reflect.Zero(v.Type()))
+	}
+
+	return st + 1, true
+}
+
+func skipLabels(d Decoder, b []byte, st int) int {
+	for Tag(b[st])&TagMask == Labeled {
+		_, _, st = d.Tag(b, st)
+	}
+
+	return st
+}
+
+func decBool(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	switch b[st] {
+	case byte(Simple | False):
+		v.SetBool(false)
+	case byte(Simple | True):
+		v.SetBool(true)
+	default:
+		return st, typeError(b, st, v.Type())
+	}
+
+	return st + 1, nil
+}
+
+func decInt(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	tag, sub, i := d.Tag(b, st)
+	if tag != Int && tag != Neg {
+		return st, typeError(b, st, v.Type())
+	}
+
+	x := uint64(sub)
+	if x > math.MaxInt64 {
+		return st, overflowError(b, st, v.Type())
+	}
+
+	r := int64(x)
+	if tag == Neg {
+		r = -1 - r
+	}
+
+	if v.OverflowInt(r) {
+		return st, overflowError(b, st, v.Type())
+	}
+
+	v.SetInt(r)
+
+	return i, nil
+}
+
+func decUint(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	tag, sub, i := d.Tag(b, st)
+	if tag == Neg {
+		return st, overflowError(b, st, v.Type())
+	}
+	if tag != Int {
+		return st, typeError(b, st, v.Type())
+	}
+
+	if v.OverflowUint(uint64(sub)) {
+		return st, overflowError(b, st, v.Type())
+	}
+
+	v.SetUint(uint64(sub))
+
+	return i, nil
+}
+
+func decFloat(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	tag := Tag(b[st])
+
+	switch {
+	case d.isFloat(tag):
+		f, i := d.Float(b, st)
+		v.SetFloat(f)
+
+		return i, nil
+	case IsInt(tag):
+		tag, sub, i := d.Tag(b, st)
+
+		f := float64(uint64(sub))
+		if tag == Neg {
+			f = -1 - f
+		}
+
+		v.SetFloat(f)
+
+		return i, nil
+	}
+
+	return st, typeError(b, st, v.Type())
+}
+
+func decString(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	if tag := Tag(b[st]) & TagMask; tag != String && tag != Bytes {
+		return st, typeError(b, st, v.Type())
+	}
+
+	s, i := d.appendString(nil, b, st)
+	v.SetString(string(s))
+
+	return i, nil
+}
+
+func decByteSlice(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	if tag := Tag(b[st]) & TagMask; tag != String && tag != Bytes {
+		return st, typeError(b, st, v.Type())
+	}
+
+	s, i := d.appendString(v.Bytes()[:0], b, st)
+	if s == nil {
+		s = []byte{}
+	}
+
+	v.SetBytes(s)
+
+	return i, nil
+}
+
+func decByteArray(d Decoder, b []byte, st int, v reflect.Value) (int, error) {
+	st, ok := decNull(d, b, st, v)
+	if ok {
+		return st, nil
+	}
+
+	if tag := Tag(b[st]) & TagMask; tag != String && tag != Bytes {
+		return st, typeError(b, st, v.Type())
+	}
+
+	s, i := d.appendString(nil, b, st)
+
+	reflect.Copy(v, reflect.ValueOf(s))
+
+	for j := len(s); j < v.Len(); j++ {
+		v.Index(j).SetUint(0)
+	}
+
+	return i, nil
+}
+
+func decSlice(ec *codec) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+		st, ok := decNull(d, b, st, v)
+		if ok {
+			return st, nil
+		}
+
+		tag, l, i := d.Tag(b, st)
+		if tag != Array {
+			return st, typeError(b, st, v.Type())
+		}
+
+		if l >= 0 && int(l) > v.Cap() {
+			v.Set(reflect.MakeSlice(v.Type(), int(l), int(l)))
+		}
+
+		if l >= 0 {
+			v.SetLen(int(l))
+		}
+
+		n := 0
+
+		for ; l < 0 && !d.Break(b, &i) || n < int(l); n++ {
+			if l < 0 {
+				v.Set(growSlice(v, n+1))
+			}
+
+			i, err = ec.dec(d, b, i, v.Index(n))
+			if err != nil {
+				return i, err
+			}
+		}
+
+		if l < 0 && n == 0 {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+
+		return i, nil
+	}
+}
+
+func decArray(ec *codec) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+		st, ok := decNull(d, b, st, v)
+		if ok {
+			return st, nil
+		}
+
+		tag, l, i := d.Tag(b, st)
+		if tag != Array {
+			return st, typeError(b, st, v.Type())
+		}
+
+		n := 0
+
+		for ; l < 0 && !d.Break(b, &i) || n < int(l); n++ {
+			if n >= v.Len() {
+				i = d.Skip(b, i)
+				continue
+			}
+
+			i, err = ec.dec(d, b, i, v.Index(n))
+			if err != nil {
+				return i, err
+			}
+		}
+
+		for ; n < v.Len(); n++ {
+			v.Index(n).Set(reflect.Zero(v.Type().Elem()))
+		}
+
+		return i, nil
+	}
+}
+
+func decMap(kc, vc *codec) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+		st, ok := decNull(d, b, st, v)
+		if ok {
+			return st, nil
+		}
+
+		tag, l, i := d.Tag(b, st)
+		if tag != Map {
+			return st, typeError(b, st, v.Type())
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), csel(l > 0 && l < 1024, int(l), 0)))
+		}
+
+		kv := reflect.New(v.Type().Key()).Elem()
+		vv := reflect.New(v.Type().Elem()).Elem()
+
+		for n := 0; l < 0 && !d.Break(b, &i) || n < int(l); n++ {
+			kv.Set(reflect.Zero(kv.Type()))
+			vv.Set(reflect.Zero(vv.Type()))
+
+			i, err = kc.dec(d, b, i, kv)
+			if err != nil {
+				return i, err
+			}
+
+			i, err = vc.dec(d, b, i, vv)
+			if err != nil {
+				return i, err
+			}
+
+			if !kv.Type().Comparable() || kv.Kind() == reflect.Interface && !kv.IsNil() && !kv.Elem().Type().Comparable() {
+				return st, fmt.Errorf("at %d: unhashable map key: %v", st, kv.Type())
+			}
+
+			v.SetMapIndex(kv, vv)
+		}
+
+		return i, nil
+	}
+}
+
+func decPtr(ec *codec) decFunc {
+	return func(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+		i, ok := decNull(d, b, st, v)
+		if ok {
+			return i, nil
+		}
+
+		// labels are kept for tagged values
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return ec.dec(d, b, st, v.Elem())
+	}
+}
+
+func decIface(d Decoder, b []byte, st int, v reflect.Value) (i int, err error) {
+	i, ok := decNull(d, b, st, v)
+	if ok {
+		return i, nil
+	}
+
+	// labels are kept for tagged values
+
+	if v.NumMethod() != 0 {
+		if v.IsNil() || v.Elem().Kind() != reflect.Pointer {
+			return st, typeError(b, st, v.Type())
+		}
+
+		e := v.Elem()
+
+		return d.tags().codecFor(e.Type()).dec(d, b, st, e)
+	}
+
+	x, i, err := d.decodeAny(b, st)
+	if err != nil {
+		return i, err
+	}
+
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(reflect.ValueOf(x))
+	}
+
+	return i, nil
+}
+
+func (d Decoder) decodeAny(b []byte, st int) (x any, i int, err error) {
+	tag, sub, i := d.Tag(b, st)
+
+	switch tag {
+	case Int:
+		if uint64(sub) > math.MaxInt64 {
+			return uint64(sub), i, nil
+		}
+
+		return sub, i, nil
+	case Neg:
+		if uint64(sub) > math.MaxInt64 {
+			x := new(big.Int)
+
+			i = d.bigInt(x, b, st)
+
+			return x, i, nil
+		}
+
+		return -1 - sub, i, nil
+	case Bytes:
+		s, i := d.appendString(nil, b, st)
+		if s == nil {
+			s = []byte{}
+		}
+
+		return s, i, nil
+	case String:
+		s, i := d.appendString(nil, b, st)
+
+		return string(s), i, nil
+	case Array:
+		arr := make([]any, 0, csel(sub > 0 && sub < 1024, int(sub), 0))
+
+		for n := 0; sub < 0 && !d.Break(b, &i) || n < int(sub); n++ {
+			x, i, err = d.decodeAny(b, i)
+			if err != nil {
+				return nil, i, err
+			}
+
+			arr = append(arr, x)
+		}
+
+		return arr, i, nil
+	case Map:
+		m := make(map[any]any, csel(sub > 0 && sub < 1024, int(sub), 0))
+
+		for n := 0; sub < 0 && !d.Break(b, &i) || n < int(sub); n++ {
+			kst := i
+
+			var k any
+
+			k, i, err = d.decodeAny(b, i)
+			if err != nil {
+				return nil, i, err
+			}
+
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, kst, fmt.Errorf("at %d: unhashable map key: %T", kst, k)
+			}
+
+			x, i, err = d.decodeAny(b, i)
+			if err != nil {
+				return nil, i, err
+			}
+
+			m[k] = x
+		}
+
+		return m, i, nil
+	case Labeled:
+		return d.decodeTagged(b, st, uint64(sub), i)
+	}
+
+	switch {
+	case sub == False, sub == True:
+		return sub == True, i, nil
+	case sub == Null, sub == Undefined, sub == None:
+		return nil, i, nil
+	case d.isFloat(Tag(b[st])):
+		f, i := d.Float(b, st)
+
+		return f, i, nil
+	}
+
+	return nil, st, fmt.Errorf("at %d: unsupported simple value: %d", st, d.simple(b, st))
+}
+
+// appendString appends the content of definite or indefinite length string or bytes.
+func (d Decoder) appendString(w, b []byte, st int) ([]byte, int) {
+	_, l, i := d.Tag(b, st)
+
+	if l >= 0 {
+		return append(w, b[i:i+int(l)]...), i + int(l)
+	}
+
+	for !d.Break(b, &i) {
+		var s []byte
+
+		s, i = d.Bytes(b, i)
+		w = append(w, s...)
+	}
+
+	return w, i
+}
+
+// isFloat is the same as IsFloat but Float8 is only a float if FtFloat8Int is set.
+// Otherwise it's a two-byte simple value.
+func (d Decoder) isFloat(tag Tag) bool {
+	return IsFloat(tag) && (tag != Simple|Float8 || d.Flags.Is(FtFloat8Int))
+}
+
+func (d Decoder) simple(b []byte, st int) int {
+	if b[st]&SubMask == Float8 {
+		return int(b[st+1])
+	}
+
+	return int(b[st] & SubMask)
+}
+
+func growSlice(v reflect.Value, n int) reflect.Value {
+	if n <= v.Cap() {
+		return v.Slice(0, n)
+	}
+
+	r := reflect.MakeSlice(v.Type(), n, 2*n)
+	reflect.Copy(r, v)
+
+	return r
+}
+
+func typeError(b []byte, st int, t reflect.Type) error {
+	return fmt.Errorf("at %d: cannot decode %v into %v", st, tagString(Tag(b[st])), t)
+}
+
+func overflowError(b []byte, st int, t reflect.Type) error {
+	return fmt.Errorf("at %d: %v value overflows %v", st, tagString(Tag(b[st])), t)
+}
+
+func tagString(tag Tag) string {
+	if tag&TagMask == Simple {
+		switch {
+		case tag == Simple|False, tag == Simple|True:
+			return "bool"
+		case tag == Simple|Null:
+			return "null"
+		case tag == Simple|Undefined:
+			return "undefined"
+		case IsFloat(tag):
+			return "float"
+		}
+
+		return "simple"
+	}
+
+	return []string{"int", "neg", "bytes", "string", "array", "map", "labeled"}[tag>>5]
+}
@@ -0,0 +1,346 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type (
+	testStruct struct {
+		Int    int
+		Int8   int8
+		Uint16 uint16
+		Float  float64
+		F32    float32
+		Bool   bool
+		Str    string
+		Bytes  []byte
+		Arr    [3]int
+		Fixed  [4]byte
+		Slice  []string
+		Map    map[string]int
+		Ptr    *int
+		Any    any
+		Nested *testStruct
+
+		private int
+	}
+
+	testList struct {
+		Val  int
+		Next *testList
+	}
+)
+
+func TestValueRoundtrip(tb *testing.T) {
+	x := 7
+
+	for _, v := range []any{
+		0, 1, -1, 1000, math.MaxInt64, math.MinInt64,
+		uint8(200), uint64(math.MaxUint64), int16(-300),
+		1.5, float32(0.25), math.Inf(-1),
+		true, false,
+		"", "hello",
+		[]byte{}, []byte{1, 2, 3},
+		[]int{1, 2, 3}, []string{"a", "b"},
+		[2]bool{true, false},
+		map[string]int{"a": 1, "b": 2},
+		map[int][]string{1: {"x"}, 2: nil},
+		&x,
+		testStruct{
+			Int: -5, Int8: -128, Uint16: 65535, Float: 3.25, F32: -1.5, Bool: true,
+			Str: "str", Bytes: []byte("bytes"), Arr: [3]int{1, 2, 3}, Fixed: [4]byte{1, 2, 3, 4},
+			Slice: []string{"q", "w"}, Map: map[string]int{"z": 26}, Ptr: &x, Any: "any",
+			Nested: &testStruct{Int: 1},
+		},
+		testList{Val: 1, Next: &testList{Val: 2, Next: &testList{Val: 3}}},
+	} {
+		b, err := Marshal(v)
+		if err != nil {
+			tb.Errorf("marshal %T: %v", v, err)
+			continue
+		}
+
+		p := reflect.New(reflect.TypeOf(v))
+
+		err = Unmarshal(b, p.Interface())
+		if err != nil {
+			tb.Errorf("unmarshal %T: %v\n%s", v, err, Dump(b))
+			continue
+		}
+
+		if r := p.Elem().Interface(); !reflect.DeepEqual(v, r) && !(reflect.TypeOf(v).Kind() == reflect.Float64 && math.IsNaN(v.(float64))) {
+			tb.Errorf("roundtrip %T\n%#v\n%#v\n%s", v, v, r, Dump(b))
+		}
+	}
+}
+
+func TestValueEncode(tb *testing.T) {
+	e := Encoder{Flags: FtDeterministic}
+
+	for _, tc := range []struct {
+		Value   any
+		Encoded []byte
+	}{
+		{nil, []byte{0xf6}},
+		{(*int)(nil), []byte{0xf6}},
+		{[]int(nil), []byte{0xf6}},
+		{[]int{}, []byte{0x80}},
+		{[]byte("ab"), []byte{0x42, 'a', 'b'}},
+		{[2]byte{1, 2}, []byte{0x42, 1, 2}},
+		{map[int]bool{10: true, 1: false, -1: true}, []byte{0xa3, 0x01, 0xf4, 0x0a, 0xf5, 0x20, 0xf5}},
+		{struct {
+			A int
+			b int
+			C string
+		}{A: 1, C: "c"}, []byte{0xa2, 0x61, 'A', 0x01, 0x61, 'C', 0x61, 'c'}},
+	} {
+		b, err := e.AppendValue(nil, tc.Value)
+		if err != nil {
+			tb.Errorf("%#v: %v", tc.Value, err)
+			continue
+		}
+
+		if !bytes.Equal(tc.Encoded, b) {
+			tb.Errorf("%#v -> % x, wanted % x", tc.Value, b, tc.Encoded)
+		}
+	}
+
+	_, err := e.AppendValue(nil, make(chan int))
+	if err == nil {
+		tb.Errorf("expected error for chan")
+	}
+}
+
+func TestValueDecodeAny(tb *testing.T) {
+	var e Encoder
+	var b []byte
+
+	b = e.AppendMap(b, -1)
+	b = e.AppendString(b, "a")
+	b = e.AppendArray(b, 3)
+	b = e.AppendInt(b, 1)
+	b = e.AppendInt(b, -2)
+	b = e.AppendUint64(b, math.MaxUint64)
+	b = e.AppendInt(b, 5)
+	b = e.AppendTag(b, Bytes, -1)
+	b = e.AppendBytes(b, []byte{1, 2})
+	b = e.AppendBytes(b, []byte{3})
+	b = e.AppendBreak(b)
+	b = e.AppendBool(b, true)
+	b = e.AppendNull(b)
+	b = e.AppendString(b, "f")
+	b = e.AppendLabeled(b, 1)
+	b = e.AppendFloat(b, 1.5)
+	b = e.AppendBreak(b)
+
+	var v any
+
+	err := Unmarshal(b, &v)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	exp := map[any]any{
+		"a":      []any{int64(1), int64(-2), uint64(math.MaxUint64)},
+		int64(5): []byte{1, 2, 3},
+		true:     nil,
+		"f":      time.Unix(1, 5e8).UTC(),
+	}
+
+	if !reflect.DeepEqual(exp, v) {
+		tb.Errorf("decoded %#v\nwanted %#v", v, exp)
+	}
+}
+
+func TestValueDecodeErrors(tb *testing.T) {
+	var e Encoder
+
+	var i8 int8
+	var u uint
+	var s string
+	var st testStruct
+	var m map[any]any
+
+	for j, tc := range []struct {
+		Data []byte
+		Dst  any
+	}{
+		{e.AppendInt(nil, 128), &i8},
+		{e.AppendInt(nil, -129), &i8},
+		{e.AppendInt(nil, -1), &u},
+		{e.AppendInt(nil, 1), &s},
+		{e.AppendString(nil, "s"), &i8},
+		{e.AppendArray(nil, 0), &st},
+		{append(e.AppendMap(nil, 1), 0x80, 0x01), &m},
+		{[]byte{0x82, 0x01}, &i8},
+		{[]byte{0x01, 0x02}, &i8},
+		{e.AppendInt(nil, 1), i8},
+	} {
+		err := Unmarshal(tc.Data, tc.Dst)
+		if err == nil {
+			tb.Errorf("%d: % x into %T: expected error", j, tc.Data, tc.Dst)
+		}
+
+		var cerr Error
+		if j == 7 && !errors.As(err, &cerr) {
+			tb.Errorf("%d: expected Error, got %v", j, err)
+		}
+	}
+}
+
+func TestValueNull(tb *testing.T) {
+	x := 5
+	v := testStruct{Int: 3, Ptr: &x, Slice: []string{"a"}, Map: map[string]int{}}
+
+	err := Unmarshal([]byte{0xa4, 0x63, 'I', 'n', 't', 0xf6, 0x63, 'P', 't', 'r', 0xf6, 0x65, 'S', 'l', 'i', 'c', 'e', 0xf6, 0x63, 'M', 'a', 'p', 0xf7}, &v)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if v.Int != 3 || v.Ptr != nil || v.Slice != nil || v.Map != nil {
+		tb.Errorf("null decoded: %+v", v)
+	}
+}
+
+func TestValueAllocs(tb *testing.T) {
+	e := MakeEncoder()
+	b := make([]byte, 0, 1024)
+
+	v := &testStruct{
+		Int: 1, Str: "str", Bytes: []byte("bytes"),
+		Slice: []string{"a", "b"}, Nested: &testStruct{Int: 2},
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		b, _ = e.AppendValue(b[:0], v)
+	})
+
+	if allocs != 0 {
+		tb.Errorf("allocs per encode: %v", allocs)
+	}
+}
+
+type (
+	testPoint struct {
+		X, Y int
+	}
+
+	testBin [2]byte
+
+	testText struct {
+		s string
+	}
+)
+
+func (p testPoint) AppendCBOR(e Encoder, b []byte) []byte {
+	b = e.AppendArray(b, 2)
+	b = e.AppendInt(b, p.X)
+	return e.AppendInt(b, p.Y)
+}
+
+func (p *testPoint) DecodeCBOR(d Decoder, b []byte, st int) (i int, err error) {
+	tag, l, i := d.Tag(b, st)
+	if tag != Array || l != 2 {
+		return st, errors.New("point expected")
+	}
+
+	x, i := d.Signed(b, i)
+	y, i := d.Signed(b, i)
+
+	p.X, p.Y = int(x), int(y)
+
+	return i, nil
+}
+
+func (x testBin) MarshalBinary() ([]byte, error) { return []byte{x[1], x[0]}, nil }
+
+func (x *testBin) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return errors.New("bad length")
+	}
+
+	x[0], x[1] = b[1], b[0]
+
+	return nil
+}
+
+func (x *testText) MarshalText() ([]byte, error) { return []byte("<" + x.s + ">"), nil }
+
+func (x *testText) UnmarshalText(b []byte) error {
+	x.s = string(b[1 : len(b)-1])
+	return nil
+}
+
+func TestValueMarshaler(tb *testing.T) {
+	type S struct {
+		P   testPoint
+		PP  *testPoint
+		Bin testBin
+		Txt testText
+		Ptr *testText
+	}
+
+	v := S{P: testPoint{1, 2}, PP: &testPoint{-3, 4}, Bin: testBin{5, 6}, Txt: testText{"a"}, Ptr: &testText{"b"}}
+
+	b, err := Marshal(v)
+	if err != nil {
+		tb.Fatalf("marshal: %v", err)
+	}
+
+	var m map[string]any
+
+	err = Unmarshal(b, &m)
+	if err != nil {
+		tb.Fatalf("unmarshal map: %v", err)
+	}
+
+	expm := map[string]any{
+		"P":   []any{int64(1), int64(2)},
+		"PP":  []any{int64(-3), int64(4)},
+		"Bin": []byte{6, 5},
+		"Txt": "<a>",
+		"Ptr": "<b>",
+	}
+
+	if !reflect.DeepEqual(expm, m) {
+		tb.Errorf("encoded %v, wanted %v", m, expm)
+	}
+
+	var r S
+
+	err = Unmarshal(b, &r)
+	if err != nil {
+		tb.Fatalf("unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(v, r) {
+		tb.Errorf("roundtrip\n%+v\n%+v", v, r)
+	}
+
+	// not addressable value with pointer receiver
+	b, err = Marshal(testText{"c"})
+	if err != nil || !bytes.Equal(b, []byte{0x63, '<', 'c', '>'}) {
+		tb.Errorf("text marshaler: % x %v", b, err)
+	}
+
+	if s := DumpValue(v); !strings.Contains(s, `"<a>"`) {
+		tb.Errorf("dump value:\n%s", s)
+	}
+
+	rd := NewReader(bytes.NewReader(append(testPoint{7, 8}.AppendCBOR(Encoder{}, nil), 0x82, 0x09, 0x0a)))
+
+	for _, exp := range []testPoint{{7, 8}, {9, 10}} {
+		var p testPoint
+
+		err = rd.DecodeValue(&p)
+		if err != nil || p != exp {
+			tb.Errorf("reader: %v %v, wanted %v", p, err, exp)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+type (
+	// Writer encodes data items into io.Writer through a bounded buffer.
+	// It tracks containers nesting and checks declared lengths are satisfied.
+	// Errors are sticky and report the stream offset of the failed item.
+	// Map keys are not sorted even in FtDeterministic mode.
+	Writer struct {
+		Encoder Encoder
+
+		wr    io.Writer
+		b     []byte
+		size  int
+		off   int64 // flushed bytes
+		stack []writerFrame
+		label bool // label written, content is expected
+		err   error
+	}
+
+	writerFrame struct {
+		tag Tag
+		n   int // declared number of items (keys and values for Map), -1 for indefinite
+		el  int
+		st  int64
+	}
+)
+
+var (
+	errTooManyItems  = errors.New("too many items for declared length")
+	errTooFewItems   = errors.New("declared length not satisfied")
+	errNoContainer   = errors.New("no open container")
+	errOpenContainer = errors.New("container not finished")
+	errStringChunk   = errors.New("indefinite string chunk must be a definite string of the same type")
+	errOddMap        = errors.New("map key without value")
+	errDanglingLabel = errors.New("label without content")
+	errIndefinite    = errors.New("indefinite length in deterministic mode")
+	errRawTrailing   = errors.New("raw data is more than one item")
+)
+
+const writerHeadSize = 9
+
+// NewWriter creates a Writer with the default buffer size.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterSize(w, 4096)
+}
+
+// NewWriterSize creates a Writer with the buffer of at most size bytes.
+// Longer strings are written in parts.
+func NewWriterSize(w io.Writer, size int) *Writer {
+	if size < 2*writerHeadSize {
+		size = 2 * writerHeadSize
+	}
+
+	return &Writer{
+		wr:      w,
+		Encoder: MakeEncoder(),
+		size:    size,
+	}
+}
+
+// Reset discards buffered data, errors and nesting state and switches to writing to w.
+func (w *Writer) Reset(wr io.Writer) {
+	w.wr = wr
+	w.b = w.b[:0]
+	w.off = 0
+	w.stack = w.stack[:0]
+	w.label = false
+	w.err = nil
+}
+
+// Offset returns the number of bytes written to the stream including buffered ones.
+func (w *Writer) Offset() int64 {
+	return w.off + int64(len(w.b))
+}
+
+// Depth returns the number of open containers.
+func (w *Writer) Depth() int {
+	return len(w.stack)
+}
+
+// Err returns the sticky error.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) WriteInt(v int) error {
+	return w.WriteInt64(int64(v))
+}
+
+func (w *Writer) WriteInt64(v int64) error {
+	if w.item(Int) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendInt64(w.b, v)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteUint(v uint) error {
+	return w.WriteUint64(uint64(v))
+}
+
+func (w *Writer) WriteUint64(v uint64) error {
+	if w.item(Int) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendUint64(w.b, v)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteFloat(v float64) error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendFloat(w.b, v)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteFloat32(v float32) error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendFloat32(w.b, v)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteBool(v bool) error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendBool(w.b, v)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteNull() error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendNull(w.b)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteUndefined() error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendUndefined(w.b)
+
+	return w.flushFull()
+}
+
+func (w *Writer) WriteSimple(x int) error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendSimple(w.b, x)
+
+	return w.flushFull()
+}
+
+// WriteText writes the text string item.
+func (w *Writer) WriteText(s string) error {
+	if w.item(String) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendTag(w.b, String, len(s))
+
+	return writePayload(w, s)
+}
+
+func (w *Writer) WriteBytes(s []byte) error {
+	if w.item(Bytes) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendTag(w.b, Bytes, len(s))
+
+	return writePayload(w, s)
+}
+
+// WriteLabel writes a tag. It must be followed by the content item.
+func (w *Writer) WriteLabel(x int) error {
+	if w.item(Labeled) != nil {
+		return w.err
+	}
+
+	w.b = w.Encoder.AppendLabeled(w.b, x)
+	w.label = true
+
+	return w.flushFull()
+}
+
+// WriteRaw writes the already encoded single data item.
+// raw is validated to be exactly one well-formed item.
+func (w *Writer) WriteRaw(raw []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	end := Decoder{Flags: w.Encoder.Flags}.Validate(raw, 0)
+	if end < 0 {
+		code, i := Error(end).CodeIndex()
+		return w.fail(w.Offset()+int64(i), code, nil)
+	}
+
+	if end != len(raw) {
+		return w.fail(w.Offset()+int64(end), 0, errRawTrailing)
+	}
+
+	tag := Tag(raw[0]) & TagMask
+	if raw[0]&SubMask == LenBreak {
+		tag = Simple // can't be a string chunk
+	}
+
+	if w.item(tag) != nil {
+		return w.err
+	}
+
+	return writePayload(w, raw)
+}
+
+// WriteValue encodes v using Encoder.AppendValue and writes it as a single item.
+// It's not streamed: the whole encoded value is held in memory
+// regardless of the buffer size before it's flushed.
+func (w *Writer) WriteValue(v any) error {
+	if w.item(Simple) != nil {
+		return w.err
+	}
+
+	st := len(w.b)
+
+	b, err := w.Encoder.AppendValue(w.b, v)
+	if err != nil {
+		w.b = b[:st]
+		return w.fail(w.Offset(), 0, err)
+	}
+
+	w.b = b
+
+	return w.flushFull()
+}
+
+// BeginArray starts the array of n elements. It must be finished with End.
+func (w *Writer) BeginArray(n int) error {
+	return w.begin(Array, n)
+}
+
+// BeginMap starts the map of n pairs. It must be finished with End.
+func (w *Writer) BeginMap(n int) error {
+	return w.begin(Map, n)
+}
+
+func (w *Writer) BeginIndefiniteArray() error {
+	return w.begin(Array, -1)
+}
+
+func (w *Writer) BeginIndefiniteMap() error {
+	return w.begin(Map, -1)
+}
+
+// BeginIndefiniteBytes starts the indefinite length byte string.
+// Only WriteBytes chunks are allowed until End.
+func (w *Writer) BeginIndefiniteBytes() error {
+	return w.begin(Bytes, -1)
+}
+
+// BeginIndefiniteString starts the indefinite length text string.
+// Only WriteText chunks are allowed until End.
+func (w *Writer) BeginIndefiniteString() error {
+	return w.begin(String, -1)
+}
+
+// End finishes the innermost container.
+// It writes Break for indefinite length containers
+// and checks the declared number of items is written for the others.
+func (w *Writer) End() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.label {
+		return w.fail(w.Offset(), 0, errDanglingLabel)
+	}
+
+	if len(w.stack) == 0 {
+		return w.fail(w.Offset(), 0, errNoContainer)
+	}
+
+	f := &w.stack[len(w.stack)-1]
+
+	switch {
+	case f.n >= 0 && f.el < f.n:
+		return w.fail(f.st, 0, errTooFewItems)
+	case f.n < 0 && f.tag == Map && f.el%2 != 0:
+		return w.fail(w.Offset(), 0, errOddMap)
+	}
+
+	w.stack = w.stack[:len(w.stack)-1]
+
+	if f.n < 0 {
+		w.b = w.Encoder.AppendBreak(w.b)
+	}
+
+	return w.flushFull()
+}
+
+// Flush writes buffered data to the underlaying io.Writer.
+// Containers may be left open.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.flush()
+}
+
+// Close checks all the containers are finished and flushes the buffer.
+// It doesn't close the underlaying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.label {
+		return w.fail(w.Offset(), 0, errDanglingLabel)
+	}
+
+	if len(w.stack) != 0 {
+		return w.fail(w.stack[len(w.stack)-1].st, 0, errOpenContainer)
+	}
+
+	return w.flush()
+}
+
+func (w *Writer) begin(tag Tag, n int) error {
+	if n < 0 && w.Encoder.Flags.Is(FtDeterministic) {
+		return w.fail(w.Offset(), 0, errIndefinite)
+	}
+
+	st := w.Offset()
+
+	if w.item(csel(n < 0, Simple, tag)) != nil { // indefinite string can't be a chunk
+		return w.err
+	}
+
+	fn := n
+	if tag == Map && n > 0 {
+		fn *= 2
+	}
+
+	w.stack = append(w.stack, writerFrame{tag: tag, n: fn, st: st})
+
+	w.b = w.Encoder.AppendTag(w.b, tag, n)
+
+	return w.flushFull()
+}
+
+// item accounts the next item in the current container and makes space for its head.
+// tag is the major type, it's checked for indefinite string chunks.
+func (w *Writer) item(tag Tag) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.b == nil {
+		w.size = csel(w.size != 0, w.size, 4096)
+		w.b = make([]byte, 0, w.size)
+	}
+
+	if len(w.b)+writerHeadSize > w.size {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	if w.label {
+		w.label = tag == Labeled
+		return nil
+	}
+
+	if len(w.stack) == 0 {
+		return nil
+	}
+
+	f := &w.stack[len(w.stack)-1]
+
+	if (f.tag == Bytes || f.tag == String) && f.tag != tag {
+		return w.fail(w.Offset(), 0, errStringChunk)
+	}
+
+	if f.n >= 0 && f.el >= f.n {
+		return w.fail(w.Offset(), 0, errTooManyItems)
+	}
+
+	f.el++
+
+	return nil
+}
+
+// writePayload writes s after the head already in the buffer
+// splitting it into parts if it doesn't fit.
+func writePayload[S ~string | ~[]byte](w *Writer, s S) error {
+	for len(s) != 0 {
+		if len(w.b) == w.size {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+
+		n := csel(len(s) < w.size-len(w.b), len(s), w.size-len(w.b))
+
+		w.b = append(w.b, s[:n]...)
+		s = s[n:]
+	}
+
+	return w.flushFull()
+}
+
+func (w *Writer) flushFull() error {
+	if len(w.b) < w.size {
+		return nil
+	}
+
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if len(w.b) == 0 {
+		return nil
+	}
+
+	n, err := w.wr.Write(w.b)
+	w.off += int64(n)
+
+	if n < len(w.b) && err == nil {
+		err = io.ErrShortWrite
+	}
+
+	if err != nil {
+		return w.fail(w.off, 0, err)
+	}
+
+	w.b = w.b[:0]
+
+	return nil
+}
+
+func (w *Writer) fail(off int64, code int, err error) error {
+	if err == nil {
+		err = Error(newError(code, int(off)))
+	} else {
+		err = fmt.Errorf("at %d: %w", off, err)
+	}
+
+	w.err = err
+
+	return err
+}
@@ -0,0 +1,130 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testShortWriter struct {
+	bytes.Buffer
+	writes int
+	max    int
+}
+
+func (w *testShortWriter) Write(p []byte) (int, error) {
+	w.writes++
+
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func TestWriter(tb *testing.T) {
+	var buf testShortWriter
+
+	w := NewWriterSize(&buf, 32)
+	w.Encoder = Encoder{Flags: FtFloat16}
+
+	long := strings.Repeat("x", 100)
+
+	for _, err := range []error{
+		w.BeginMap(3),
+		w.WriteText("a"),
+		w.BeginIndefiniteArray(),
+		w.WriteInt(1),
+		w.WriteInt64(-2),
+		w.WriteUint64(1 << 40),
+		w.WriteFloat(1.5),
+		w.WriteBool(true),
+		w.WriteNull(),
+		w.End(),
+		w.WriteText("s"),
+		w.BeginIndefiniteBytes(),
+		w.WriteBytes([]byte{1, 2}),
+		w.WriteBytes([]byte(long)),
+		w.End(),
+		w.WriteText("l"),
+		w.WriteLabel(1),
+		w.WriteLabel(2),
+		w.WriteText(long),
+		w.End(),
+		w.WriteRaw([]byte{0x82, 0x01, 0x02}),
+		w.WriteValue([]int{3, 4}),
+		w.Close(),
+	} {
+		if err != nil {
+			tb.Fatalf("write: %v", err)
+		}
+	}
+
+	exp, err := ParseDiag(`{"a": [_ 1, -2, 1099511627776, 1.5, true, null], "s": (_ h'0102', '` + long + `'), "l": 1(2("` + long + `"))}, [1, 2], [3, 4]`)
+	if err != nil {
+		tb.Fatalf("parse diag: %v", err)
+	}
+
+	if !bytes.Equal(exp, buf.Bytes()) {
+		tb.Errorf("written\n%s\nwanted\n%s", Diag(buf.Bytes()), Diag(exp))
+	}
+
+	if buf.max > 32 {
+		tb.Errorf("buffer exceeded: %v", buf.max)
+	}
+
+	if w.Offset() != int64(len(exp)) || w.Depth() != 0 {
+		tb.Errorf("offset %v depth %v", w.Offset(), w.Depth())
+	}
+
+	if _, ok := any(w).(io.Writer); ok {
+		tb.Errorf("unbuffered Write is exposed")
+	}
+}
+
+func TestWriterErrors(tb *testing.T) {
+	for j, tc := range []struct {
+		F   func(w *Writer) error
+		Err error
+		Off int64
+	}{
+		{func(w *Writer) error { w.BeginArray(1); w.WriteInt(1); return w.WriteInt(2) }, errTooManyItems, 2},
+		{func(w *Writer) error { w.WriteInt(1); w.BeginArray(2); w.WriteInt(1); return w.End() }, errTooFewItems, 1},
+		{func(w *Writer) error { w.BeginMap(1); w.WriteInt(1); w.WriteInt(2); return w.WriteInt(3) }, errTooManyItems, 3},
+		{func(w *Writer) error { w.BeginIndefiniteMap(); w.WriteInt(1); return w.End() }, errOddMap, 2},
+		{func(w *Writer) error { return w.End() }, errNoContainer, 0},
+		{func(w *Writer) error { w.BeginIndefiniteString(); return w.WriteBytes(nil) }, errStringChunk, 1},
+		{func(w *Writer) error { w.BeginIndefiniteString(); return w.BeginIndefiniteString() }, errStringChunk, 1},
+		{func(w *Writer) error { w.BeginArray(1); w.WriteLabel(1); return w.End() }, errDanglingLabel, 2},
+		{func(w *Writer) error { w.BeginArray(1); return w.Close() }, errOpenContainer, 0},
+		{func(w *Writer) error {
+			w.Encoder.Flags = FtDeterministic
+			return w.BeginIndefiniteArray()
+		}, errIndefinite, 0},
+		{func(w *Writer) error { w.WriteInt(1); return w.WriteRaw([]byte{0x82, 0x01}) }, MakeError(ErrUnexpectedEOF, 1), 1},
+		{func(w *Writer) error { return w.WriteRaw(nil) }, MakeError(ErrUnexpectedEOF, 0), 0},
+		{func(w *Writer) error { w.WriteInt(1); return w.WriteRaw([]byte{0x01, 0x02}) }, errRawTrailing, 2},
+		{func(w *Writer) error { w.BeginIndefiniteArray(); return w.WriteRaw([]byte{0xff}) }, MakeError(ErrMalformed, 1), 1},
+	} {
+		var buf bytes.Buffer
+
+		w := NewWriter(&buf)
+
+		err := tc.F(w)
+		if !errors.Is(err, tc.Err) {
+			tb.Errorf("%d: error %v, wanted %v", j, err, tc.Err)
+			continue
+		}
+
+		if at := "at " + strconv.FormatInt(tc.Off, 10); !strings.HasPrefix(err.Error(), at+":") && !strings.HasPrefix(err.Error(), at+" (") {
+			tb.Errorf("%d: error %v, wanted offset %d", j, err, tc.Off)
+		}
+
+		if err2 := w.WriteInt(1); err2 != err {
+			tb.Errorf("%d: error is not sticky: %v", j, err2)
+		}
+	}
+}